@@ -2,43 +2,141 @@ package Agent
 
 import (
 	"FrameworkMultiAgents/Messages"
+	"context"
 	"fmt"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// AgentState is the lifecycle state of an agent.
+type AgentState int
+
+const (
+	Initiated AgentState = iota // created, Start not called yet
+	Active                      // running its cycle
+	Suspended                   // paused by Suspend, messages stay in the mailbox
+	Waiting                     // idle between two cycles
+	Deleted                     // dead, the agent can not be restarted
+)
+
+// SchedulingMode tells Start when the agent handles its messages and runs its Perceive/Decide/Act cycle.
+type SchedulingMode int
+
+const (
+	// MixedScheduling handles messages as soon as they arrive and runs the cycle every TickInterval.
+	MixedScheduling SchedulingMode = iota
+	// ReactiveScheduling blocks until a message arrives, then handles it and runs the cycle once.
+	ReactiveScheduling
+	// PeriodicScheduling handles the waiting messages and runs the cycle every TickInterval.
+	PeriodicScheduling
+)
+
+const DefaultTickInterval = 1 * time.Second
+
+// SyncPollInterval is how often an agent run by Step looks at its synchronous channel,
+// a channel send can not wake the agent up.
+var SyncPollInterval = 10 * time.Millisecond
+
+// Priority orders the agents waiting for a worker, higher priorities get more turns.
+type Priority int
+
+const (
+	LowPriority Priority = iota - 1
+	NormalPriority
+	HighPriority
+)
+
 type Agent struct {
 	ID                      int `json:"id"`
+	AID                     AID `json:"aid"` // name@platform of the agent, see aid.go
 	CurrentBehaviour        Behaviour
 	AgentBehaviours         map[string]Behaviour
 	MailBox                 chan Messages.Message
 	SendAsyncMessageToAgent func(message Messages.Message, receiverId int, agentId int)
 	GetSyncChannelWithAgent func(SourceID, agentId int) (chan Messages.Message, error)
-	SynchronousChannel      chan Messages.Message
+	SendToReceivers         func(message Messages.Message) error
+	SynchronousChannel      chan Messages.Message // guarded by the state mutex, see SetSyncChannel
+	SchedulingMode          SchedulingMode
+	TickInterval            time.Duration
+	// OnDeath is called once, after the agent reached the Deleted state.
+	// The container uses it to forget the agent.
+	OnDeath func(agentID int)
+
+	// Priority is used by the worker pools of the containers, see Step.
+	Priority Priority
+
+	// Directory is set by the container, see RegisterServices and SearchServices.
+	Directory Directory
+
+	state      AgentState
+	stateMutex sync.Mutex
+	cancel     context.CancelFunc
+	ctx        context.Context
+	wakeup     func()
+	nextTick   time.Time
+
+	activeBehaviours []*activeBehaviour
+	behavioursMutex  sync.Mutex
+	roundRobin       int
+	queue            []Messages.Message // handled by no behaviour, kept for Receive
+	deferred         []Messages.Message // read by a Receive that did not take them, see receive.go
+	queueMutex       sync.Mutex         // guards queue and deferred
+	dropped          atomic.Int64
+	suspension       chan struct{} // Suspend or Resume was called, see Start
+	syncChanged      chan struct{} // the synchronous channel was replaced, see Start
+	done             chan struct{}
+	deathOnce        sync.Once
 }
 
+func (mode SchedulingMode) String() string {
+	switch mode {
+	case MixedScheduling:
+		return "mixed"
+	case ReactiveScheduling:
+		return "reactive"
+	case PeriodicScheduling:
+		return "periodic"
+	}
+	return "unknown"
+}
+
+func (state AgentState) String() string {
+	switch state {
+	case Initiated:
+		return "initiated"
+	case Active:
+		return "active"
+	case Suspended:
+		return "suspended"
+	case Waiting:
+		return "waiting"
+	case Deleted:
+		return "deleted"
+	}
+	return "unknown"
+}
+
+// Perceive, Decide and Act run a phase of the cycle on every runnable behaviour,
+// in a round robin order that changes on every cycle.
 func (agent *Agent) Perceive() {
-	if agent.CurrentBehaviour == nil {
-		fmt.Println("No behaviour set for agent")
-		return
+	for _, active := range agent.runnableBehaviours() {
+		active.behaviour.Perceive(agent)
 	}
-	agent.CurrentBehaviour.Perceive(agent)
 }
 
 func (agent *Agent) Decide() {
-	if agent.CurrentBehaviour == nil {
-		fmt.Println("No behaviour set for agent")
-		return
+	for _, active := range agent.runnableBehaviours() {
+		active.behaviour.Decide(agent)
 	}
-	agent.CurrentBehaviour.Decide(agent)
 }
 
 func (agent *Agent) Act() {
-	if agent.CurrentBehaviour == nil {
-		fmt.Println("No behaviour set for agent")
-		return
+	for _, active := range agent.runnableBehaviours() {
+		active.behaviour.Act(agent)
 	}
-	agent.CurrentBehaviour.Act(agent)
+	agent.markDoneBehaviours()
 }
 
 type Behaviour interface {
@@ -49,98 +147,463 @@ type Behaviour interface {
 	HandleSyncCommunication(agent *Agent, message Messages.Message)
 }
 
-func (agent *Agent) takeMail(message Messages.Message) {
-	agent.MailBox <- message
+// Deliver puts a message in the mailbox and wakes the agent up if it runs on a worker pool.
+// When the mailbox is full, Deliver waits for room if wait is true and drops the message otherwise.
+func (agent *Agent) Deliver(message Messages.Message, wait bool) bool {
+	if wait {
+		agent.MailBox <- message
+	} else {
+		select {
+		case agent.MailBox <- message:
+		default:
+			return false
+		}
+	}
+	agent.Wake()
+	return true
 }
 
 func (agent *Agent) SendMail(message Messages.Message, receiverId int) {
 	agent.SendAsyncMessageToAgent(message, receiverId, agent.ID)
 }
 
-func NewAgent(id string, sendMessageToContainer func(message Messages.Message, receiverId, agentId int), GetSyncChannelWithAgent func(SourceAgent, agentId int) (chan Messages.Message, error)) *Agent {
-	idInt, _ := strconv.Atoi(id)
+// Send delivers an agent message to every agent of its Receivers list, given by ID, name or name@platform.
+// Type and Sender, the AID of the agent, are set by the agent. To answer a message, send its CreateReply.
+// The receivers that cannot be reached do not keep the message from the others, the error joins their failures.
+func (agent *Agent) Send(message Messages.Message) error {
+	if len(message.Receivers) == 0 {
+		return fmt.Errorf("the message has no receivers")
+	}
+	if agent.SendToReceivers == nil {
+		return fmt.Errorf("the agent is not in a container")
+	}
+	message.Type = Messages.InterAgentAsyncMessage
+	message.Sender = agent.AID.String()
+	return agent.SendToReceivers(message)
+}
+
+// Name returns the name of the agent on its platform.
+func (agent *Agent) Name() string {
+	return agent.AID.Name
+}
+
+func NewAgent(id int, aid AID, sendMessageToContainer func(message Messages.Message, receiverId, agentId int), GetSyncChannelWithAgent func(SourceAgent, agentId int) (chan Messages.Message, error)) *Agent {
+	if aid.Name == "" {
+		aid.Name = strconv.Itoa(id)
+	}
 	return &Agent{
-		ID:                      idInt,
+		ID:                      id,
+		AID:                     aid,
 		CurrentBehaviour:        nil,
 		AgentBehaviours:         make(map[string]Behaviour),
 		MailBox:                 make(chan Messages.Message, 50),
 		SendAsyncMessageToAgent: sendMessageToContainer,
 		GetSyncChannelWithAgent: GetSyncChannelWithAgent,
 		SynchronousChannel:      nil,
+		SchedulingMode:          MixedScheduling,
+		TickInterval:            DefaultTickInterval,
+		state:                   Initiated,
+		suspension:              make(chan struct{}, 1),
+		syncChanged:             make(chan struct{}, 1),
+		done:                    make(chan struct{}),
 	}
 }
 
 func (agent *Agent) StartSyncCommunication(receiverId int) error {
-	if agent.SynchronousChannel != nil {
-		fmt.Errorf("The agent already has a synchronous communication")
+	if agent.getChannel() != nil {
+		return fmt.Errorf("The agent already has a synchronous communication")
 	}
 	channel, err := agent.GetSyncChannelWithAgent(agent.ID, receiverId)
 	if err != nil {
 		return err
 	}
-	agent.SynchronousChannel = channel
+	agent.SetSyncChannel(channel)
 	return nil
 }
 
 func (agent *Agent) SendSyncMessage(message Messages.Message) error {
-	if agent.SynchronousChannel == nil {
-		fmt.Errorf("The agent does not have a synchronous communication")
+	channel := agent.getChannel()
+	if channel == nil {
+		return fmt.Errorf("The agent does not have a synchronous communication")
 	}
-	agent.SynchronousChannel <- message
+	channel <- message
 	return nil
 }
 
 // function "giveNewChannel", which is used to give the container a synchronous channel to communicate with another agent.
 // The agent will create a new channel, return it to the container.
 func (agent *Agent) GiveNewChannel() (chan Messages.Message, error) {
-	if agent.SynchronousChannel == nil {
-		agent.SynchronousChannel = make(chan Messages.Message)
-		return agent.SynchronousChannel, nil
+	agent.stateMutex.Lock()
+	if agent.SynchronousChannel != nil {
+		agent.stateMutex.Unlock()
+		return nil, fmt.Errorf("The agent already has a synchronous communication")
 	}
-	return nil, fmt.Errorf("The agent already has a synchronous communication")
+	channel := make(chan Messages.Message)
+	agent.SynchronousChannel = channel
+	agent.stateMutex.Unlock()
+	agent.syncChannelChanged()
+	return channel, nil
+}
+
+// SetSyncChannel replaces the synchronous channel of the agent, nil to remove it.
+// A running agent reads the new channel at once.
+func (agent *Agent) SetSyncChannel(channel chan Messages.Message) {
+	agent.stateMutex.Lock()
+	agent.SynchronousChannel = channel
+	agent.stateMutex.Unlock()
+	agent.syncChannelChanged()
 }
 
 func (agent *Agent) getChannel() chan Messages.Message {
+	agent.stateMutex.Lock()
+	defer agent.stateMutex.Unlock()
 	return agent.SynchronousChannel
 }
 
+// syncChannelChanged wakes the agent up, blocked in Start or waiting for the worker pool,
+// so that it reads from the new synchronous channel.
+func (agent *Agent) syncChannelChanged() {
+	select {
+	case agent.syncChanged <- struct{}{}:
+	default:
+	}
+	agent.Wake()
+}
+
+// receiveSync handles a message read from the synchronous channel. It returns false when the channel
+// was closed by the other agent, the channel is then removed.
+func (agent *Agent) receiveSync(channel chan Messages.Message, message Messages.Message, ok bool) bool {
+	if !ok {
+		agent.stateMutex.Lock()
+		if agent.SynchronousChannel == channel {
+			agent.SynchronousChannel = nil
+		}
+		agent.stateMutex.Unlock()
+		return false
+	}
+	agent.handleSyncCommunication(message)
+	return true
+}
+
 func (agent *Agent) StopSynchronousCommunication() {
-	close(agent.SynchronousChannel)
+	agent.stateMutex.Lock()
+	channel := agent.SynchronousChannel
 	agent.SynchronousChannel = nil
+	agent.stateMutex.Unlock()
+	if channel != nil {
+		close(channel)
+	}
+	agent.syncChannelChanged()
+}
+
+// State returns the current lifecycle state of the agent.
+func (agent *Agent) State() AgentState {
+	agent.stateMutex.Lock()
+	defer agent.stateMutex.Unlock()
+	return agent.state
+}
+
+// transition moves the agent to the state "to" if its current state is one of "from".
+func (agent *Agent) transition(to AgentState, from ...AgentState) bool {
+	agent.stateMutex.Lock()
+	defer agent.stateMutex.Unlock()
+	for _, state := range from {
+		if agent.state == state {
+			agent.state = to
+			return true
+		}
+	}
+	return false
+}
+
+// Done returns a channel closed when the agent is dead.
+func (agent *Agent) Done() <-chan struct{} {
+	return agent.done
+}
+
+// Start runs the agent until ctx is cancelled, Kill is called or a Death message is read from the mailbox.
+func (agent *Agent) Start(ctx context.Context) {
+	ctx, ok := agent.begin(ctx, nil)
+	if !ok {
+		return
+	}
+	defer agent.die()
+	defer agent.cancel()
+
+	var tick <-chan time.Time
+	if agent.SchedulingMode != ReactiveScheduling {
+		ticker := time.NewTicker(agent.tickInterval())
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	// in periodic mode the messages are only read on ticks
+	var mailBox, syncChannel chan Messages.Message
+	if agent.SchedulingMode != PeriodicScheduling {
+		mailBox = agent.MailBox
+	}
+
+	for {
+		if ctx.Err() != nil {
+			// killed, the messages left are dropped
+			return
+		}
+		if agent.State() == Suspended {
+			select {
+			case <-agent.suspension:
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+		if agent.SchedulingMode != PeriodicScheduling {
+			// the synchronous channel can be replaced while the agent runs, syncChanged tells when
+			syncChannel = agent.getChannel()
+		}
+		agent.transition(Waiting, Active)
+		select {
+		case <-ctx.Done():
+			return
+		case message := <-mailBox:
+			agent.transition(Active, Waiting)
+			if !agent.handleMailboxMessage(message) {
+				return
+			}
+			if agent.SchedulingMode == ReactiveScheduling {
+				agent.cycle()
+			}
+		case message, ok := <-syncChannel:
+			agent.transition(Active, Waiting)
+			if !agent.receiveSync(syncChannel, message, ok) {
+				continue
+			}
+			if agent.SchedulingMode == ReactiveScheduling {
+				agent.cycle()
+			}
+		case <-agent.syncChanged:
+			agent.transition(Active, Waiting)
+		case <-agent.suspension:
+			// suspended while waiting, the messages stay in the mailbox
+		case <-tick:
+			agent.transition(Active, Waiting)
+			if agent.SchedulingMode == PeriodicScheduling && !agent.drainMessages() {
+				return
+			}
+			agent.cycle()
+		}
+	}
 }
 
-func (agent *Agent) handleSyncCommunication(message Messages.Message) {
-	agent.CurrentBehaviour.HandleSyncCommunication(agent, message)
+// Attach prepares the agent to be run by repeated calls to Step instead of Start.
+// wakeup is called whenever the agent has something new to do (message, Kill, Resume),
+// it must not block.
+func (agent *Agent) Attach(ctx context.Context, wakeup func()) bool {
+	_, ok := agent.begin(ctx, wakeup)
+	return ok
 }
 
-func (agent *Agent) RegisterBehaviour(name string, behaviour Behaviour) {
-	agent.AgentBehaviours[name] = behaviour
+// begin moves an initiated agent to the active state.
+func (agent *Agent) begin(ctx context.Context, wakeup func()) (context.Context, bool) {
+	agent.stateMutex.Lock()
+	defer agent.stateMutex.Unlock()
+	if agent.state != Initiated {
+		return nil, false
+	}
+	agent.ctx, agent.cancel = context.WithCancel(ctx)
+	agent.wakeup = wakeup
+	agent.state = Active
+	if agent.SchedulingMode != ReactiveScheduling {
+		agent.nextTick = time.Now().Add(agent.tickInterval())
+	}
+	return agent.ctx, true
 }
 
-func (agent *Agent) SetBehaviour(name string) {
-	agent.CurrentBehaviour = agent.AgentBehaviours[name]
+// Step runs one slice of an attached agent: at most budget messages, then the cycle if its tick is due.
+// It returns when the agent wants to run again, a zero time meaning "when woken up".
+// Step must not be called concurrently for the same agent.
+func (agent *Agent) Step(budget int) (next time.Time, alive bool) {
+	if agent.ctx.Err() != nil {
+		agent.die()
+		return time.Time{}, false
+	}
+	if !agent.transition(Active, Waiting, Active) {
+		// suspended, Resume will wake the agent up
+		return time.Time{}, true
+	}
+	defer agent.transition(Waiting, Active)
+	now := time.Now()
+
+	syncChannel := agent.getChannel()
+	if agent.SchedulingMode != PeriodicScheduling {
+	messages:
+		for handled := 0; handled < budget; handled++ {
+			select {
+			case message := <-agent.MailBox:
+				if !agent.handleMailboxMessage(message) {
+					agent.cancel()
+					agent.die()
+					return time.Time{}, false
+				}
+			case message, ok := <-syncChannel:
+				if !agent.receiveSync(syncChannel, message, ok) {
+					syncChannel = nil
+				}
+			default:
+				break messages
+			}
+			if agent.SchedulingMode == ReactiveScheduling {
+				agent.cycle()
+			}
+		}
+	}
+	if agent.SchedulingMode != ReactiveScheduling && !now.Before(agent.nextTick) {
+		if agent.SchedulingMode == PeriodicScheduling && !agent.drainMessages() {
+			agent.cancel()
+			agent.die()
+			return time.Time{}, false
+		}
+		agent.cycle()
+		agent.nextTick = now.Add(agent.tickInterval())
+	}
+
+	next = agent.nextTick
+	if agent.SchedulingMode != PeriodicScheduling && len(agent.MailBox) > 0 {
+		next = now
+	}
+	if agent.getChannel() != nil {
+		poll := now.Add(SyncPollInterval)
+		if next.IsZero() || next.After(poll) {
+			next = poll
+		}
+	}
+	return next, true
 }
 
-func (agent *Agent) RemoveBehaviour(name string) {
-	delete(agent.AgentBehaviours, name)
+// Wake asks the worker pool running the agent for a turn, it does nothing for agents run by Start.
+func (agent *Agent) Wake() {
+	agent.stateMutex.Lock()
+	wakeup := agent.wakeup
+	agent.stateMutex.Unlock()
+	if wakeup != nil {
+		wakeup()
+	}
+}
+
+func (agent *Agent) tickInterval() time.Duration {
+	if agent.TickInterval <= 0 {
+		return DefaultTickInterval
+	}
+	return agent.TickInterval
 }
 
-func (agent *Agent) Start() {
+// SetScheduling chooses how the agent is scheduled, it must be called before Start.
+// The interval is ignored in reactive mode.
+func (agent *Agent) SetScheduling(mode SchedulingMode, interval time.Duration) {
+	agent.SchedulingMode = mode
+	agent.TickInterval = interval
+}
+
+func (agent *Agent) cycle() {
+	agent.Perceive()
+	agent.Decide()
+	agent.Act()
+	agent.rotateBehaviours()
+	agent.dispatchDeferred()
+}
+
+// handleMailboxMessage returns false when the message asks the agent to die.
+func (agent *Agent) handleMailboxMessage(message Messages.Message) bool {
+	if message.Type == Messages.Death {
+		return false
+	}
+	if behaviour := agent.behaviourFor(message); behaviour != nil {
+		behaviour.HandleMailboxMessage(agent, message)
+		agent.dispatchDeferred()
+	} else {
+		// no behaviour asked for it, it waits for a Receive
+		agent.enqueue(message)
+		agent.restartBlockedBehaviours()
+	}
+	return true
+}
+
+// drainMessages handles the messages waiting for a periodic agent.
+func (agent *Agent) drainMessages() bool {
+	for waiting := len(agent.MailBox); waiting > 0; waiting-- {
+		if !agent.handleMailboxMessage(<-agent.MailBox) {
+			return false
+		}
+	}
+	syncChannel := agent.getChannel()
 	for {
 		select {
-		case message := <-agent.MailBox:
-			if message.Type == Messages.Death {
-				// handle death
-			} else {
-				agent.CurrentBehaviour.HandleMailboxMessage(agent, message)
+		case message, ok := <-syncChannel:
+			if !agent.receiveSync(syncChannel, message, ok) {
+				return true
 			}
-		case message := <-agent.SynchronousChannel:
-			agent.handleSyncCommunication(message)
 		default:
-			agent.Perceive()
-			agent.Decide()
-			agent.Act()
+			return true
 		}
-		time.Sleep(1 * time.Second)
 	}
 }
+
+// Stop asks the agent to die once the messages already in its mailbox are handled.
+func (agent *Agent) Stop() {
+	if agent.transition(Deleted, Initiated) {
+		agent.die()
+		return
+	}
+	if !agent.Deliver(Messages.Message{Type: Messages.Death, Sender: strconv.Itoa(agent.ID)}, false) {
+		// mailbox full, no room for a graceful death
+		agent.Kill()
+	}
+}
+
+// Kill stops the agent immediately, pending messages are dropped.
+func (agent *Agent) Kill() {
+	if agent.transition(Deleted, Initiated) {
+		agent.die()
+		return
+	}
+	agent.stateMutex.Lock()
+	cancel := agent.cancel
+	agent.stateMutex.Unlock()
+	if cancel != nil {
+		cancel()
+		agent.Wake()
+	}
+}
+
+// Suspend pauses the cycle of an active agent until Resume is called.
+func (agent *Agent) Suspend() {
+	if agent.transition(Suspended, Active, Waiting) {
+		agent.suspensionChanged()
+	}
+}
+
+// Resume restarts the cycle of a suspended agent.
+func (agent *Agent) Resume() {
+	if agent.transition(Active, Suspended) {
+		agent.suspensionChanged()
+		agent.Wake()
+	}
+}
+
+// suspensionChanged wakes up the loop of Start, waiting for messages or for Resume.
+func (agent *Agent) suspensionChanged() {
+	select {
+	case agent.suspension <- struct{}{}:
+	default:
+	}
+}
+
+func (agent *Agent) die() {
+	agent.deathOnce.Do(func() {
+		agent.stateMutex.Lock()
+		agent.state = Deleted
+		agent.stateMutex.Unlock()
+		close(agent.done)
+		if agent.OnDeath != nil {
+			agent.OnDeath(agent.ID)
+		}
+	})
+}
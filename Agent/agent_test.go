@@ -0,0 +1,231 @@
+package Agent
+
+import (
+	"FrameworkMultiAgents/Messages"
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recorder is a behaviour reporting the messages it handles and counting its cycles.
+type recorder struct {
+	mailbox chan Messages.Message
+	sync    chan Messages.Message
+	acts    atomic.Int64
+}
+
+func newRecorder() *recorder {
+	return &recorder{mailbox: make(chan Messages.Message, 16), sync: make(chan Messages.Message, 16)}
+}
+
+func (b *recorder) Perceive(agent *Agent, params ...interface{}) {}
+func (b *recorder) Decide(agent *Agent, params ...interface{})   {}
+func (b *recorder) Act(agent *Agent, params ...interface{})      { b.acts.Add(1) }
+func (b *recorder) HandleMailboxMessage(agent *Agent, message Messages.Message) {
+	b.mailbox <- message
+}
+func (b *recorder) HandleSyncCommunication(agent *Agent, message Messages.Message) {
+	b.sync <- message
+}
+
+// newTestAgent returns an agent running the behaviour, not started yet.
+func newTestAgent(id int, behaviour Behaviour) *Agent {
+	agent := NewAgent(id, AID{}, nil, nil)
+	agent.RegisterBehaviour("main", behaviour)
+	agent.SetBehaviour("main")
+	return agent
+}
+
+// start runs the agent until the end of the test.
+func start(t *testing.T, agent *Agent) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		<-agent.Done()
+	})
+	go agent.Start(ctx)
+}
+
+// waitFor fails the test unless the channel yields a message within a second.
+func waitFor(t *testing.T, messages <-chan Messages.Message, what string) Messages.Message {
+	t.Helper()
+	select {
+	case message := <-messages:
+		return message
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+		return Messages.Message{}
+	}
+}
+
+// waitUntil fails the test unless condition holds within a second.
+func waitUntil(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting until %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSchedulingModes(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        SchedulingMode
+		interval    time.Duration
+		wantHandled bool // the message is handled within the window
+		wantCycled  bool // the cycle ran within the window
+	}{
+		{"reactive", ReactiveScheduling, time.Hour, true, true},
+		{"mixed before the tick", MixedScheduling, time.Hour, true, false},
+		{"mixed", MixedScheduling, 10 * time.Millisecond, true, true},
+		{"periodic before the tick", PeriodicScheduling, time.Hour, false, false},
+		{"periodic", PeriodicScheduling, 10 * time.Millisecond, true, true},
+	}
+	const window = 200 * time.Millisecond
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			behaviour := newRecorder()
+			agent := newTestAgent(1, behaviour)
+			agent.SetScheduling(test.mode, test.interval)
+			start(t, agent)
+			agent.Deliver(Messages.Text("ping"), false)
+
+			select {
+			case <-behaviour.mailbox:
+				if !test.wantHandled {
+					t.Error("the message was handled before the tick")
+				}
+			case <-time.After(window):
+				if test.wantHandled {
+					t.Error("the message was not handled")
+				}
+			}
+			for deadline := time.Now().Add(window); behaviour.acts.Load() == 0 && time.Now().Before(deadline); {
+				time.Sleep(time.Millisecond)
+			}
+			if cycled := behaviour.acts.Load() > 0; cycled != test.wantCycled {
+				t.Errorf("cycled %v, want %v", cycled, test.wantCycled)
+			}
+		})
+	}
+}
+
+func TestSyncChannelGivenToABlockedAgent(t *testing.T) {
+	for _, mode := range []SchedulingMode{ReactiveScheduling, MixedScheduling} {
+		t.Run(mode.String(), func(t *testing.T) {
+			behaviour := newRecorder()
+			receiver := newTestAgent(1, behaviour)
+			receiver.SetScheduling(mode, time.Hour)
+			start(t, receiver)
+			// blocked without a synchronous channel, no tick to look for one
+			waitUntil(t, "the receiver waits", func() bool { return receiver.State() == Waiting })
+
+			sender := NewAgent(2, AID{}, nil, func(source, agentID int) (chan Messages.Message, error) {
+				return receiver.GiveNewChannel()
+			})
+			if err := sender.StartSyncCommunication(receiver.ID); err != nil {
+				t.Fatal(err)
+			}
+			sent := make(chan error, 1)
+			go func() { sent <- sender.SendSyncMessage(Messages.Text("ping")) }()
+			waitFor(t, behaviour.sync, "the synchronous message")
+			if err := <-sent; err != nil {
+				t.Fatal(err)
+			}
+
+			// closed by the sender, the receiver forgets the channel
+			sender.StopSynchronousCommunication()
+			waitUntil(t, "the receiver drops the closed channel", func() bool { return receiver.getChannel() == nil })
+			if _, err := receiver.GiveNewChannel(); err != nil {
+				t.Errorf("no new synchronous communication after the close: %v", err)
+			}
+		})
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	tests := []struct {
+		name     string
+		started  bool
+		end      func(agent *Agent, cancel context.CancelFunc)
+		wantPing bool // the message delivered before the end is handled
+	}{
+		{"stop", true, func(agent *Agent, cancel context.CancelFunc) { agent.Stop() }, true},
+		{"death message", true, func(agent *Agent, cancel context.CancelFunc) {
+			agent.Deliver(Messages.Message{Type: Messages.Death}, false)
+		}, true},
+		{"kill", true, func(agent *Agent, cancel context.CancelFunc) { agent.Kill() }, false},
+		{"cancelled", true, func(agent *Agent, cancel context.CancelFunc) { cancel() }, false},
+		{"stop before start", false, func(agent *Agent, cancel context.CancelFunc) { agent.Stop() }, false},
+		{"kill before start", false, func(agent *Agent, cancel context.CancelFunc) { agent.Kill() }, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			behaviour := newRecorder()
+			agent := newTestAgent(7, behaviour)
+			agent.SetScheduling(ReactiveScheduling, 0)
+			deaths := make(chan int, 2)
+			agent.OnDeath = func(agentID int) { deaths <- agentID }
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if test.started {
+				go agent.Start(ctx)
+				waitUntil(t, "the agent runs", func() bool { return agent.State() != Initiated })
+				// suspended, the agent keeps the message in its mailbox until the end
+				agent.Suspend()
+				agent.Deliver(Messages.Text("ping"), false)
+			}
+
+			test.end(agent, cancel)
+			if test.started {
+				agent.Resume()
+			}
+			select {
+			case <-agent.Done():
+			case <-time.After(time.Second):
+				t.Fatal("the agent is still alive")
+			}
+			if state := agent.State(); state != Deleted {
+				t.Errorf("state %v, want %v", state, Deleted)
+			}
+			if handled := len(behaviour.mailbox) == 1; handled != test.wantPing {
+				t.Errorf("message handled %v, want %v", handled, test.wantPing)
+			}
+			if agentID := <-deaths; agentID != 7 {
+				t.Errorf("OnDeath(%d), want OnDeath(7)", agentID)
+			}
+
+			// dead, the agent can not be restarted
+			agent.Start(context.Background())
+			agent.Kill()
+			if len(deaths) != 0 || agent.State() != Deleted {
+				t.Errorf("the agent came back to life: state %v, %d more deaths", agent.State(), len(deaths))
+			}
+		})
+	}
+}
+
+func TestSuspend(t *testing.T) {
+	behaviour := newRecorder()
+	agent := newTestAgent(1, behaviour)
+	agent.SetScheduling(ReactiveScheduling, 0)
+	start(t, agent)
+	waitUntil(t, "the agent waits", func() bool { return agent.State() == Waiting })
+
+	agent.Suspend()
+	if state := agent.State(); state != Suspended {
+		t.Fatalf("state %v after Suspend, want %v", state, Suspended)
+	}
+	agent.Deliver(Messages.Text("ping"), false)
+	select {
+	case <-behaviour.mailbox:
+		t.Fatal("a suspended agent handled a message")
+	case <-time.After(50 * time.Millisecond):
+	}
+	agent.Resume()
+	waitFor(t, behaviour.mailbox, "the message after Resume")
+}
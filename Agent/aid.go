@@ -0,0 +1,30 @@
+package Agent
+
+import "strings"
+
+// AID identifies an agent across the platforms, like a FIPA agent identifier: a name unique on its
+// platform, the name of the platform and the addresses of the container hosting the agent.
+// An agent added without a name is named after its ID.
+type AID struct {
+	Name      string
+	Platform  string
+	Addresses []string
+}
+
+// String returns the globally unique name of the agent, name@platform.
+func (aid AID) String() string {
+	if aid.Platform == "" {
+		return aid.Name
+	}
+	return aid.Name + "@" + aid.Platform
+}
+
+// ParseAID reads a receiver given as an ID, a name or name@platform.
+// The platform is empty when the receiver has none.
+func ParseAID(receiver string) AID {
+	name, platform, found := strings.Cut(receiver, "@")
+	if !found {
+		return AID{Name: receiver}
+	}
+	return AID{Name: name, Platform: platform}
+}
@@ -0,0 +1,247 @@
+package Agent
+
+import (
+	"FrameworkMultiAgents/Messages"
+	"fmt"
+	"log"
+)
+
+// BehaviourState is the state of a behaviour added to an agent.
+type BehaviourState int
+
+const (
+	Runnable BehaviourState = iota // run on every cycle
+	Blocked                        // skipped by the cycle until a message is routed to it or RestartBehaviour is called
+	Finished                       // done, AddBehaviour runs it again
+	Inactive                       // not added to the agent
+)
+
+type activeBehaviour struct {
+	name      string
+	behaviour Behaviour
+	state     BehaviourState
+	filter    Messages.MessageTemplate
+}
+
+func (state BehaviourState) String() string {
+	switch state {
+	case Runnable:
+		return "runnable"
+	case Blocked:
+		return "blocked"
+	case Finished:
+		return "finished"
+	case Inactive:
+		return "inactive"
+	}
+	return "unknown"
+}
+
+func (agent *Agent) RegisterBehaviour(name string, behaviour Behaviour) {
+	agent.AgentBehaviours[name] = behaviour
+}
+
+// SetBehaviour replaces the current behaviour by the registered behaviour name.
+// The current behaviour receives the messages no other behaviour asked for.
+func (agent *Agent) SetBehaviour(name string) {
+	if agent.CurrentBehaviour != nil {
+		agent.deactivate(agent.CurrentBehaviour)
+	}
+	agent.CurrentBehaviour = agent.AgentBehaviours[name]
+	if agent.CurrentBehaviour != nil {
+		agent.AddBehaviour(name)
+	}
+}
+
+// RemoveBehaviour stops the behaviour and forgets its registration.
+func (agent *Agent) RemoveBehaviour(name string) {
+	if behaviour, ok := agent.AgentBehaviours[name]; ok {
+		agent.deactivate(behaviour)
+		if agent.CurrentBehaviour == behaviour {
+			agent.CurrentBehaviour = nil
+		}
+	}
+	delete(agent.AgentBehaviours, name)
+}
+
+// AddBehaviour makes the registered behaviour name run next to the other active behaviours.
+// Adding a finished behaviour runs it again, adding a running one does nothing.
+func (agent *Agent) AddBehaviour(name string) error {
+	behaviour, ok := agent.AgentBehaviours[name]
+	if !ok {
+		return fmt.Errorf("no behaviour registered with the name %s", name)
+	}
+	agent.behavioursMutex.Lock()
+	defer agent.behavioursMutex.Unlock()
+	for _, active := range agent.activeBehaviours {
+		if active.name == name {
+			if active.state == Finished {
+				if resetter, ok := behaviour.(interface{ Reset() }); ok {
+					resetter.Reset()
+				}
+				active.state = Runnable
+			}
+			return nil
+		}
+	}
+	agent.activeBehaviours = append(agent.activeBehaviours, &activeBehaviour{
+		name:      name,
+		behaviour: behaviour,
+		state:     Runnable,
+	})
+	return nil
+}
+
+// BlockBehaviour stops running the behaviour until it receives a message or is restarted.
+func (agent *Agent) BlockBehaviour(name string) error {
+	return agent.setBehaviourState(name, Blocked)
+}
+
+// RestartBehaviour makes a blocked behaviour runnable again.
+func (agent *Agent) RestartBehaviour(name string) error {
+	return agent.setBehaviourState(name, Runnable)
+}
+
+// SetMessageFilter routes to the behaviour the mailbox and synchronous messages accepted by filter.
+// Messages accepted by no filter go to the current behaviour, then to the first behaviour without filter,
+// and are queued for Receive when every behaviour has a filter.
+func (agent *Agent) SetMessageFilter(name string, filter Messages.MessageTemplate) error {
+	agent.behavioursMutex.Lock()
+	defer agent.behavioursMutex.Unlock()
+	active := agent.findBehaviour(name)
+	if active == nil {
+		return fmt.Errorf("behaviour %s is not active", name)
+	}
+	active.filter = filter
+	return nil
+}
+
+// BehaviourState returns the state of the behaviour added under name.
+func (agent *Agent) BehaviourState(name string) BehaviourState {
+	agent.behavioursMutex.Lock()
+	defer agent.behavioursMutex.Unlock()
+	if active := agent.findBehaviour(name); active != nil {
+		return active.state
+	}
+	return Inactive
+}
+
+func (agent *Agent) setBehaviourState(name string, state BehaviourState) error {
+	agent.behavioursMutex.Lock()
+	defer agent.behavioursMutex.Unlock()
+	active := agent.findBehaviour(name)
+	if active == nil || active.state == Finished {
+		return fmt.Errorf("behaviour %s is not active", name)
+	}
+	active.state = state
+	return nil
+}
+
+// findBehaviour must be called with behavioursMutex held.
+func (agent *Agent) findBehaviour(name string) *activeBehaviour {
+	for _, active := range agent.activeBehaviours {
+		if active.name == name {
+			return active
+		}
+	}
+	return nil
+}
+
+func (agent *Agent) deactivate(behaviour Behaviour) {
+	agent.behavioursMutex.Lock()
+	defer agent.behavioursMutex.Unlock()
+	for i, active := range agent.activeBehaviours {
+		if active.behaviour == behaviour {
+			agent.activeBehaviours = append(agent.activeBehaviours[:i], agent.activeBehaviours[i+1:]...)
+			return
+		}
+	}
+}
+
+// runnableBehaviours returns the runnable behaviours starting at the round robin position.
+// The behaviours are called without the lock so they can add or remove behaviours.
+func (agent *Agent) runnableBehaviours() []*activeBehaviour {
+	agent.behavioursMutex.Lock()
+	defer agent.behavioursMutex.Unlock()
+	if len(agent.activeBehaviours) == 0 {
+		return nil
+	}
+	runnable := make([]*activeBehaviour, 0, len(agent.activeBehaviours))
+	for i := range agent.activeBehaviours {
+		active := agent.activeBehaviours[(agent.roundRobin+i)%len(agent.activeBehaviours)]
+		if active.state == Runnable {
+			runnable = append(runnable, active)
+		}
+	}
+	return runnable
+}
+
+func (agent *Agent) rotateBehaviours() {
+	agent.behavioursMutex.Lock()
+	defer agent.behavioursMutex.Unlock()
+	agent.roundRobin++
+	if agent.roundRobin >= len(agent.activeBehaviours) {
+		agent.roundRobin = 0
+	}
+}
+
+// markDoneBehaviours finishes the behaviours whose Done method returns true.
+func (agent *Agent) markDoneBehaviours() {
+	agent.behavioursMutex.Lock()
+	defer agent.behavioursMutex.Unlock()
+	for _, active := range agent.activeBehaviours {
+		if active.state == Finished {
+			continue
+		}
+		if finisher, ok := active.behaviour.(interface{ Done() bool }); ok && finisher.Done() {
+			active.state = Finished
+		}
+	}
+}
+
+// behaviourFor picks the behaviour handling a message and restarts it if it was blocked.
+// It returns nil when no behaviour wants the message.
+func (agent *Agent) behaviourFor(message Messages.Message) Behaviour {
+	agent.behavioursMutex.Lock()
+	defer agent.behavioursMutex.Unlock()
+	var target *activeBehaviour
+	for _, active := range agent.activeBehaviours {
+		if active.state != Finished && active.filter != nil && active.filter(message) {
+			target = active
+			break
+		}
+	}
+	if target == nil {
+		for _, active := range agent.activeBehaviours {
+			if active.state != Finished && active.filter == nil && (target == nil || active.behaviour == agent.CurrentBehaviour) {
+				target = active
+			}
+		}
+	}
+	if target == nil {
+		return nil
+	}
+	if target.state == Blocked {
+		target.state = Runnable
+	}
+	return target.behaviour
+}
+
+func (agent *Agent) handleSyncCommunication(message Messages.Message) {
+	if behaviour := agent.behaviourFor(message); behaviour != nil {
+		behaviour.HandleSyncCommunication(agent, message)
+	} else {
+		log.Printf("Agent %d: no behaviour for the synchronous message from %s, dropped", agent.ID, message.Sender)
+	}
+}
+
+// restartBlockedBehaviours gives the blocked behaviours a chance to Receive a new message.
+func (agent *Agent) restartBlockedBehaviours() {
+	agent.behavioursMutex.Lock()
+	defer agent.behavioursMutex.Unlock()
+	for _, active := range agent.activeBehaviours {
+		if active.state == Blocked {
+			active.state = Runnable
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package Agent
+
+import (
+	"FrameworkMultiAgents/Messages"
+	"strings"
+	"testing"
+)
+
+// finite is a recorder done once it ran its acts, reset by AddBehaviour.
+type finite struct {
+	*recorder
+	left, acts int
+}
+
+func (b *finite) Act(agent *Agent, params ...interface{}) {
+	b.recorder.Act(agent)
+	b.left--
+}
+func (b *finite) Done() bool { return b.left <= 0 }
+func (b *finite) Reset()     { b.left = b.acts }
+
+// names returns the names of the runnable behaviours, in the order they run.
+func names(agent *Agent) string {
+	var runnable []string
+	for _, active := range agent.runnableBehaviours() {
+		runnable = append(runnable, active.name)
+	}
+	return strings.Join(runnable, " ")
+}
+
+func TestMessageRouting(t *testing.T) {
+	fromPing := Messages.MatchSender("ping")
+	tests := []struct {
+		name    string
+		filters map[string]Messages.MessageTemplate // by behaviour, the others have no filter
+		current string
+		sender  string
+		want    string // the behaviour handling the message, empty when none does
+	}{
+		{"filter", map[string]Messages.MessageTemplate{"b": fromPing}, "a", "ping", "b"},
+		{"current behaviour", map[string]Messages.MessageTemplate{"b": fromPing}, "a", "pong", "a"},
+		{"current behaviour without filter", nil, "b", "pong", "b"},
+		{"first behaviour without filter", map[string]Messages.MessageTemplate{"a": fromPing}, "a", "pong", "b"},
+		{"filters only", map[string]Messages.MessageTemplate{"a": fromPing, "b": fromPing, "c": fromPing}, "a", "pong", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			agent := NewAgent(1, AID{}, nil, nil)
+			behaviours := make(map[Behaviour]string)
+			for _, name := range []string{"a", "b", "c"} {
+				behaviour := newRecorder()
+				behaviours[behaviour] = name
+				agent.RegisterBehaviour(name, behaviour)
+				agent.AddBehaviour(name)
+			}
+			agent.SetBehaviour(test.current)
+			for name, filter := range test.filters {
+				if err := agent.SetMessageFilter(name, filter); err != nil {
+					t.Fatal(err)
+				}
+			}
+			message := Messages.Text("ping")
+			message.Sender = test.sender
+			if got := behaviours[agent.behaviourFor(message)]; got != test.want {
+				t.Errorf("handled by %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestBehaviourStates(t *testing.T) {
+	agent := NewAgent(1, AID{}, nil, nil)
+	if got := names(agent); got != "" {
+		t.Fatalf("runnable %q without behaviours", got)
+	}
+	once := &finite{recorder: newRecorder(), left: 1, acts: 1}
+	for name, behaviour := range map[string]Behaviour{"a": newRecorder(), "b": newRecorder(), "once": once} {
+		agent.RegisterBehaviour(name, behaviour)
+	}
+	for _, name := range []string{"a", "b", "once"} {
+		if err := agent.AddBehaviour(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := agent.AddBehaviour("unknown"); err == nil {
+		t.Error("added a behaviour never registered")
+	}
+
+	// round robin between the runnable behaviours
+	if got := names(agent); got != "a b once" {
+		t.Errorf("runnable %q, want a b once", got)
+	}
+	agent.rotateBehaviours()
+	if got := names(agent); got != "b once a" {
+		t.Errorf("after a rotation, runnable %q, want b once a", got)
+	}
+
+	// a blocked behaviour is skipped until a message is routed to it
+	agent.BlockBehaviour("b")
+	if got := names(agent); got != "once a" || agent.BehaviourState("b") != Blocked {
+		t.Errorf("runnable %q with b %v, want once a", got, agent.BehaviourState("b"))
+	}
+	agent.SetMessageFilter("b", Messages.MatchSender("ping"))
+	message := Messages.Text("ping")
+	message.Sender = "ping"
+	agent.behaviourFor(message)
+	if state := agent.BehaviourState("b"); state != Runnable {
+		t.Errorf("b %v after a message, want runnable", state)
+	}
+
+	// a finished behaviour runs again once added again
+	once.Act(agent)
+	agent.markDoneBehaviours()
+	if state := agent.BehaviourState("once"); state != Finished {
+		t.Errorf("once %v after its act, want finished", state)
+	}
+	if err := agent.BlockBehaviour("once"); err == nil {
+		t.Error("blocked a finished behaviour")
+	}
+	agent.AddBehaviour("once")
+	if state := agent.BehaviourState("once"); state != Runnable || once.Done() {
+		t.Errorf("once %v, done %v after AddBehaviour, want runnable and reset", state, once.Done())
+	}
+
+	agent.RemoveBehaviour("a")
+	if state := agent.BehaviourState("a"); state != Inactive {
+		t.Errorf("a %v after RemoveBehaviour, want inactive", state)
+	}
+}
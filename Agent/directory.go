@@ -0,0 +1,76 @@
+package Agent
+
+import (
+	"FrameworkMultiAgents/YellowPage"
+	"fmt"
+	"strconv"
+)
+
+// Directory gives the agents access to the Directory Facilitator of the platform,
+// the container of the agent reaches it on the main container.
+type Directory interface {
+	RegisterService(description YellowPage.AgentDescription) error
+	ModifyService(description YellowPage.AgentDescription) error
+	DeregisterService(agentID string) error
+	SearchServices(template YellowPage.ServiceDescription, maxResults int) ([]YellowPage.AgentDescription, error)
+	SubscribeAgent(agentID string, filter YellowPage.Filter) (string, error)
+	Unsubscribe(subscriptionID string) error
+}
+
+// RegisterServices publishes the services offered by the agent.
+func (agent *Agent) RegisterServices(services ...YellowPage.ServiceDescription) error {
+	if agent.Directory == nil {
+		return fmt.Errorf("agent %d has no directory", agent.ID)
+	}
+	return agent.Directory.RegisterService(agent.description(services))
+}
+
+// ModifyServices replaces the services published by the agent.
+func (agent *Agent) ModifyServices(services ...YellowPage.ServiceDescription) error {
+	if agent.Directory == nil {
+		return fmt.Errorf("agent %d has no directory", agent.ID)
+	}
+	return agent.Directory.ModifyService(agent.description(services))
+}
+
+// DeregisterServices withdraws the services published by the agent.
+func (agent *Agent) DeregisterServices() error {
+	if agent.Directory == nil {
+		return fmt.Errorf("agent %d has no directory", agent.ID)
+	}
+	return agent.Directory.DeregisterService(strconv.Itoa(agent.ID))
+}
+
+// SearchServices returns the agents offering a service that matches template, such as
+// YellowPage.ServiceDescription{Type: "book-selling"}. maxResults <= 0 returns every agent found.
+func (agent *Agent) SearchServices(template YellowPage.ServiceDescription, maxResults int) ([]YellowPage.AgentDescription, error) {
+	if agent.Directory == nil {
+		return nil, fmt.Errorf("agent %d has no directory", agent.ID)
+	}
+	return agent.Directory.SearchServices(template, maxResults)
+}
+
+// Subscribe asks for the changes of the directory selected by filter, such as the agents offering
+// a service of a given type, to be sent to the mailbox of the agent as Messages.RegistryEvent messages.
+// It returns the ID of the subscription, the ConversationID of the messages.
+func (agent *Agent) Subscribe(filter YellowPage.Filter) (string, error) {
+	if agent.Directory == nil {
+		return "", fmt.Errorf("agent %d has no directory", agent.ID)
+	}
+	return agent.Directory.SubscribeAgent(strconv.Itoa(agent.ID), filter)
+}
+
+// Unsubscribe ends a subscription of the agent.
+func (agent *Agent) Unsubscribe(subscriptionID string) error {
+	if agent.Directory == nil {
+		return fmt.Errorf("agent %d has no directory", agent.ID)
+	}
+	return agent.Directory.Unsubscribe(subscriptionID)
+}
+
+func (agent *Agent) description(services []YellowPage.ServiceDescription) YellowPage.AgentDescription {
+	return YellowPage.AgentDescription{
+		AgentID:  strconv.Itoa(agent.ID),
+		Services: services,
+	}
+}
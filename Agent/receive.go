@@ -0,0 +1,145 @@
+package Agent
+
+import (
+	"FrameworkMultiAgents/Messages"
+	"context"
+	"log"
+	"time"
+)
+
+// MaxQueuedMessages bounds the messages kept for Receive, and those a Receive read without taking them.
+// Past it the oldest one is dropped, see DroppedMessages.
+var MaxQueuedMessages = 1000
+
+// Receive returns the first message matching template. The messages already queued are looked at first,
+// then the mailbox is read for at most timeout; a timeout of zero does not wait.
+// Messages that do not match are handed to the behaviours as usual once the behaviour calling Receive
+// returns, and are visible to the next calls until then.
+// Receive is meant to be called by the behaviours of the agent, from the agent's own cycle. Such behaviours
+// should have a message filter (see SetMessageFilter), else the mailbox hands them the messages first.
+func (agent *Agent) Receive(template Messages.MessageTemplate, timeout time.Duration) (Messages.Message, bool) {
+	if message, ok := agent.takeQueued(template); ok {
+		return message, true
+	}
+	if timeout <= 0 {
+		for waiting := len(agent.MailBox); waiting > 0; waiting-- {
+			if message, ok := agent.receiveOne(<-agent.MailBox, template); ok {
+				return message, true
+			}
+		}
+		return Messages.Message{}, false
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	return agent.waitMessage(template, timer.C)
+}
+
+// BlockingReceive waits until a message matches template. It returns false if the agent dies meanwhile.
+// On a worker pool the agent keeps its worker while it waits.
+func (agent *Agent) BlockingReceive(template Messages.MessageTemplate) (Messages.Message, bool) {
+	if message, ok := agent.takeQueued(template); ok {
+		return message, true
+	}
+	return agent.waitMessage(template, nil)
+}
+
+// QueuedMessages returns the number of messages waiting for a Receive.
+func (agent *Agent) QueuedMessages() int {
+	agent.queueMutex.Lock()
+	defer agent.queueMutex.Unlock()
+	return len(agent.queue)
+}
+
+// DroppedMessages returns the number of messages dropped because MaxQueuedMessages was reached.
+func (agent *Agent) DroppedMessages() int64 {
+	return agent.dropped.Load()
+}
+
+func (agent *Agent) waitMessage(template Messages.MessageTemplate, timeout <-chan time.Time) (Messages.Message, bool) {
+	ctx := agent.context()
+	for {
+		select {
+		case message := <-agent.MailBox:
+			if message, ok := agent.receiveOne(message, template); ok {
+				return message, true
+			}
+		case <-timeout:
+			return Messages.Message{}, false
+		case <-ctx.Done():
+			return Messages.Message{}, false
+		}
+	}
+}
+
+// receiveOne returns the message if it matches, defers it otherwise.
+// A Death message kills the agent, the cycle notices it after the current behaviour returns.
+func (agent *Agent) receiveOne(message Messages.Message, template Messages.MessageTemplate) (Messages.Message, bool) {
+	if message.Type == Messages.Death {
+		agent.Kill()
+		return Messages.Message{}, false
+	}
+	if template.Match(message) {
+		return message, true
+	}
+	agent.queueMutex.Lock()
+	agent.deferred = agent.keep(agent.deferred, message)
+	agent.queueMutex.Unlock()
+	return Messages.Message{}, false
+}
+
+func (agent *Agent) takeQueued(template Messages.MessageTemplate) (Messages.Message, bool) {
+	agent.queueMutex.Lock()
+	defer agent.queueMutex.Unlock()
+	if message, ok := take(&agent.queue, template); ok {
+		return message, true
+	}
+	return take(&agent.deferred, template)
+}
+
+func take(messages *[]Messages.Message, template Messages.MessageTemplate) (Messages.Message, bool) {
+	for i, message := range *messages {
+		if template.Match(message) {
+			*messages = append((*messages)[:i], (*messages)[i+1:]...)
+			return message, true
+		}
+	}
+	return Messages.Message{}, false
+}
+
+// dispatchDeferred hands the behaviours the messages a Receive read without taking them.
+func (agent *Agent) dispatchDeferred() {
+	agent.queueMutex.Lock()
+	deferred := agent.deferred
+	agent.deferred = nil
+	agent.queueMutex.Unlock()
+	for _, message := range deferred {
+		agent.handleMailboxMessage(message)
+	}
+}
+
+func (agent *Agent) enqueue(message Messages.Message) {
+	agent.queueMutex.Lock()
+	defer agent.queueMutex.Unlock()
+	agent.queue = agent.keep(agent.queue, message)
+}
+
+// keep appends the message, dropping the oldest one past MaxQueuedMessages.
+// It must be called with queueMutex held.
+func (agent *Agent) keep(messages []Messages.Message, message Messages.Message) []Messages.Message {
+	if len(messages) >= MaxQueuedMessages {
+		dropped := messages[0]
+		agent.dropped.Add(1)
+		log.Printf("Agent %d: message queue full, dropped the message of type %d from %s (%d dropped)", agent.ID, dropped.Type, dropped.Sender, agent.dropped.Load())
+		messages = messages[1:]
+	}
+	return append(messages, message)
+}
+
+func (agent *Agent) context() context.Context {
+	agent.stateMutex.Lock()
+	defer agent.stateMutex.Unlock()
+	if agent.ctx == nil {
+		return context.Background()
+	}
+	return agent.ctx
+}
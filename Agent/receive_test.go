@@ -0,0 +1,107 @@
+package Agent
+
+import (
+	"FrameworkMultiAgents/Messages"
+	"context"
+	"testing"
+	"time"
+)
+
+// acting is a recorder calling act on each cycle.
+type acting struct {
+	*recorder
+	act func(agent *Agent)
+}
+
+func (b *acting) Act(agent *Agent, params ...interface{}) { b.act(agent) }
+
+// from returns a text message sent by sender.
+func from(sender string) Messages.Message {
+	message := Messages.Text(sender)
+	message.Sender = sender
+	return message
+}
+
+func TestReceive(t *testing.T) {
+	agent := NewAgent(1, AID{}, nil, nil)
+	agent.Deliver(from("a"), false)
+	agent.Deliver(from("b"), false)
+
+	if message, ok := agent.Receive(Messages.MatchSender("b"), 0); !ok || message.Sender != "b" {
+		t.Fatalf("Receive(b) = %v, %v", message.Sender, ok)
+	}
+	// read without being taken, a is kept for the next calls
+	if message, ok := agent.Receive(Messages.MatchSender("a"), 0); !ok || message.Sender != "a" {
+		t.Fatalf("Receive(a) = %v, %v", message.Sender, ok)
+	}
+	if _, ok := agent.Receive(nil, 0); ok {
+		t.Fatal("Receive without messages returned one")
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		agent.Deliver(from("c"), false)
+	}()
+	if message, ok := agent.Receive(Messages.MatchSender("c"), time.Second); !ok || message.Sender != "c" {
+		t.Fatalf("Receive(c) with a timeout = %v, %v", message.Sender, ok)
+	}
+	start := time.Now()
+	if _, ok := agent.Receive(nil, 20*time.Millisecond); ok || time.Since(start) < 20*time.Millisecond {
+		t.Errorf("Receive returned %v after %v, want false after the timeout", ok, time.Since(start))
+	}
+}
+
+func TestQueuedMessages(t *testing.T) {
+	defer func(max int) { MaxQueuedMessages = max }(MaxQueuedMessages)
+	MaxQueuedMessages = 2
+
+	behaviour := newRecorder()
+	agent := newTestAgent(1, behaviour)
+	// a behaviour with a filter only, the other messages wait for a Receive
+	agent.SetMessageFilter("main", Messages.MatchSender("nobody"))
+	agent.SetScheduling(ReactiveScheduling, 0)
+	start(t, agent)
+	for _, sender := range []string{"a", "b", "c"} {
+		agent.Deliver(from(sender), false)
+	}
+	waitUntil(t, "the messages are queued", func() bool { return agent.QueuedMessages() == 2 })
+	if dropped := agent.DroppedMessages(); dropped != 1 {
+		t.Errorf("%d messages dropped, want 1", dropped)
+	}
+	if message, ok := agent.takeQueued(nil); !ok || message.Sender != "b" {
+		t.Errorf("oldest queued message from %q, want b", message.Sender)
+	}
+}
+
+func TestBlockingReceive(t *testing.T) {
+	agent := NewAgent(1, AID{}, nil, nil)
+	agent.Deliver(from("a"), false)
+	if message, ok := agent.BlockingReceive(nil); !ok || message.Sender != "a" {
+		t.Fatalf("BlockingReceive = %v, %v", message.Sender, ok)
+	}
+
+	// the agent dies while waiting
+	blocked, received := make(chan struct{}, 1), make(chan bool, 1)
+	waiting := &acting{recorder: newRecorder(), act: func(agent *Agent) {
+		blocked <- struct{}{}
+		if _, ok := agent.BlockingReceive(Messages.MatchSender("never")); !ok {
+			received <- ok
+		}
+	}}
+	agent = newTestAgent(2, waiting)
+	agent.SetScheduling(MixedScheduling, time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	go agent.Start(ctx)
+	select {
+	case <-blocked:
+	case <-time.After(time.Second):
+		t.Fatal("the agent did not run its behaviour")
+	}
+	cancel()
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("BlockingReceive still waits after the death of the agent")
+	}
+	<-agent.Done()
+}
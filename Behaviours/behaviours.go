@@ -0,0 +1,525 @@
+package Behaviours
+
+import (
+	"FrameworkMultiAgents/Agent"
+	"FrameworkMultiAgents/Messages"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Ready-made behaviours built on Agent.Behaviour. They do their work in Act, so the timing
+// of Ticker and Waker can not be finer than the scheduling of the agent running them.
+
+// Finisher is implemented by behaviours that can end. Behaviours that do not implement it never end.
+type Finisher interface {
+	Done() bool
+}
+
+// Ender gives the exit code of a finished behaviour, it drives the transitions of an FSM.
+type Ender interface {
+	OnEnd() int
+}
+
+// Resetter is implemented by behaviours that can be run again from the start.
+type Resetter interface {
+	Reset()
+}
+
+func isDone(behaviour Agent.Behaviour) bool {
+	if finisher, ok := behaviour.(Finisher); ok {
+		return finisher.Done()
+	}
+	return false
+}
+
+func exitCode(behaviour Agent.Behaviour) int {
+	if ender, ok := behaviour.(Ender); ok {
+		return ender.OnEnd()
+	}
+	return 0
+}
+
+func reset(behaviour Agent.Behaviour) {
+	if resetter, ok := behaviour.(Resetter); ok {
+		resetter.Reset()
+	}
+}
+
+// Base gives empty implementations of the Agent.Behaviour methods and stores the exit code.
+// Embed it to write only the methods you need.
+type Base struct {
+	exitCode int
+}
+
+func (b *Base) Perceive(agent *Agent.Agent, params ...interface{})                   {}
+func (b *Base) Decide(agent *Agent.Agent, params ...interface{})                     {}
+func (b *Base) Act(agent *Agent.Agent, params ...interface{})                        {}
+func (b *Base) HandleMailboxMessage(agent *Agent.Agent, message Messages.Message)    {}
+func (b *Base) HandleSyncCommunication(agent *Agent.Agent, message Messages.Message) {}
+
+// SetExitCode sets the value returned by OnEnd.
+func (b *Base) SetExitCode(code int) {
+	b.exitCode = code
+}
+
+func (b *Base) OnEnd() int {
+	return b.exitCode
+}
+
+// OneShot runs its action once.
+type OneShot struct {
+	Base
+	action func(agent *Agent.Agent)
+	done   bool
+}
+
+func NewOneShot(action func(agent *Agent.Agent)) *OneShot {
+	return &OneShot{action: action}
+}
+
+func (b *OneShot) Act(agent *Agent.Agent, params ...interface{}) {
+	if b.done {
+		return
+	}
+	b.action(agent)
+	b.done = true
+}
+
+func (b *OneShot) Done() bool {
+	return b.done
+}
+
+func (b *OneShot) Reset() {
+	b.done = false
+}
+
+// Cyclic runs its action on every Act and never ends.
+type Cyclic struct {
+	Base
+	action func(agent *Agent.Agent)
+}
+
+func NewCyclic(action func(agent *Agent.Agent)) *Cyclic {
+	return &Cyclic{action: action}
+}
+
+func (b *Cyclic) Act(agent *Agent.Agent, params ...interface{}) {
+	b.action(agent)
+}
+
+// Ticker runs onTick every period until Stop is called.
+type Ticker struct {
+	Base
+	period  time.Duration
+	onTick  func(agent *Agent.Agent)
+	lastRun time.Time
+	stopped bool
+}
+
+func NewTicker(period time.Duration, onTick func(agent *Agent.Agent)) *Ticker {
+	return &Ticker{period: period, onTick: onTick}
+}
+
+func (b *Ticker) Act(agent *Agent.Agent, params ...interface{}) {
+	if b.stopped {
+		return
+	}
+	now := time.Now()
+	if b.lastRun.IsZero() {
+		// the first period starts with the first Act
+		b.lastRun = now
+		return
+	}
+	if now.Sub(b.lastRun) >= b.period {
+		b.lastRun = now
+		b.onTick(agent)
+	}
+}
+
+// Stop ends the ticker, onTick is not called anymore.
+func (b *Ticker) Stop() {
+	b.stopped = true
+}
+
+func (b *Ticker) Done() bool {
+	return b.stopped
+}
+
+func (b *Ticker) Reset() {
+	b.lastRun = time.Time{}
+	b.stopped = false
+}
+
+// Waker runs onWake once, delay after its first Act.
+type Waker struct {
+	Base
+	delay   time.Duration
+	onWake  func(agent *Agent.Agent)
+	started time.Time
+	done    bool
+}
+
+func NewWaker(delay time.Duration, onWake func(agent *Agent.Agent)) *Waker {
+	return &Waker{delay: delay, onWake: onWake}
+}
+
+func (b *Waker) Act(agent *Agent.Agent, params ...interface{}) {
+	if b.done {
+		return
+	}
+	if b.started.IsZero() {
+		b.started = time.Now()
+	}
+	if time.Since(b.started) >= b.delay {
+		b.onWake(agent)
+		b.done = true
+	}
+}
+
+func (b *Waker) Done() bool {
+	return b.done
+}
+
+func (b *Waker) Reset() {
+	b.started = time.Time{}
+	b.done = false
+}
+
+// Sequential runs its children one after the other and ends with the last one.
+// Messages go to the running child. OnEnd returns the exit code of the last child.
+type Sequential struct {
+	Base
+	children []Agent.Behaviour
+	current  int
+}
+
+func NewSequential(children ...Agent.Behaviour) *Sequential {
+	return &Sequential{children: children}
+}
+
+// AddChild appends a behaviour to the sequence.
+func (b *Sequential) AddChild(child Agent.Behaviour) {
+	b.children = append(b.children, child)
+}
+
+func (b *Sequential) running() Agent.Behaviour {
+	if b.current < len(b.children) {
+		return b.children[b.current]
+	}
+	return nil
+}
+
+func (b *Sequential) Perceive(agent *Agent.Agent, params ...interface{}) {
+	if child := b.running(); child != nil {
+		child.Perceive(agent, params...)
+	}
+}
+
+func (b *Sequential) Decide(agent *Agent.Agent, params ...interface{}) {
+	if child := b.running(); child != nil {
+		child.Decide(agent, params...)
+	}
+}
+
+func (b *Sequential) Act(agent *Agent.Agent, params ...interface{}) {
+	child := b.running()
+	if child == nil {
+		return
+	}
+	child.Act(agent, params...)
+	if isDone(child) {
+		b.SetExitCode(exitCode(child))
+		b.current++
+	}
+}
+
+func (b *Sequential) HandleMailboxMessage(agent *Agent.Agent, message Messages.Message) {
+	if child := b.running(); child != nil {
+		child.HandleMailboxMessage(agent, message)
+	}
+}
+
+func (b *Sequential) HandleSyncCommunication(agent *Agent.Agent, message Messages.Message) {
+	if child := b.running(); child != nil {
+		child.HandleSyncCommunication(agent, message)
+	}
+}
+
+func (b *Sequential) Done() bool {
+	return b.current >= len(b.children)
+}
+
+func (b *Sequential) Reset() {
+	for _, child := range b.children {
+		reset(child)
+	}
+	b.current = 0
+	b.SetExitCode(0)
+}
+
+// ParallelTermination tells when a Parallel behaviour ends.
+type ParallelTermination int
+
+const (
+	WhenAll ParallelTermination = iota // every child is done
+	WhenAny                            // one child is done
+)
+
+// Parallel runs all its unfinished children on every cycle.
+// Messages go to every unfinished child.
+type Parallel struct {
+	Base
+	termination ParallelTermination
+	children    []Agent.Behaviour
+	done        bool
+}
+
+func NewParallel(termination ParallelTermination, children ...Agent.Behaviour) *Parallel {
+	return &Parallel{termination: termination, children: children}
+}
+
+// AddChild adds a behaviour run in parallel with the others.
+func (b *Parallel) AddChild(child Agent.Behaviour) {
+	b.children = append(b.children, child)
+}
+
+func (b *Parallel) each(run func(child Agent.Behaviour)) {
+	if b.done {
+		return
+	}
+	for _, child := range b.children {
+		if !isDone(child) {
+			run(child)
+		}
+	}
+}
+
+func (b *Parallel) Perceive(agent *Agent.Agent, params ...interface{}) {
+	b.each(func(child Agent.Behaviour) { child.Perceive(agent, params...) })
+}
+
+func (b *Parallel) Decide(agent *Agent.Agent, params ...interface{}) {
+	b.each(func(child Agent.Behaviour) { child.Decide(agent, params...) })
+}
+
+func (b *Parallel) Act(agent *Agent.Agent, params ...interface{}) {
+	b.each(func(child Agent.Behaviour) { child.Act(agent, params...) })
+	if b.done {
+		return
+	}
+	finished := 0
+	for _, child := range b.children {
+		if isDone(child) {
+			finished++
+			if b.termination == WhenAny {
+				b.SetExitCode(exitCode(child))
+				b.done = true
+				return
+			}
+		}
+	}
+	b.done = b.termination == WhenAll && finished == len(b.children)
+}
+
+func (b *Parallel) HandleMailboxMessage(agent *Agent.Agent, message Messages.Message) {
+	b.each(func(child Agent.Behaviour) { child.HandleMailboxMessage(agent, message) })
+}
+
+func (b *Parallel) HandleSyncCommunication(agent *Agent.Agent, message Messages.Message) {
+	b.each(func(child Agent.Behaviour) { child.HandleSyncCommunication(agent, message) })
+}
+
+func (b *Parallel) Done() bool {
+	return b.done
+}
+
+func (b *Parallel) Reset() {
+	for _, child := range b.children {
+		reset(child)
+	}
+	b.done = false
+	b.SetExitCode(0)
+}
+
+// FSM is a finite state machine whose states are behaviours. When the behaviour of a state is done,
+// its exit code (see Ender) selects the transition to the next state. The FSM ends when a last state is done.
+type FSM struct {
+	Base
+	states      map[string]Agent.Behaviour
+	lastStates  map[string]bool
+	transitions map[string]map[int]string
+	defaults    map[string]string
+	first       string
+	current     string
+	done        bool
+	err         error
+	agent       *Agent.Agent // running the FSM, resolves the states registered with a nil behaviour
+}
+
+// ErrNoTransition ends an FSM whose state ended with an exit code no transition handles, see FSM.Err.
+var ErrNoTransition = errors.New("FSM: no transition")
+
+// ErrUnknownState ends an FSM that enters a state neither the FSM nor its agent registered, see FSM.Err.
+var ErrUnknownState = errors.New("FSM: unknown state")
+
+func NewFSM() *FSM {
+	return &FSM{
+		states:      make(map[string]Agent.Behaviour),
+		lastStates:  make(map[string]bool),
+		transitions: make(map[string]map[int]string),
+		defaults:    make(map[string]string),
+	}
+}
+
+// RegisterState adds a state. With a nil behaviour the state runs the behaviour registered
+// under the same name in the agent (Agent.RegisterBehaviour).
+func (b *FSM) RegisterState(name string, behaviour Agent.Behaviour) {
+	b.states[name] = behaviour
+}
+
+func (b *FSM) RegisterFirstState(name string, behaviour Agent.Behaviour) {
+	b.RegisterState(name, behaviour)
+	b.first = name
+	if b.current == "" {
+		b.current = name
+	}
+}
+
+func (b *FSM) RegisterLastState(name string, behaviour Agent.Behaviour) {
+	b.RegisterState(name, behaviour)
+	b.lastStates[name] = true
+}
+
+// RegisterTransition goes from "from" to "to" when "from" ends with the exit code event.
+func (b *FSM) RegisterTransition(from, to string, event int) {
+	if _, ok := b.transitions[from]; !ok {
+		b.transitions[from] = make(map[int]string)
+	}
+	b.transitions[from][event] = to
+}
+
+// RegisterDefaultTransition goes from "from" to "to" when no other transition matches the exit code.
+func (b *FSM) RegisterDefaultTransition(from, to string) {
+	b.defaults[from] = to
+}
+
+// CurrentState returns the name of the running state.
+func (b *FSM) CurrentState() string {
+	return b.current
+}
+
+// Err returns an error wrapping ErrNoTransition or ErrUnknownState when the FSM ended outside of a last state,
+// nil otherwise.
+func (b *FSM) Err() error {
+	return b.err
+}
+
+// fail ends the FSM with the error returned by Err.
+func (b *FSM) fail(err error) {
+	b.err = err
+	log.Print(err)
+	b.done = true
+}
+
+func (b *FSM) state(agent *Agent.Agent) Agent.Behaviour {
+	b.agent = agent
+	if b.done || b.current == "" {
+		return nil
+	}
+	return b.behaviour(b.current)
+}
+
+// behaviour returns the behaviour of a state, nil for a state registered with a nil behaviour
+// before the FSM knows its agent.
+func (b *FSM) behaviour(name string) Agent.Behaviour {
+	if behaviour := b.states[name]; behaviour != nil {
+		return behaviour
+	}
+	if b.agent == nil {
+		return nil
+	}
+	return b.agent.AgentBehaviours[name]
+}
+
+func (b *FSM) Perceive(agent *Agent.Agent, params ...interface{}) {
+	if state := b.state(agent); state != nil {
+		state.Perceive(agent, params...)
+	}
+}
+
+func (b *FSM) Decide(agent *Agent.Agent, params ...interface{}) {
+	if state := b.state(agent); state != nil {
+		state.Decide(agent, params...)
+	}
+}
+
+func (b *FSM) Act(agent *Agent.Agent, params ...interface{}) {
+	state := b.state(agent)
+	if state == nil {
+		switch {
+		case b.done:
+		case b.current == "":
+			b.fail(fmt.Errorf("%w: no first state", ErrUnknownState))
+		default:
+			b.fail(fmt.Errorf("%w: %s", ErrUnknownState, b.current))
+		}
+		return
+	}
+	state.Act(agent, params...)
+	if !isDone(state) {
+		return
+	}
+	code := exitCode(state)
+	b.SetExitCode(code)
+	if b.lastStates[b.current] {
+		b.done = true
+		return
+	}
+	next, ok := b.transitions[b.current][code]
+	if !ok {
+		next, ok = b.defaults[b.current]
+	}
+	if !ok {
+		b.fail(fmt.Errorf("%w from state %s with exit code %d", ErrNoTransition, b.current, code))
+		return
+	}
+	if b.behaviour(next) == nil {
+		b.fail(fmt.Errorf("%w: %s, from state %s with exit code %d", ErrUnknownState, next, b.current, code))
+		return
+	}
+	b.current = next
+	if state := b.state(agent); state != nil {
+		// a state can be entered many times
+		reset(state)
+	}
+}
+
+func (b *FSM) HandleMailboxMessage(agent *Agent.Agent, message Messages.Message) {
+	if state := b.state(agent); state != nil {
+		state.HandleMailboxMessage(agent, message)
+	}
+}
+
+func (b *FSM) HandleSyncCommunication(agent *Agent.Agent, message Messages.Message) {
+	if state := b.state(agent); state != nil {
+		state.HandleSyncCommunication(agent, message)
+	}
+}
+
+func (b *FSM) Done() bool {
+	return b.done
+}
+
+func (b *FSM) Reset() {
+	for name := range b.states {
+		if state := b.behaviour(name); state != nil {
+			reset(state)
+		}
+	}
+	b.current = b.first
+	b.done = false
+	b.err = nil
+	b.SetExitCode(0)
+}
@@ -0,0 +1,174 @@
+package Behaviours
+
+import (
+	"FrameworkMultiAgents/Agent"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// countdown ends with its exit code after n Acts.
+type countdown struct {
+	Base
+	n int
+}
+
+func newCountdown(n, code int) *countdown {
+	b := &countdown{n: n}
+	b.SetExitCode(code)
+	return b
+}
+
+func (b *countdown) Act(agent *Agent.Agent, params ...interface{}) {
+	if b.n > 0 {
+		b.n--
+	}
+}
+
+func (b *countdown) Done() bool {
+	return b.n == 0
+}
+
+// run calls the cycle of the behaviour until it is done, at most max times, and returns the number of cycles.
+func run(behaviour Agent.Behaviour, agent *Agent.Agent, max int) int {
+	cycles := 0
+	for ; cycles < max && !isDone(behaviour); cycles++ {
+		behaviour.Perceive(agent)
+		behaviour.Decide(agent)
+		behaviour.Act(agent)
+	}
+	return cycles
+}
+
+func TestComposites(t *testing.T) {
+	tests := []struct {
+		name       string
+		behaviour  func() Agent.Behaviour
+		wantCycles int
+		wantCode   int
+	}{
+		{"one shot", func() Agent.Behaviour { return NewOneShot(func(*Agent.Agent) {}) }, 1, 0},
+		{"sequential", func() Agent.Behaviour {
+			return NewSequential(newCountdown(1, 1), newCountdown(2, 2))
+		}, 3, 2},
+		{"empty sequential", func() Agent.Behaviour { return NewSequential() }, 0, 0},
+		{"parallel when all", func() Agent.Behaviour {
+			return NewParallel(WhenAll, newCountdown(1, 1), newCountdown(3, 3))
+		}, 3, 0},
+		{"parallel when any", func() Agent.Behaviour {
+			return NewParallel(WhenAny, newCountdown(3, 3), newCountdown(2, 2))
+		}, 2, 2},
+	}
+	agent := Agent.NewAgent(1, Agent.AID{}, nil, nil)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			behaviour := test.behaviour()
+			if cycles := run(behaviour, agent, 10); cycles != test.wantCycles {
+				t.Errorf("done after %d cycles, want %d", cycles, test.wantCycles)
+			}
+			if code := exitCode(behaviour); code != test.wantCode {
+				t.Errorf("exit code %d, want %d", code, test.wantCode)
+			}
+		})
+	}
+}
+
+func TestFSM(t *testing.T) {
+	tests := []struct {
+		name      string
+		setup     func(fsm *FSM, agent *Agent.Agent, state func(name string, code int) Agent.Behaviour)
+		wantTrace string
+		wantErr   error
+	}{
+		{"transition on the exit code", func(fsm *FSM, agent *Agent.Agent, state func(string, int) Agent.Behaviour) {
+			fsm.RegisterFirstState("A", state("A", 2))
+			fsm.RegisterState("B", state("B", 0))
+			fsm.RegisterLastState("C", state("C", 0))
+			fsm.RegisterTransition("A", "B", 1)
+			fsm.RegisterTransition("A", "C", 2)
+		}, "A C", nil},
+		{"default transition", func(fsm *FSM, agent *Agent.Agent, state func(string, int) Agent.Behaviour) {
+			fsm.RegisterFirstState("A", state("A", 7))
+			fsm.RegisterLastState("B", state("B", 0))
+			fsm.RegisterTransition("A", "A", 1)
+			fsm.RegisterDefaultTransition("A", "B")
+		}, "A B", nil},
+		{"state of the agent", func(fsm *FSM, agent *Agent.Agent, state func(string, int) Agent.Behaviour) {
+			fsm.RegisterFirstState("A", state("A", 0))
+			fsm.RegisterLastState("B", nil)
+			agent.RegisterBehaviour("B", state("B", 0))
+			fsm.RegisterTransition("A", "B", 0)
+		}, "A B", nil},
+		{"no transition", func(fsm *FSM, agent *Agent.Agent, state func(string, int) Agent.Behaviour) {
+			fsm.RegisterFirstState("A", state("A", 3))
+			fsm.RegisterLastState("B", state("B", 0))
+			fsm.RegisterTransition("A", "B", 1)
+		}, "A", ErrNoTransition},
+		{"transition to an unknown state", func(fsm *FSM, agent *Agent.Agent, state func(string, int) Agent.Behaviour) {
+			fsm.RegisterFirstState("A", state("A", 0))
+			fsm.RegisterTransition("A", "missing", 0)
+		}, "A", ErrUnknownState},
+		{"unknown state of the agent", func(fsm *FSM, agent *Agent.Agent, state func(string, int) Agent.Behaviour) {
+			fsm.RegisterFirstState("A", nil)
+		}, "", ErrUnknownState},
+		{"no first state", func(fsm *FSM, agent *Agent.Agent, state func(string, int) Agent.Behaviour) {
+			fsm.RegisterLastState("A", state("A", 0))
+		}, "", ErrUnknownState},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var trace []string
+			state := func(name string, code int) Agent.Behaviour {
+				b := NewOneShot(func(*Agent.Agent) { trace = append(trace, name) })
+				b.SetExitCode(code)
+				return b
+			}
+			agent := Agent.NewAgent(1, Agent.AID{}, nil, nil)
+			fsm := NewFSM()
+			test.setup(fsm, agent, state)
+
+			run(fsm, agent, 10)
+			if !fsm.Done() {
+				t.Fatal("the FSM did not end")
+			}
+			if got := strings.Join(trace, " "); got != test.wantTrace {
+				t.Errorf("ran %q, want %q", got, test.wantTrace)
+			}
+			if err := fsm.Err(); !errors.Is(err, test.wantErr) || (err == nil) != (test.wantErr == nil) {
+				t.Errorf("Err() = %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestFSMEntersAStateAgain(t *testing.T) {
+	runs := 0
+	var loop *OneShot
+	loop = NewOneShot(func(*Agent.Agent) {
+		runs++
+		if runs == 3 {
+			loop.SetExitCode(1)
+		}
+	})
+	fsm := NewFSM()
+	fsm.RegisterFirstState("loop", loop)
+	fsm.RegisterLastState("end", newCountdown(1, 0))
+	fsm.RegisterTransition("loop", "loop", 0)
+	fsm.RegisterTransition("loop", "end", 1)
+	agent := Agent.NewAgent(1, Agent.AID{}, nil, nil)
+
+	if cycles := run(fsm, agent, 10); cycles != 4 || runs != 3 {
+		t.Errorf("done after %d cycles and %d runs of the loop, want 4 and 3", cycles, runs)
+	}
+
+	// Reset runs the FSM again from its first state, with every state reset
+	runs = 0
+	loop.SetExitCode(0)
+	fsm.Reset()
+	if fsm.Done() || fsm.CurrentState() != "loop" {
+		t.Fatalf("after Reset: done %v in state %s", fsm.Done(), fsm.CurrentState())
+	}
+	if cycles := run(fsm, agent, 10); cycles != 4 || runs != 3 {
+		t.Errorf("after Reset: done after %d cycles and %d runs of the loop, want 4 and 3", cycles, runs)
+	}
+}
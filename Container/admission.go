@@ -0,0 +1,179 @@
+package Container
+
+import (
+	"FrameworkMultiAgents/Messages"
+	"FrameworkMultiAgents/NetworkService"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// ErrNotAdmitted is answered by the main container to the containers its admission policy refuses.
+var ErrNotAdmitted = errors.New("container not admitted")
+
+// ErrAgentQuota is answered by the main container when a container already hosts as many agents as allowed.
+var ErrAgentQuota = errors.New("agent quota reached")
+
+// AdmissionPolicy sets which containers the main container registers, and how many agents they host.
+// The zero value admits every container. The addresses are the identifiers of the connections of the
+// containers: combined with mutual TLS, see NetworkService.NewTLSTransport, they are bound to the
+// certificates of the containers. The standby main containers present the same credentials.
+type AdmissionPolicy struct {
+	Tokens           []string // join tokens or pre-shared keys, one of which the containers must present, see WithJoinToken
+	MaxContainers    int      // registered at once, the main container excluded, 0 for no limit
+	AllowedAddresses []string // addresses, hosts or CIDR blocks the containers may register from, any when empty
+	MaxAgents        int      // per container, 0 for no limit
+	// Admit, when set, is asked last whether the container at address may register.
+	Admit func(address string) error
+}
+
+// admission enforces the admission policy of the main container.
+type admission struct {
+	policy AdmissionPolicy
+	mutex  sync.Mutex // held from the checks to the registration, so that the limits hold
+}
+
+// admit returns an error wrapping ErrNotAdmitted unless the container may register.
+func (admission *admission) admit(MainContainer *MainContainer, payload Messages.RegisterContainerPayload) error {
+	if payload.Address == MainContainer.localAdress {
+		return fmt.Errorf("%w: %s is the main container", ErrNotAdmitted, payload.Address)
+	}
+	if err := admission.credentials(payload.Address, payload.Token); err != nil {
+		return err
+	}
+	// a container registering again keeps its place
+	registered := MainContainer.yellowPage.IsContainerRegistered(payload.Address)
+	if max := admission.policy.MaxContainers; !registered && max > 0 && MainContainer.yellowPage.ContainerCount()-1 >= max {
+		return fmt.Errorf("%w: %s: %d containers already registered", ErrNotAdmitted, payload.Address, max)
+	}
+	return nil
+}
+
+// credentials returns an error wrapping ErrNotAdmitted unless the policy accepts the token and the address.
+func (admission *admission) credentials(address, token string) error {
+	policy := admission.policy
+	if len(policy.Tokens) > 0 && !validToken(policy.Tokens, token) {
+		return fmt.Errorf("%w: %s: invalid join token", ErrNotAdmitted, address)
+	}
+	if len(policy.AllowedAddresses) > 0 && !allowedAddress(policy.AllowedAddresses, address) {
+		return fmt.Errorf("%w: %s: address not allowed", ErrNotAdmitted, address)
+	}
+	if policy.Admit != nil {
+		if err := policy.Admit(address); err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrNotAdmitted, address, err)
+		}
+	}
+	return nil
+}
+
+// admitted returns the container a request comes from, with an error wrapping ErrNotAdmitted
+// unless it is registered. No other container may act as the main container.
+func (MainContainer *MainContainer) admitted(ctx context.Context) (string, error) {
+	peer, ok := NetworkService.Peer(ctx)
+	if !ok || peer == MainContainer.localAdress || !MainContainer.yellowPage.IsContainerRegistered(peer) {
+		return peer, fmt.Errorf("%w: %s is not registered", ErrNotAdmitted, peer)
+	}
+	return peer, nil
+}
+
+// validToken compares the token to each accepted one in constant time.
+func validToken(tokens []string, token string) bool {
+	valid := 0
+	for _, accepted := range tokens {
+		valid |= subtle.ConstantTimeCompare([]byte(accepted), []byte(token))
+	}
+	return valid == 1 && token != ""
+}
+
+func allowedAddress(allowed []string, address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	ip := net.ParseIP(host)
+	for _, entry := range allowed {
+		if entry == address || entry == host {
+			return true
+		}
+		if _, block, err := net.ParseCIDR(entry); err == nil && ip != nil && block.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// registerAdmissionHandlers makes the main container refuse the registrations its admission policy does not allow,
+// and the requests of the containers it did not register. The requests are attributed to the container they come
+// from, whatever their payload claims.
+func (MainContainer *MainContainer) registerAdmissionHandlers() {
+	ns := MainContainer.networkService
+	admission := MainContainer.admission
+	if registerContainer, ok := ns.Handler(Messages.RegisterContainer); ok {
+		ns.RegisterHandler(Messages.RegisterContainer, func(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+			payload, err := Messages.Decode[Messages.RegisterContainerPayload](message)
+			if err != nil {
+				return Messages.Message{}, err
+			}
+			admission.mutex.Lock()
+			defer admission.mutex.Unlock()
+			if err := admission.admit(MainContainer, payload); err != nil {
+				return Messages.Message{}, err
+			}
+			return registerContainer(ctx, message)
+		})
+	}
+	if registerAgent, ok := ns.Handler(Messages.RegisterAgent); ok {
+		ns.RegisterHandler(Messages.RegisterAgent, func(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+			admission.mutex.Lock()
+			defer admission.mutex.Unlock()
+			peer, err := MainContainer.admitted(ctx)
+			if err != nil {
+				return Messages.Message{}, err
+			}
+			if max := admission.policy.MaxAgents; max > 0 && MainContainer.yellowPage.AgentCount(peer) >= max {
+				return Messages.Message{}, fmt.Errorf("%w: %s hosts %d agents", ErrAgentQuota, peer, max)
+			}
+			return registerAgent(ctx, message)
+		})
+	}
+	gated := []Messages.MessageType{
+		Messages.DeregisterAgent, Messages.DeregisterContainer,
+		Messages.RegisterService, Messages.ModifyService, Messages.DeregisterService,
+		Messages.SubscribeRegistry, Messages.UnsubscribeRegistry,
+	}
+	for _, messageType := range gated {
+		handler, ok := ns.Handler(messageType)
+		if !ok {
+			continue
+		}
+		ns.RegisterHandler(messageType, func(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+			if _, err := MainContainer.admitted(ctx); err != nil {
+				return Messages.Message{}, err
+			}
+			return handler(ctx, message)
+		})
+	}
+	// the lease of a container not registered anymore is answered as expired, so that it registers again
+	if renewLease, ok := ns.Handler(Messages.RenewLease); ok {
+		ns.RegisterHandler(Messages.RenewLease, func(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+			if peer, _ := NetworkService.Peer(ctx); peer == MainContainer.localAdress {
+				return Messages.Message{}, fmt.Errorf("%w: %s is the main container", ErrNotAdmitted, peer)
+			}
+			return renewLease(ctx, message)
+		})
+	}
+}
+
+// admitStandby returns an error wrapping ErrNotAdmitted unless the standby main container may replicate the yellow page.
+func (MainContainer *MainContainer) admitStandby(ctx context.Context, payload Messages.RegisterStandbyPayload) error {
+	if err := actsFor(ctx, payload.Address); err != nil {
+		return err
+	}
+	if payload.Address == MainContainer.localAdress {
+		return fmt.Errorf("%w: %s is the main container", ErrNotAdmitted, payload.Address)
+	}
+	return MainContainer.admission.credentials(payload.Address, payload.Token)
+}
@@ -0,0 +1,237 @@
+package Container
+
+import (
+	"FrameworkMultiAgents/Messages"
+	"FrameworkMultiAgents/NetworkService"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// refusedWith tells whether err is, or the main container answered with, an error wrapping want.
+func refusedWith(err, want error) bool {
+	var remoteError *NetworkService.RemoteError
+	if errors.As(err, &remoteError) {
+		return strings.Contains(remoteError.Message, want.Error())
+	}
+	return errors.Is(err, want)
+}
+
+func TestValidToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		tokens []string
+		token  string
+		want   bool
+	}{
+		{"accepted", []string{"secret"}, "secret", true},
+		{"one of the accepted", []string{"old", "secret"}, "secret", true},
+		{"other token", []string{"secret"}, "guess", false},
+		{"prefix", []string{"secret"}, "sec", false},
+		{"no token", []string{"secret"}, "", false},
+		{"empty accepted token", []string{""}, "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := validToken(test.tokens, test.token); got != test.want {
+				t.Errorf("validToken() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAllowedAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		address string
+		want    bool
+	}{
+		{"address", []string{"10.0.0.1:8081"}, "10.0.0.1:8081", true},
+		{"other port", []string{"10.0.0.1:8081"}, "10.0.0.1:8082", false},
+		{"host", []string{"10.0.0.1"}, "10.0.0.1:8082", true},
+		{"host name", []string{"worker"}, "worker:8081", true},
+		{"CIDR block", []string{"10.0.0.0/24"}, "10.0.0.42:8081", true},
+		{"outside the CIDR block", []string{"10.0.0.0/24"}, "10.0.1.42:8081", false},
+		{"IPv6 CIDR block", []string{"fd00::/8"}, "[fd00::1]:8081", true},
+		{"host name and CIDR block", []string{"10.0.0.0/24"}, "worker:8081", false},
+		{"memory address", []string{"container-1"}, "container-1", true},
+		{"none allowed", nil, "container-1", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := allowedAddress(test.allowed, test.address); got != test.want {
+				t.Errorf("allowedAddress() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAdmission(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  AdmissionPolicy
+		token   string
+		wantErr bool
+	}{
+		{"open", AdmissionPolicy{}, "", false},
+		{"token", AdmissionPolicy{Tokens: []string{"secret"}}, "secret", false},
+		{"wrong token", AdmissionPolicy{Tokens: []string{"secret"}}, "guess", true},
+		{"missing token", AdmissionPolicy{Tokens: []string{"secret"}}, "", true},
+		{"allowed address", AdmissionPolicy{AllowedAddresses: []string{"container-1"}}, "", false},
+		{"address not allowed", AdmissionPolicy{AllowedAddresses: []string{"container-2"}}, "", true},
+		{"refused by Admit", AdmissionPolicy{Admit: func(address string) error { return errors.New("maintenance") }}, "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transport := NetworkService.NewMemoryTransport()
+			mainContainer := startMainContainer(t, "main", WithTransport(transport), WithAdmission(test.policy))
+			container, err := NewContainer("main", "container-1", WithTransport(transport), WithJoinToken(test.token))
+			if test.wantErr {
+				if !refusedWith(err, ErrNotAdmitted) {
+					t.Fatalf("NewContainer: %v, want %v", err, ErrNotAdmitted)
+				}
+				if mainContainer.yellowPage.IsContainerRegistered("container-1") {
+					t.Error("the refused container is registered")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewContainer: %v", err)
+			}
+			container.Shutdown(shutdownContext(t))
+		})
+	}
+}
+
+func TestQuotas(t *testing.T) {
+	transport := NetworkService.NewMemoryTransport()
+	startMainContainer(t, "main", WithTransport(transport), WithAdmission(AdmissionPolicy{MaxContainers: 1, MaxAgents: 2}))
+	container, err := NewContainer("main", "container-1", WithTransport(transport))
+	if err != nil {
+		t.Fatalf("NewContainer: %v", err)
+	}
+	t.Cleanup(func() { container.Shutdown(shutdownContext(t)) })
+
+	if _, err := NewContainer("main", "container-2", WithTransport(transport)); !refusedWith(err, ErrNotAdmitted) {
+		t.Errorf("second container: %v, want %v", err, ErrNotAdmitted)
+	}
+	for i, wantErr := range []error{nil, nil, ErrAgentQuota} {
+		_, err := container.AddAgent("")
+		if wantErr == nil && err != nil || wantErr != nil && !refusedWith(err, wantErr) {
+			t.Errorf("agent %d: %v, want %v", i+1, err, wantErr)
+		}
+	}
+}
+
+func TestRequestsOfOtherContainers(t *testing.T) {
+	transport := NetworkService.NewMemoryTransport()
+	startMainContainer(t, "main", WithTransport(transport))
+	container, err := NewContainer("main", "container-1", WithTransport(transport))
+	if err != nil {
+		t.Fatalf("NewContainer: %v", err)
+	}
+	t.Cleanup(func() { container.Shutdown(shutdownContext(t)) })
+	agentID, err := container.AddAgent("pong")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rogue := NetworkService.NewNetworkService("main", "rogue")
+	rogue.SetTransport(transport)
+	if err := rogue.Listen(); err != nil {
+		t.Fatal(err)
+	}
+	go rogue.Start()
+	t.Cleanup(func() { rogue.Shutdown(shutdownContext(t)) })
+	send := func(messageType Messages.MessageType, contentType Messages.ContentType, payload any) error {
+		message := Messages.Message{Type: messageType, Sender: "container-1", ExpectResponse: true}
+		if err := message.SetContent(contentType, payload); err != nil {
+			t.Fatal(err)
+		}
+		_, err := rogue.SendMessage(context.Background(), message, "main")
+		return err
+	}
+
+	tests := []struct {
+		name        string
+		messageType Messages.MessageType
+		contentType Messages.ContentType
+		payload     any
+		wantErr     error // nil when the request is handled
+	}{
+		{"unregistered container", Messages.RegisterAgent, Messages.RegisterAgentContent,
+			Messages.RegisterAgentPayload{ContainerID: "rogue"}, ErrNotAdmitted},
+		{"registration for another container", Messages.RegisterContainer, Messages.RegisterContainerContent,
+			Messages.RegisterContainerPayload{Address: "container-1"}, NetworkService.ErrUntrustedPeer},
+		{"registration", Messages.RegisterContainer, Messages.RegisterContainerContent,
+			Messages.RegisterContainerPayload{Address: "rogue"}, nil},
+		{"agent of another container", Messages.RegisterAgent, Messages.RegisterAgentContent,
+			Messages.RegisterAgentPayload{ContainerID: "container-1"}, NetworkService.ErrUntrustedPeer},
+		{"deregistration of another agent", Messages.DeregisterAgent, Messages.DeregisterAgentContent,
+			Messages.DeregisterAgentPayload{AgentID: agentID}, NetworkService.ErrUntrustedPeer},
+		{"deregistration of another container", Messages.DeregisterContainer, Messages.DeregisterContainerContent,
+			Messages.DeregisterContainerPayload{Address: "container-1"}, NetworkService.ErrUntrustedPeer},
+		{"own agent", Messages.RegisterAgent, Messages.RegisterAgentContent,
+			Messages.RegisterAgentPayload{ContainerID: "rogue"}, nil},
+	}
+	// the steps depend on each other, they run in order
+	for _, test := range tests {
+		err := send(test.messageType, test.contentType, test.payload)
+		if test.wantErr == nil && err != nil || test.wantErr != nil && !refusedWith(err, test.wantErr) {
+			t.Errorf("%s: %v, want %v", test.name, err, test.wantErr)
+		}
+	}
+	if _, err := container.ResolveAgentAddress(agentID); err != nil {
+		t.Errorf("the agent of container-1 is gone: %v", err)
+	}
+}
+
+func TestDeathFromOtherContainers(t *testing.T) {
+	transport := NetworkService.NewMemoryTransport()
+	startMainContainer(t, "main", WithTransport(transport))
+	containers := make([]*Container, 2)
+	for i := range containers {
+		container, err := NewContainer("main", fmt.Sprintf("container-%d", i+1), WithTransport(transport))
+		if err != nil {
+			t.Fatalf("NewContainer: %v", err)
+		}
+		t.Cleanup(func() { container.Shutdown(shutdownContext(t)) })
+		containers[i] = container
+	}
+	agentID, err := containers[0].AddAgent("pong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent := containers[0].GetAgent(agentID)
+	go agent.Start(context.Background())
+	id, _ := strconv.Atoi(agentID)
+
+	// sent directly, the Death is refused
+	message := Messages.Message{Type: Messages.Death, Sender: "container-2", ExpectResponse: true}
+	if err := message.SetContent(Messages.DeathContent, Messages.DeathPayload{AgentID: id}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := containers[1].networkService.SendMessage(context.Background(), message, "container-1"); !refusedWith(err, NetworkService.ErrUntrustedPeer) {
+		t.Errorf("Death sent to container-1: %v, want %v", err, NetworkService.ErrUntrustedPeer)
+	}
+	select {
+	case <-agent.Done():
+		t.Fatal("the agent was killed by another container")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// through the main container, it is delivered
+	if err := containers[1].KillAgent(id); err != nil {
+		t.Fatalf("KillAgent: %v", err)
+	}
+	select {
+	case <-agent.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("the agent was not killed")
+	}
+}
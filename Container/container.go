@@ -5,83 +5,227 @@ import (
 	"FrameworkMultiAgents/Messages"
 	"FrameworkMultiAgents/NetworkService"
 	"FrameworkMultiAgents/YellowPage"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Container struct {
-	id                  string
-	localAdress         string
-	agents              map[string]*Agent.Agent
-	mainServerAdress    string // null if the Container is the main Container
-	mainServerPort      string
-	networkService      *NetworkService.NetworkService
-	resolveAgentLocally func(agentID string) (string, error)
+	id                     string
+	localAdress            string
+	agents                 map[string]*Agent.Agent
+	names                  map[string]string // IDs of the local agents by name
+	agentsMutex            sync.RWMutex
+	platform               string   // name of the platform, the agents are named name@platform
+	mainServerAdress       string   // null if the Container is the main Container, see currentMain
+	mainAddresses          []string // main containers to fail over to, in order, see failover.go
+	mainMutex              sync.Mutex
+	heartbeatInterval      time.Duration
+	missedHeartbeats       int
+	mainServerPort         string
+	networkService         *NetworkService.NetworkService
+	resolveAgentLocally    func(agentID string) (string, error)
+	resolveNameLocally     func(name string) (string, string, error)
+	deregisterAgentLocally func(agentID string) bool
+	resolutions            *resolutionCache                  // agent addresses resolved by the main container, see resolution.go
+	directory              *YellowPage.YellowPage            // yellow page of the main container, nil in the other containers
+	subscriptions          map[string]func(YellowPage.Event) // handlers of the subscriptions of the container, see subscriptions.go
+	subscriptionsMutex     sync.Mutex
+	nextSubscription       atomic.Uint64
+	scheduler              *Scheduler
+	schedulerStarted       bool // by Start, the agents added afterwards join the pool at once; guarded by agentsMutex
+	ctx                    context.Context
+	cancel                 context.CancelFunc
 }
 
 type MainContainer struct {
 	Container
-	yellowPage YellowPage.YellowPage
+	yellowPage     *YellowPage.YellowPage
+	standby        atomic.Bool // replicates the primary main container, see replication.go
+	following      string      // the primary main container a standby registered with
+	followingMutex sync.Mutex
+	replicas       map[string]*replica
+	replicasMutex  sync.Mutex
+	resolvers      map[string]bool // containers caching agent addresses
+	resolversMutex sync.Mutex
+	leaseDuration  time.Duration // granted to the containers on each heartbeat, see leases.go
+	admission      *admission    // see admission.go
+	joinToken      string        // presented by a standby to the primary main container
 }
 
-func NewContainer(mainAddress, localAddress string) *Container {
+// NewContainer registers a container with the main container at mainAddress.
+// WithMainAddresses gives the standby main containers used when the main container fails.
+// It returns the error of the registration when no main container accepts the container.
+func NewContainer(mainAddress, localAddress string, opts ...Option) (*Container, error) {
+	settings := newOptions(opts)
 	networkService := NetworkService.NewNetworkService(mainAddress, localAddress)
+	networkService.SetRequestTimeout(settings.requestTimeout)
+	networkService.SetReconnectPolicy(settings.reconnectPolicy)
+	networkService.SetOutboundQueue(settings.queueLength, settings.queuePolicy)
+	if err := settings.listen(networkService); err != nil {
+		return nil, err
+	}
+	// on port 0, the port chosen by the system
+	localAddress = networkService.LocalAddress
+
+	ctx, cancel := context.WithCancel(context.Background())
+	newContainer := &Container{
+		id:                  localAddress,
+		localAdress:         localAddress,
+		agents:              make(map[string]*Agent.Agent),
+		names:               make(map[string]string),
+		mainServerAdress:    mainAddress,
+		mainAddresses:       append([]string{mainAddress}, settings.mainAddresses...),
+		heartbeatInterval:   settings.heartbeatInterval,
+		missedHeartbeats:    settings.missedHeartbeats,
+		networkService:      networkService,
+		resolveAgentLocally: nil,
+		resolutions:         newResolutionCache(settings.resolutionTTL),
+		subscriptions:       make(map[string]func(YellowPage.Event)),
+		ctx:                 ctx,
+		cancel:              cancel,
+	}
 
 	// Prepare the message
-	payload := Messages.RegisterContainerPayload{Address: localAddress}
-	payloadStr, _ := json.Marshal(payload) // handle error properly
+	payload := Messages.RegisterContainerPayload{Address: localAddress, Token: settings.joinToken}
+	content, err := Messages.Encode(Messages.RegisterContainerContent, payload)
+	if err != nil {
+		cancel()
+		networkService.Shutdown(context.Background())
+		return nil, fmt.Errorf("failed to encode register Container message: %w", err)
+	}
 	message := Messages.Message{
 		Type:           Messages.RegisterContainer,
 		Sender:         localAddress,
 		ContentType:    Messages.RegisterContainerContent,
-		Content:        string(payloadStr),
+		Content:        content,
 		ExpectResponse: true,
 	}
 	// revoir les notation (content/payload)
-	// voir les contexte en go
 
 	// Send the message and wait for a response
-	response, err := networkService.SendMessage(message, mainAddress)
+	response, err := newContainer.sendToMain(context.Background(), message)
+	var remoteError *NetworkService.RemoteError
+	if errors.As(err, &remoteError) && strings.HasPrefix(remoteError.Message, ErrNotAdmitted.Error()) {
+		err = fmt.Errorf("%w%s", ErrNotAdmitted, strings.TrimPrefix(remoteError.Message, ErrNotAdmitted.Error()))
+	}
 	if err != nil {
-		log.Fatalf("Failed to register Container: %v", err)
+		cancel()
+		networkService.Shutdown(context.Background())
+		return nil, fmt.Errorf("failed to register Container: %w", err)
 	}
-	var answerPayload Messages.RegisterContainerAnswerPayload
-	if err := json.Unmarshal([]byte(response.Content), &answerPayload); err != nil {
-		log.Fatalf("Failed to parse register Container response: %v", err)
+	answerPayload, err := Messages.Decode[Messages.RegisterContainerAnswerPayload](response)
+	if err != nil {
+		cancel()
+		networkService.Shutdown(context.Background())
+		return nil, fmt.Errorf("failed to parse register Container response: %w", err)
 	}
+	newContainer.platform = answerPayload.Platform
 
-	newContainer := &Container{
-		id:                  localAddress,
-		localAdress:         localAddress,
-		agents:              make(map[string]*Agent.Agent),
-		mainServerAdress:    mainAddress,
-		networkService:      networkService,
-		resolveAgentLocally: nil,
-	}
 	newContainer.networkService.SetContainerOps(newContainer)
+	newContainer.registerInvalidationHandler()
+	newContainer.registerEventHandler()
+	newContainer.registerDeathCheck()
 	go newContainer.networkService.Start()
-	return newContainer
+	go newContainer.renewLease()
+	return newContainer, nil
+}
+
+// NewMainContainer starts the primary main container of the platform.
+// It returns an error when its address can not be listened on, or when the journal of its yellow page
+// can not be read, see WithPersistence.
+func NewMainContainer(mainAdress string, opts ...Option) (*MainContainer, error) {
+	mainContainer, err := newMainContainer(mainAdress, newOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	// the agents of the previous run of the main container died with it
+	mainContainer.yellowPage.DeregisterContainer(mainContainer.localAdress)
+	mainContainer.yellowPage.RegisterContainer(mainContainer.localAdress)
+	return mainContainer, nil
 }
 
-func NewMainContainer(mainAdress string) *MainContainer {
-	container := Container{
-		id:               mainAdress,
-		localAdress:      mainAdress,
-		agents:           make(map[string]*Agent.Agent),
-		mainServerAdress: "",
-		networkService:   NetworkService.NewNetworkService(mainAdress, mainAdress),
+// newMainContainer starts serving a yellow page, as the primary or as a standby.
+func newMainContainer(mainAdress string, settings options) (*MainContainer, error) {
+	yellowPage := YellowPage.NewYellowPage()
+	if settings.persistenceDirectory != "" {
+		var err error
+		yellowPage, err = YellowPage.OpenYellowPage(settings.persistenceDirectory)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the yellow page: %w", err)
+		}
+		if settings.snapshotInterval > 0 {
+			yellowPage.Journal().SnapshotInterval = settings.snapshotInterval
+		}
 	}
-	go container.networkService.Start()
-	mainContainer := MainContainer{
-		Container:  container,
-		yellowPage: *YellowPage.NewYellowPage(),
+
+	networkService := NetworkService.NewNetworkService(mainAdress, mainAdress)
+	if err := settings.listen(networkService); err != nil {
+		if closeErr := yellowPage.Close(); closeErr != nil {
+			log.Printf("Failed to close the yellow page: %v", closeErr)
+		}
+		return nil, fmt.Errorf("failed to start the main container: %w", err)
+	}
+	// on port 0, the port chosen by the system
+	mainAdress = networkService.LocalAddress
+	if settings.platformName == "" {
+		settings.platformName = mainAdress
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	mainContainer := &MainContainer{
+		Container: Container{
+			id:                mainAdress,
+			localAdress:       mainAdress,
+			agents:            make(map[string]*Agent.Agent),
+			names:             make(map[string]string),
+			platform:          settings.platformName,
+			mainServerAdress:  "",
+			heartbeatInterval: settings.heartbeatInterval,
+			missedHeartbeats:  settings.missedHeartbeats,
+			networkService:    networkService,
+			resolutions:       newResolutionCache(settings.resolutionTTL),
+			subscriptions:     make(map[string]func(YellowPage.Event)),
+			ctx:               ctx,
+			cancel:            cancel,
+		},
+		yellowPage:    yellowPage,
+		replicas:      make(map[string]*replica),
+		resolvers:     make(map[string]bool),
+		leaseDuration: settings.leaseDuration,
+		admission:     &admission{policy: settings.admission},
+		joinToken:     settings.joinToken,
+	}
+	mainContainer.Container.directory = mainContainer.yellowPage
+	mainContainer.networkService.SetRequestTimeout(settings.requestTimeout)
+	mainContainer.networkService.SetReconnectPolicy(settings.reconnectPolicy)
+	mainContainer.networkService.SetOutboundQueue(settings.queueLength, settings.queuePolicy)
+	mainContainer.networkService.SetContainerOps(mainContainer)
+	mainContainer.registerDirectoryHandlers()
+	mainContainer.registerSubscriptionHandlers()
+	mainContainer.registerLeaseHandler()
+	mainContainer.registerEventHandler()
+	mainContainer.registerResolutionHandler()
+	mainContainer.registerAdmissionHandlers()
+	mainContainer.registerPeerChecks()
+	mainContainer.registerDeathForwarding()
+	mainContainer.registerReplicationHandlers()
+	go mainContainer.networkService.Start()
+	go mainContainer.pushInvalidations()
+	go mainContainer.notifySubscribers()
+	if settings.leaseDuration > 0 {
+		go mainContainer.expireLeases()
 	}
-	container.networkService.SetContainerOps(&mainContainer)
-	mainContainer.yellowPage.RegisterContainer(mainAdress)
 	mainContainer.Container.resolveAgentLocally = mainContainer.ResolveAgentAddress
-	return &mainContainer
+	mainContainer.Container.resolveNameLocally = mainContainer.ResolveAgentName
+	mainContainer.Container.deregisterAgentLocally = mainContainer.DeregisterAgent
+	return mainContainer, nil
 }
 
 func (Container *Container) RegisterContainer(address string) string {
@@ -89,64 +233,239 @@ func (Container *Container) RegisterContainer(address string) string {
 	return ""
 }
 
-func (Container *Container) RegisterAgent(containerId string) string {
+func (Container *Container) RegisterAgent(containerId, name string) (string, error) {
 	// No-op for regular containers
-	return ""
+	return "", nil
+}
+
+// Address returns the address of the container, with the port chosen by the system when created on port 0.
+func (Container *Container) Address() string {
+	return Container.localAdress
+}
+
+// PlatformName returns the name of the platform of the container.
+func (Container *Container) PlatformName() string {
+	return Container.platform
+}
+
+func (Container *Container) DeregisterAgent(agentID string) bool {
+	// No-op for regular containers
+	return false
+}
+
+func (Container *Container) DeregisterContainer(address string) bool {
+	// No-op for regular containers
+	return false
 }
 
 func (MainContainer *MainContainer) RegisterContainer(Address string) string {
 	return MainContainer.yellowPage.RegisterContainer(Address)
 }
 
-func (MainContainer *MainContainer) RegisterAgent(containerId string) string {
-	return MainContainer.yellowPage.RegisterAgent(containerId)
+func (MainContainer *MainContainer) RegisterAgent(containerId, name string) (string, error) {
+	return MainContainer.yellowPage.RegisterNamedAgent(containerId, name)
 }
 
-func (MainContainer *MainContainer) AddAgent() string {
-	agentID := MainContainer.RegisterAgent(MainContainer.id)
-	agent := *Agent.NewAgent(agentID, MainContainer.sendMessageToAnotherAgent, MainContainer.GetSyncChannelWithAgent)
-	MainContainer.agents[agentID] = &agent
-	return agentID
+func (MainContainer *MainContainer) DeregisterAgent(agentID string) bool {
+	return MainContainer.yellowPage.DeregisterAgent(agentID)
 }
 
-func (Container *Container) AddAgent() string {
-	var agentID string
+// DeregisterContainer forgets the container registered with the address and all its agents.
+// A standby leaves it to the primary.
+func (MainContainer *MainContainer) DeregisterContainer(address string) bool {
+	if address == MainContainer.localAdress || !MainContainer.IsPrimary() {
+		return false
+	}
+	_, found := MainContainer.yellowPage.DeregisterContainer(address)
+	return found
+}
+
+// AddAgent creates an agent in the main container, see Container.AddAgent.
+func (MainContainer *MainContainer) AddAgent(name string) (string, error) {
+	return MainContainer.AddAgentContext(context.Background(), name)
+}
+
+// AddAgentContext creates an agent in the main container, see Container.AddAgentContext.
+func (MainContainer *MainContainer) AddAgentContext(ctx context.Context, name string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if !MainContainer.IsPrimary() {
+		return "", fmt.Errorf("standby main container %s hosts no agent until it takes over: %w", MainContainer.localAdress, ErrNotPrimary)
+	}
+	agentID, err := MainContainer.RegisterAgent(MainContainer.id, name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := MainContainer.addLocalAgent(agentID, name); err != nil {
+		return "", err
+	}
+	return agentID, nil
+}
+
+// AddAgent creates an agent and returns its ID. A non-empty name is reserved in the yellow page
+// until the agent dies, YellowPage.ErrNameTaken is returned if another agent has it.
+// The agent is then reachable as name or name@platform as well as by its ID.
+func (Container *Container) AddAgent(name string) (string, error) {
+	return Container.AddAgentContext(context.Background(), name)
+}
+
+// AddAgentContext is AddAgent, giving up when ctx is done. The main container may still register
+// the agent if ctx ends while the request is on its way, the registration then ends with the container.
+func (Container *Container) AddAgentContext(ctx context.Context, name string) (string, error) {
+	if name != "" {
+		if err := YellowPage.ValidateAgentName(name); err != nil {
+			return "", err
+		}
+	}
 
 	// Prepare the message
-	payload := Messages.RegisterAgentPayload{ContainerID: Container.id}
-	payloadStr, _ := json.Marshal(payload) // handle error properly
+	payload := Messages.RegisterAgentPayload{ContainerID: Container.id, Name: name}
+	content, err := Messages.Encode(Messages.RegisterAgentContent, payload)
+	if err != nil {
+		return "", err
+	}
 	message := Messages.Message{
 		Type:           Messages.RegisterAgent,
 		Sender:         Container.localAdress,
 		ContentType:    Messages.RegisterAgentContent,
-		Content:        string(payloadStr),
+		Content:        content,
 		ExpectResponse: true,
 	}
 
 	// Send the message and wait for a response
-	response, err := Container.networkService.SendMessage(message, Container.mainServerAdress)
+	response, err := Container.sendToMain(ctx, message)
+	var remoteError *NetworkService.RemoteError
+	if errors.As(err, &remoteError) && strings.HasPrefix(remoteError.Message, YellowPage.ErrNameTaken.Error()) {
+		return "", fmt.Errorf("%w: %s", YellowPage.ErrNameTaken, name)
+	}
+	if errors.As(err, &remoteError) {
+		for _, refusal := range []error{ErrAgentQuota, ErrNotAdmitted} {
+			if strings.HasPrefix(remoteError.Message, refusal.Error()) {
+				return "", fmt.Errorf("failed to register agent: %w%s", refusal, strings.TrimPrefix(remoteError.Message, refusal.Error()))
+			}
+		}
+	}
 	if err != nil {
-		log.Fatalf("Failed to register agent: %v", err)
+		return "", fmt.Errorf("failed to register agent: %w", err)
 	}
 
 	// Parse the response
-	var answerPayload Messages.RegisterAgentAnswerPayload
-	if err := json.Unmarshal([]byte(response.Content), &answerPayload); err != nil {
-		log.Fatalf("Failed to parse register agent response: %v", err)
+	answerPayload, err := Messages.Decode[Messages.RegisterAgentAnswerPayload](response)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse register agent response: %w", err)
 	}
-	agentID = strconv.Itoa(answerPayload.ID)
+	agentID := strconv.Itoa(answerPayload.ID)
 
-	// Create the agent
-	agent := *Agent.NewAgent(agentID, Container.sendMessageToAnotherAgent, Container.GetSyncChannelWithAgent)
-	Container.agents[agentID] = &agent
+	if _, err := Container.addLocalAgent(agentID, name); err != nil {
+		return "", err
+	}
+	return agentID, nil
+}
 
-	return agentID
+func (Container *Container) addLocalAgent(agentID, name string) (*Agent.Agent, error) {
+	id, err := strconv.Atoi(agentID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid agent ID %q: %w", agentID, err)
+	}
+	aid := Agent.AID{Name: name, Platform: Container.platform, Addresses: []string{Container.localAdress}}
+	agent := Agent.NewAgent(id, aid, Container.sendMessageToAnotherAgent, Container.GetSyncChannelWithAgent)
+	agent.OnDeath = Container.agentDied
+	agent.SendToReceivers = Container.sendToReceivers
+	agent.Directory = Container
+	Container.agentsMutex.Lock()
+	Container.agents[agentID] = agent
+	if name != "" {
+		Container.names[name] = agentID
+	}
+	var pool *Scheduler
+	if Container.schedulerStarted {
+		pool = Container.scheduler
+	}
+	Container.agentsMutex.Unlock()
+	if pool != nil {
+		// the pool is running, Start will not attach this agent
+		pool.Add(Container.ctx, agent)
+	}
+	return agent, nil
+}
+
+// agentDied forgets a dead agent and removes it from the YellowPage.
+func (Container *Container) agentDied(agentID int) {
+	agentIDStr := strconv.Itoa(agentID)
+	Container.agentsMutex.Lock()
+	if agent, ok := Container.agents[agentIDStr]; ok && Container.names[agent.Name()] == agentIDStr {
+		delete(Container.names, agent.Name())
+	}
+	delete(Container.agents, agentIDStr)
+	Container.agentsMutex.Unlock()
+	if err := Container.deregisterAgent(agentIDStr); err != nil {
+		log.Printf("Failed to deregister agent %s: %v", agentIDStr, err)
+	}
+}
+
+func (Container *Container) deregisterAgent(agentID string) error {
+	if Container.currentMain() == "" {
+		Container.deregisterAgentLocally(agentID)
+		return nil
+	}
+	payload := Messages.DeregisterAgentPayload{AgentID: agentID}
+	content, err := Messages.Encode(Messages.DeregisterAgentContent, payload)
+	if err != nil {
+		return err
+	}
+	message := Messages.Message{
+		Type:           Messages.DeregisterAgent,
+		Sender:         Container.localAdress,
+		ContentType:    Messages.DeregisterAgentContent,
+		Content:        content,
+		ExpectResponse: true,
+	}
+	_, err = Container.sendToMain(context.Background(), message)
+	return err
+}
+
+// KillAgent sends a Death message to the agent, wherever it lives.
+// The agent handles the messages already in its mailbox before dying.
+func (Container *Container) KillAgent(agentID int) error {
+	return Container.KillAgentContext(context.Background(), agentID)
+}
+
+// KillAgentContext is KillAgent, giving up when ctx is done.
+func (Container *Container) KillAgentContext(ctx context.Context, agentID int) error {
+	if agent := Container.GetAgent(strconv.Itoa(agentID)); agent != nil {
+		agent.Stop()
+		return nil
+	}
+	agentAdress, err := Container.ResolveAgentAddressContext(ctx, strconv.Itoa(agentID))
+	if err != nil {
+		return err
+	}
+	payload := Messages.DeathPayload{AgentID: agentID}
+	content, err := Messages.Encode(Messages.DeathContent, payload)
+	if err != nil {
+		return err
+	}
+	message := Messages.Message{
+		Type:           Messages.Death,
+		Sender:         Container.localAdress,
+		ContentType:    Messages.DeathContent,
+		Content:        content,
+		ExpectResponse: false,
+	}
+	if Container.currentMain() != "" {
+		// the containers take a Death from a main container only, which checks who asks for it
+		_, err = Container.sendToMain(ctx, message)
+		return err
+	}
+	_, err = Container.networkService.SendMessage(ctx, message, agentAdress)
+	return err
 }
 
 func (Container *Container) PutMessageInMailBox(message Messages.Message, receiverID int) {
-	if _, exists := Container.agents[strconv.Itoa(receiverID)]; exists {
+	if agent := Container.GetAgent(strconv.Itoa(receiverID)); agent != nil {
 		// send the message to the agent
-		Container.agents[strconv.Itoa(receiverID)].MailBox <- message
+		agent.Deliver(message, true)
 	}
 	return
 }
@@ -155,34 +474,109 @@ func (MainContainer *MainContainer) ResolveAgentAddress(agentID string) (string,
 	return MainContainer.yellowPage.ResolveAgentAddress(agentID)
 }
 
+func (MainContainer *MainContainer) ResolveAgentName(name string) (string, string, error) {
+	return MainContainer.yellowPage.ResolveAgentName(name)
+}
+
 func (Container *Container) ResolveAgentAddress(agentID string) (string, error) {
-	if Container.mainServerAdress == "" {
+	return Container.ResolveAgentAddressContext(context.Background(), agentID)
+}
+
+// ResolveAgentAddressContext is ResolveAgentAddress, giving up when ctx is done.
+func (Container *Container) ResolveAgentAddressContext(ctx context.Context, agentID string) (string, error) {
+	if Container.currentMain() == "" {
 		return Container.resolveAgentLocally(agentID)
-	} else {
-		// send the message to the main Container
-		// Prepare the message
-		payload := Messages.GetAgentAdressPayload{AgentID: agentID}
-		payloadStr, _ := json.Marshal(payload)
-		message := Messages.Message{
-			Type:           Messages.GetAgentAdress,
-			Sender:         Container.localAdress,
-			ContentType:    Messages.GetAgentAdressContent,
-			Content:        string(payloadStr),
-			ExpectResponse: true,
-		}
-		// Send the message and wait for a response
-		response, err := Container.networkService.SendMessage(message, Container.mainServerAdress)
-		if err != nil {
-			log.Fatalf("Failed to resolve agent address: %v", err)
-		}
-		// Parse the response
-		var answerPayload Messages.GetAgentAdressAnswerPayload
-		if err := json.Unmarshal([]byte(response.Content), &answerPayload); err != nil {
-			log.Fatalf("Failed to parse resolve agent address response: %v", err)
-		}
-		return answerPayload.Adress, nil
 	}
+	_, address, err := Container.lookupAgent(ctx, Messages.GetAgentAdressPayload{AgentID: agentID}, agentID)
+	return address, err
+}
+
+// ResolveAgentName returns the ID of the agent registered under name and the address of its container.
+func (Container *Container) ResolveAgentName(name string) (string, string, error) {
+	return Container.ResolveAgentNameContext(context.Background(), name)
+}
+
+// ResolveAgentNameContext is ResolveAgentName, giving up when ctx is done.
+func (Container *Container) ResolveAgentNameContext(ctx context.Context, name string) (string, string, error) {
+	if Container.currentMain() == "" {
+		return Container.resolveNameLocally(name)
+	}
+	return Container.lookupAgent(ctx, Messages.GetAgentAdressPayload{Name: name}, name)
+}
+
+// lookupAgent asks the main container where an agent lives, unless the cache knows it under key.
+func (Container *Container) lookupAgent(ctx context.Context, payload Messages.GetAgentAdressPayload, key string) (string, string, error) {
+	if agentID, address, ok := Container.resolutions.get(key); ok {
+		return agentID, address, nil
+	}
+	// send the message to the main Container
+	// Prepare the message
+	content, err := Messages.Encode(Messages.GetAgentAdressContent, payload)
+	if err != nil {
+		return "", "", err
+	}
+	message := Messages.Message{
+		Type:           Messages.GetAgentAdress,
+		Sender:         Container.localAdress,
+		ContentType:    Messages.GetAgentAdressContent,
+		Content:        content,
+		ExpectResponse: true,
+	}
+	// Send the message and wait for a response
+	response, err := Container.sendToMain(ctx, message)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to resolve agent address: %w", err)
+	}
+	// Parse the response
+	answerPayload, err := Messages.Decode[Messages.GetAgentAdressAnswerPayload](response)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse resolve agent address response: %w", err)
+	}
+	if answerPayload.Adress == "" {
+		return "", "", fmt.Errorf("%w %s", YellowPage.ErrUnknownAgent, key)
+	}
+	agentID := answerPayload.AgentID
+	if agentID == "" {
+		// main containers predating the agent names answer the address only
+		agentID = payload.AgentID
+	}
+	Container.resolutions.put(key, agentID, answerPayload.Adress)
+	return agentID, answerPayload.Adress, nil
+}
+
+// localAgent returns the agent of the container given by ID, name or name@platform, nil if it lives elsewhere.
+func (Container *Container) localAgent(receiver string) *Agent.Agent {
+	aid := Agent.ParseAID(receiver)
+	if aid.Platform != "" && aid.Platform != Container.platform {
+		return nil
+	}
+	Container.agentsMutex.RLock()
+	defer Container.agentsMutex.RUnlock()
+	if agent, ok := Container.agents[aid.Name]; ok {
+		return agent
+	}
+	return Container.agents[Container.names[aid.Name]]
+}
 
+// LocalAgentID returns the ID of the agent of the container given by ID, name or name@platform.
+func (Container *Container) LocalAgentID(receiver string) (int, bool) {
+	if agent := Container.localAgent(receiver); agent != nil {
+		return agent.ID, true
+	}
+	return 0, false
+}
+
+// resolveReceiver returns the address of the container of an agent given by ID, name or name@platform.
+func (Container *Container) resolveReceiver(receiver string) (string, error) {
+	aid := Agent.ParseAID(receiver)
+	if aid.Platform != "" && aid.Platform != Container.platform {
+		return "", fmt.Errorf("%w %s: the platform is %s", YellowPage.ErrUnknownAgent, receiver, Container.platform)
+	}
+	if YellowPage.ValidateAgentName(aid.Name) != nil {
+		return Container.ResolveAgentAddress(aid.Name)
+	}
+	_, address, err := Container.ResolveAgentName(aid.Name)
+	return address, err
 }
 
 func (Container *Container) sendMessageToAnotherAgent(message Messages.Message, receiverId int, agentID int) {
@@ -190,33 +584,81 @@ func (Container *Container) sendMessageToAnotherAgent(message Messages.Message,
 	// function to send message to another agent
 
 	// check if the other agent is in the same Container
-	if _, exists := Container.agents[strconv.Itoa(receiverId)]; exists {
-		// send the message to the agent
-		select {
-		case Container.agents[strconv.Itoa(receiverId)].MailBox <- message:
-		default:
-		}
+	if agent := Container.GetAgent(strconv.Itoa(receiverId)); agent != nil {
+		// send the message to the agent, dropped if the mailbox is full
+		agent.Deliver(message, false)
 	} else {
-
-		// Resolve the agent address
 		receiverIdStr := strconv.Itoa(receiverId)
-		receiverAdress, err := Container.ResolveAgentAddress(receiverIdStr)
+		err := Container.sendToAgent(message, receiverIdStr)
+		if staleAddress(err) {
+			// the cached address may be outdated, resolve the agent once more
+			err = Container.sendToAgent(message, receiverIdStr)
+		}
 		if err != nil {
-			log.Fatalf("Failed to resolve agent address: %v", err)
+			log.Printf("Message to agent %s dropped: %v", receiverIdStr, err)
 		}
-		// Send the message
-		_, err = Container.networkService.SendMessage(message, receiverAdress)
+	}
+}
+
+// sendToAgent sends a message to the container of a remote agent. The cached address of the agent
+// is evicted when the container cannot be reached, or answers that it does not host the agent.
+func (Container *Container) sendToAgent(message Messages.Message, agentID string) error {
+	address, err := Container.ResolveAgentAddress(agentID)
+	if err != nil {
+		return err
+	}
+	_, err = Container.networkService.SendMessage(context.Background(), message, address)
+	switch {
+	case errors.Is(err, NetworkService.ErrConnectionLost):
+		Container.resolutions.invalidate(nil, address)
+	case staleAddress(err):
+		Container.resolutions.invalidate([]string{agentID}, "")
+	}
+	return err
+}
+
+// staleAddress tells whether err shows the address an agent was resolved to is outdated:
+// the container is gone, or does not host the agent anymore.
+func staleAddress(err error) bool {
+	var remoteError *NetworkService.RemoteError
+	if errors.As(err, &remoteError) {
+		return strings.HasPrefix(remoteError.Message, YellowPage.ErrUnknownAgent.Error())
+	}
+	return errors.Is(err, NetworkService.ErrConnectionLost)
+}
+
+// sendToReceivers delivers an agent message to each of its receivers,
+// remote receivers get a single copy per container. A receiver that cannot be reached does not
+// keep the message from the others, the errors are joined.
+func (Container *Container) sendToReceivers(message Messages.Message) error {
+	var errs []error
+	addresses := make(map[string]bool)
+	for _, receiver := range message.Receivers {
+		if agent := Container.localAgent(receiver); agent != nil {
+			agent.Deliver(message, false)
+			continue
+		}
+		address, err := Container.resolveReceiver(receiver)
 		if err != nil {
-			log.Fatalf("Failed to send message to agent: %v", err)
+			errs = append(errs, err)
+			continue
+		}
+		addresses[address] = true
+	}
+	for address := range addresses {
+		if _, err := Container.networkService.SendMessage(context.Background(), message, address); err != nil {
+			// the container may be gone, resolve its agents again next time
+			Container.resolutions.invalidate(nil, address)
+			errs = append(errs, fmt.Errorf("failed to send the message to %s: %w", address, err))
 		}
 	}
+	return errors.Join(errs...)
 }
 
 func (Container *Container) GetSyncChannelWithAgent(sourceAgentID, agentId int) (chan Messages.Message, error) {
 	// ask agent to return a newly created channel
 	// check if the agent is in the same Container
-	if _, exists := Container.agents[strconv.Itoa(agentId)]; exists {
-		agent := Container.agents[strconv.Itoa(agentId)]
+	if agent := Container.GetAgent(strconv.Itoa(agentId)); agent != nil {
 		return agent.GiveNewChannel()
 	} else {
 
@@ -224,33 +666,39 @@ func (Container *Container) GetSyncChannelWithAgent(sourceAgentID, agentId int)
 		agentIdStr := strconv.Itoa(agentId)
 		agentAdress, err := Container.ResolveAgentAddress(agentIdStr)
 		if err != nil {
-			log.Fatalf("Failed to resolve agent address: %v", err)
+			return nil, err
 		}
 
 		// Prepare the message
 		payload := Messages.SetSyncCommunicationPayload{AgentID: agentId}
-		payloadStr, _ := json.Marshal(payload) // handle error properly
+		content, err := Messages.Encode(Messages.SetSyncCommunicationContent, payload)
+		if err != nil {
+			return nil, err
+		}
 		message := Messages.Message{
 			Type:           Messages.SetSyncCommunication,
 			Sender:         Container.localAdress,
 			ContentType:    Messages.SetSyncCommunicationContent,
-			Content:        string(payloadStr),
+			Content:        content,
 			ExpectResponse: true,
 		}
 		// Send the message and wait for a response
-		response, err := Container.networkService.SendMessage(message, agentAdress)
+		response, err := Container.networkService.SendMessage(context.Background(), message, agentAdress)
 		if err != nil {
-			log.Fatalf("Failed to get sync channel with agent: %v", err)
+			return nil, fmt.Errorf("failed to get sync channel with agent %d: %w", agentId, err)
 		}
 		// Parse the response
-		var answerPayload Messages.SetSyncCommunicationAnswerPayload
-		if err := json.Unmarshal([]byte(response.Content), &answerPayload); err != nil {
-			log.Fatalf("Failed to parse get sync channel with agent response: %v", err)
+		answerPayload, err := Messages.Decode[Messages.SetSyncCommunicationAnswerPayload](response)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse get sync channel with agent response: %w", err)
 		}
 		if !answerPayload.Success {
 			return nil, fmt.Errorf("Failed to get sync channel with agent")
 		}
 		channel, err := Container.networkService.CreateSyncChannel(sourceAgentID, agentAdress)
+		if err != nil {
+			return nil, err
+		}
 		go Container.networkService.ListenToSyncChannel(channel, agentAdress)
 		return channel, nil
 
@@ -258,16 +706,115 @@ func (Container *Container) GetSyncChannelWithAgent(sourceAgentID, agentId int)
 
 }
 
+// RegisterHandler makes the container process the incoming messages of type messageType,
+// such as an application message type numbered from Messages.UserMessageType.
+func (Container *Container) RegisterHandler(messageType Messages.MessageType, handler NetworkService.Handler) {
+	Container.networkService.RegisterHandler(messageType, handler)
+}
+
+// SendMessage sends a message to the container at address and waits for the response if the message expects one,
+// until ctx is done or the request timeout passes, see NetworkService.SendMessage.
+func (Container *Container) SendMessage(ctx context.Context, message Messages.Message, address string) (Messages.Message, error) {
+	message.Sender = Container.localAdress
+	return Container.networkService.SendMessage(ctx, message, address)
+}
+
+// QueueStats returns the state of the outbound queue of each connection of the container, by peer.
+func (Container *Container) QueueStats() map[string]NetworkService.QueueStats {
+	return Container.networkService.QueueStats()
+}
+
 func (Container *Container) GetAgent(agentID string) *Agent.Agent {
+	Container.agentsMutex.RLock()
+	defer Container.agentsMutex.RUnlock()
 	return Container.agents[agentID]
 }
 
+// UseWorkerPool makes Start run the agents on a pool of workers instead of one goroutine per agent.
+// It must be called before Start. The agents added after Start join the pool at once.
+func (Container *Container) UseWorkerPool(workers int) {
+	Container.scheduler = NewScheduler(workers)
+}
+
 func (Container *Container) Start() {
+	Container.agentsMutex.Lock()
+	defer Container.agentsMutex.Unlock()
+	if Container.scheduler != nil {
+		if Container.schedulerStarted {
+			// the agents added since joined the pool already
+			return
+		}
+		Container.scheduler.Start(Container.ctx)
+		Container.schedulerStarted = true
+		for _, agent := range Container.agents {
+			Container.scheduler.Add(Container.ctx, agent)
+		}
+		return
+	}
 	for _, agent := range Container.agents {
-		go agent.Start()
+		go agent.Start(Container.ctx)
+	}
+}
+
+// Stop kills every agent of the container and removes the container from the main container.
+func (Container *Container) Stop() {
+	Container.cancel()
+	if Container.currentMain() != "" {
+		if err := Container.deregisterContainer(); err != nil {
+			log.Printf("Failed to deregister container %s: %v", Container.localAdress, err)
+		}
 	}
 }
 
+// Stop kills every agent of the main container, stops feeding its standbys and closes its yellow page.
+func (MainContainer *MainContainer) Stop() {
+	MainContainer.Container.Stop()
+	MainContainer.replicasMutex.Lock()
+	replicas := make([]*replica, 0, len(MainContainer.replicas))
+	for _, standby := range MainContainer.replicas {
+		replicas = append(replicas, standby)
+	}
+	MainContainer.replicasMutex.Unlock()
+	for _, standby := range replicas {
+		MainContainer.dropReplica(standby)
+	}
+	if err := MainContainer.yellowPage.Close(); err != nil {
+		log.Printf("Failed to close the yellow page: %v", err)
+	}
+}
+
+// Shutdown stops the container, then closes its connections and its server so that its address
+// can be used again, waiting for them until ctx is done.
+func (Container *Container) Shutdown(ctx context.Context) error {
+	Container.Stop()
+	return Container.networkService.Shutdown(ctx)
+}
+
+// Shutdown stops the main container, then closes its connections and its server.
+func (MainContainer *MainContainer) Shutdown(ctx context.Context) error {
+	MainContainer.Stop()
+	return MainContainer.networkService.Shutdown(ctx)
+}
+
+func (Container *Container) deregisterContainer() error {
+	payload := Messages.DeregisterContainerPayload{Address: Container.localAdress}
+	content, err := Messages.Encode(Messages.DeregisterContainerContent, payload)
+	if err != nil {
+		return err
+	}
+	message := Messages.Message{
+		Type:           Messages.DeregisterContainer,
+		Sender:         Container.localAdress,
+		ContentType:    Messages.DeregisterContainerContent,
+		Content:        content,
+		ExpectResponse: true,
+	}
+	_, err = Container.sendToMain(context.Background(), message)
+	return err
+}
+
 func (Container *Container) UpdateAgentSyncChannel(agentID string, channel chan Messages.Message) {
-	Container.agents[agentID].SynchronousChannel = channel
+	if agent := Container.GetAgent(agentID); agent != nil {
+		agent.SetSyncChannel(channel)
+	}
 }
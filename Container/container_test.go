@@ -0,0 +1,137 @@
+package Container
+
+import (
+	"FrameworkMultiAgents/Agent"
+	"FrameworkMultiAgents/Messages"
+	"FrameworkMultiAgents/NetworkService"
+	"FrameworkMultiAgents/YellowPage"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startMainContainer starts a main container, stopped at the end of the test.
+func startMainContainer(t *testing.T, address string, opts ...Option) *MainContainer {
+	t.Helper()
+	mainContainer, err := NewMainContainer(address, opts...)
+	if err != nil {
+		t.Fatalf("NewMainContainer: %v", err)
+	}
+	t.Cleanup(func() { mainContainer.Shutdown(shutdownContext(t)) })
+	return mainContainer
+}
+
+func shutdownContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+// eventually waits for condition to hold, failing the test after a few seconds.
+func eventually(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting until %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestUnreadableJournal(t *testing.T) {
+	directory := t.TempDir()
+	if err := os.WriteFile(filepath.Join(directory, "yellowpage.log"), []byte("not json\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	transport := NetworkService.NewMemoryTransport()
+	if _, err := NewMainContainer("main", WithTransport(transport), WithPersistence(directory)); err == nil {
+		t.Error("NewMainContainer loaded a corrupted journal")
+	}
+	if _, err := NewStandbyContainer("standby", []string{"main", "standby"}, WithTransport(transport), WithPersistence(directory)); err == nil {
+		t.Error("NewStandbyContainer loaded a corrupted journal")
+	}
+}
+
+func TestAddressInUse(t *testing.T) {
+	transport := NetworkService.NewMemoryTransport()
+	startMainContainer(t, "main", WithTransport(transport))
+	if _, err := NewMainContainer("main", WithTransport(transport)); err == nil {
+		t.Error("two main containers listen on the same address")
+	}
+	if _, err := NewStandbyContainer("main", []string{"main"}, WithTransport(transport)); err == nil {
+		t.Error("a standby listens on the address of the main container")
+	}
+	if _, err := NewContainer("main", "main", WithTransport(transport)); err == nil {
+		t.Error("a container listens on the address of the main container")
+	}
+}
+
+// startRogue starts a network service at address registered to the main container as a container,
+// hosting an agent named agentName, and stopped at the end of the test.
+func startRogue(t *testing.T, transport NetworkService.Transport, address, agentName string) *NetworkService.NetworkService {
+	t.Helper()
+	rogue := NetworkService.NewNetworkService("main", address)
+	rogue.SetTransport(transport)
+	if err := rogue.Listen(); err != nil {
+		t.Fatal(err)
+	}
+	go rogue.Start()
+	t.Cleanup(func() { rogue.Shutdown(shutdownContext(t)) })
+	for _, request := range []struct {
+		messageType Messages.MessageType
+		contentType Messages.ContentType
+		payload     any
+	}{
+		{Messages.RegisterContainer, Messages.RegisterContainerContent, Messages.RegisterContainerPayload{Address: address}},
+		{Messages.RegisterAgent, Messages.RegisterAgentContent, Messages.RegisterAgentPayload{ContainerID: address, Name: agentName}},
+	} {
+		message := Messages.Message{Type: request.messageType, Sender: address, ExpectResponse: true}
+		if err := message.SetContent(request.contentType, request.payload); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := rogue.SendMessage(context.Background(), message, "main"); err != nil {
+			t.Fatalf("registration of %s: %v", address, err)
+		}
+	}
+	return rogue
+}
+
+func TestSendToEveryReceiver(t *testing.T) {
+	transport := NetworkService.NewMemoryTransport()
+	startMainContainer(t, "main", WithTransport(transport))
+	containers := make([]*Container, 2)
+	for i := range containers {
+		container, err := NewContainer("main", fmt.Sprintf("container-%d", i+1), WithTransport(transport))
+		if err != nil {
+			t.Fatalf("NewContainer: %v", err)
+		}
+		t.Cleanup(func() { container.Shutdown(shutdownContext(t)) })
+		containers[i] = container
+	}
+	agents := make(map[string]*Agent.Agent)
+	for name, container := range map[string]*Container{"sender": containers[0], "local": containers[0], "remote": containers[1]} {
+		agentID, err := container.AddAgent(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		agents[name] = container.GetAgent(agentID)
+	}
+	// the container of gone stops without deregistering
+	startRogue(t, transport, "rogue", "gone").Shutdown(shutdownContext(t))
+
+	message := Messages.Text("ping")
+	message.Receivers = []string{"gone", "local", "missing", "remote"}
+	err := agents["sender"].Send(message)
+	if !errors.Is(err, YellowPage.ErrUnknownAgent) || !strings.Contains(err.Error(), "rogue") {
+		t.Errorf("Send: %v, want the errors of missing and gone", err)
+	}
+	for _, name := range []string{"local", "remote"} {
+		eventually(t, name+" gets the message", func() bool { return len(agents[name].MailBox) == 1 })
+	}
+}
@@ -0,0 +1,139 @@
+package Container
+
+import (
+	"FrameworkMultiAgents/Messages"
+	"FrameworkMultiAgents/NetworkService"
+	"FrameworkMultiAgents/YellowPage"
+	"context"
+)
+
+// RegisterService adds an agent description to the Directory Facilitator of the main container.
+func (Container *Container) RegisterService(description YellowPage.AgentDescription) error {
+	return Container.RegisterServiceContext(context.Background(), description)
+}
+
+// RegisterServiceContext is RegisterService, giving up when ctx is done.
+func (Container *Container) RegisterServiceContext(ctx context.Context, description YellowPage.AgentDescription) error {
+	if Container.directory != nil {
+		return Container.directory.RegisterService(description)
+	}
+	_, err := Container.requestMain(ctx, Messages.RegisterService, Messages.ServiceDescriptionContent, Messages.ServiceDescriptionPayload{
+		Description: description,
+	})
+	return err
+}
+
+// ModifyService replaces the description of an agent in the Directory Facilitator.
+func (Container *Container) ModifyService(description YellowPage.AgentDescription) error {
+	return Container.ModifyServiceContext(context.Background(), description)
+}
+
+// ModifyServiceContext is ModifyService, giving up when ctx is done.
+func (Container *Container) ModifyServiceContext(ctx context.Context, description YellowPage.AgentDescription) error {
+	if Container.directory != nil {
+		return Container.directory.ModifyService(description)
+	}
+	_, err := Container.requestMain(ctx, Messages.ModifyService, Messages.ServiceDescriptionContent, Messages.ServiceDescriptionPayload{
+		Description: description,
+	})
+	return err
+}
+
+// DeregisterService removes an agent from the Directory Facilitator.
+func (Container *Container) DeregisterService(agentID string) error {
+	return Container.DeregisterServiceContext(context.Background(), agentID)
+}
+
+// DeregisterServiceContext is DeregisterService, giving up when ctx is done.
+func (Container *Container) DeregisterServiceContext(ctx context.Context, agentID string) error {
+	if Container.directory != nil {
+		return Container.directory.DeregisterService(agentID)
+	}
+	_, err := Container.requestMain(ctx, Messages.DeregisterService, Messages.DeregisterServiceContent, Messages.DeregisterServicePayload{
+		AgentID: agentID,
+	})
+	return err
+}
+
+// SearchServices returns the agents offering a service that matches template.
+func (Container *Container) SearchServices(template YellowPage.ServiceDescription, maxResults int) ([]YellowPage.AgentDescription, error) {
+	return Container.SearchServicesContext(context.Background(), template, maxResults)
+}
+
+// SearchServicesContext is SearchServices, giving up when ctx is done.
+func (Container *Container) SearchServicesContext(ctx context.Context, template YellowPage.ServiceDescription, maxResults int) ([]YellowPage.AgentDescription, error) {
+	if Container.directory != nil {
+		return Container.directory.SearchServices(template, maxResults), nil
+	}
+	response, err := Container.requestMain(ctx, Messages.SearchService, Messages.SearchServiceContent, Messages.SearchServicePayload{
+		Template:   template,
+		MaxResults: maxResults,
+	})
+	if err != nil {
+		return nil, err
+	}
+	answerPayload, err := Messages.Decode[Messages.SearchServiceAnswerPayload](response)
+	if err != nil {
+		return nil, err
+	}
+	return answerPayload.Results, nil
+}
+
+// requestMain sends a request to the main container and waits for its response.
+func (Container *Container) requestMain(ctx context.Context, messageType Messages.MessageType, contentType Messages.ContentType, payload any) (Messages.Message, error) {
+	message := Messages.Message{
+		Type:           messageType,
+		Sender:         Container.localAdress,
+		ExpectResponse: true,
+	}
+	if err := message.SetContent(contentType, payload); err != nil {
+		return Messages.Message{}, err
+	}
+	return Container.sendToMain(ctx, message)
+}
+
+// registerDirectoryHandlers serves the Directory Facilitator to the other containers.
+func (MainContainer *MainContainer) registerDirectoryHandlers() {
+	ns := MainContainer.networkService
+	ns.RegisterHandler(Messages.RegisterService, func(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+		payload, err := Messages.Decode[Messages.ServiceDescriptionPayload](message)
+		if err != nil {
+			return Messages.Message{}, err
+		}
+		if err := MainContainer.RegisterService(payload.Description); err != nil {
+			return Messages.Message{}, err
+		}
+		return NetworkService.NewResponse(Messages.RegisterServiceAnswer, Messages.ServiceAnswerContent, Messages.ServiceAnswerPayload{Success: true})
+	})
+	ns.RegisterHandler(Messages.ModifyService, func(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+		payload, err := Messages.Decode[Messages.ServiceDescriptionPayload](message)
+		if err != nil {
+			return Messages.Message{}, err
+		}
+		if err := MainContainer.ModifyService(payload.Description); err != nil {
+			return Messages.Message{}, err
+		}
+		return NetworkService.NewResponse(Messages.ModifyServiceAnswer, Messages.ServiceAnswerContent, Messages.ServiceAnswerPayload{Success: true})
+	})
+	ns.RegisterHandler(Messages.DeregisterService, func(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+		payload, err := Messages.Decode[Messages.DeregisterServicePayload](message)
+		if err != nil {
+			return Messages.Message{}, err
+		}
+		if err := MainContainer.DeregisterService(payload.AgentID); err != nil {
+			return Messages.Message{}, err
+		}
+		return NetworkService.NewResponse(Messages.DeregisterServiceAnswer, Messages.ServiceAnswerContent, Messages.ServiceAnswerPayload{Success: true})
+	})
+	ns.RegisterHandler(Messages.SearchService, func(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+		payload, err := Messages.Decode[Messages.SearchServicePayload](message)
+		if err != nil {
+			return Messages.Message{}, err
+		}
+		results, err := MainContainer.SearchServices(payload.Template, payload.MaxResults)
+		if err != nil {
+			return Messages.Message{}, err
+		}
+		return NetworkService.NewResponse(Messages.SearchServiceAnswer, Messages.SearchServiceAnswerContent, Messages.SearchServiceAnswerPayload{Results: results})
+	})
+}
@@ -0,0 +1,89 @@
+package Container
+
+import (
+	"FrameworkMultiAgents/Messages"
+	"FrameworkMultiAgents/NetworkService"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// currentMain returns the address of the main container the requests go to, empty in a main container.
+func (Container *Container) currentMain() string {
+	Container.mainMutex.Lock()
+	defer Container.mainMutex.Unlock()
+	return Container.mainServerAdress
+}
+
+// sendToMain sends a request to the main container. When the main container is unreachable or is
+// not the primary anymore, the request is sent again to the primary found among the main addresses.
+func (Container *Container) sendToMain(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+	mainAddress := Container.currentMain()
+	response, err := Container.networkService.SendMessage(ctx, message, mainAddress)
+	if err == nil || !needsFailover(err) || len(Container.mainAddresses) < 2 || ctx.Err() != nil {
+		return response, err
+	}
+	if failoverErr := Container.failover(mainAddress); failoverErr != nil {
+		return response, fmt.Errorf("%w (%v)", err, failoverErr)
+	}
+	return Container.networkService.SendMessage(ctx, message, Container.currentMain())
+}
+
+// needsFailover tells whether the main container failed, rather than the request: it refused the request
+// as a standby, or the connection to it was lost. A request that timed out may have been handled,
+// it is not sent again.
+func needsFailover(err error) bool {
+	var remoteError *NetworkService.RemoteError
+	if errors.As(err, &remoteError) {
+		return remoteError.Message == ErrNotPrimary.Error()
+	}
+	return errors.Is(err, NetworkService.ErrConnectionLost) && !errors.Is(err, NetworkService.ErrTimeout) && !errors.Is(err, context.Canceled)
+}
+
+// failover looks for the primary main container until a standby had the time to take over.
+func (Container *Container) failover(failed string) error {
+	Container.mainMutex.Lock()
+	defer Container.mainMutex.Unlock()
+	if Container.mainServerAdress != failed {
+		// another request already found the new primary
+		return nil
+	}
+	deadline := time.Now().Add(2 * time.Duration(Container.missedHeartbeats) * Container.heartbeatInterval)
+	for {
+		for _, address := range Container.mainAddresses {
+			status, err := Container.mainStatus(address)
+			if err != nil || !status.Primary {
+				continue
+			}
+			if address != failed {
+				log.Printf("Main container %s failed, container %s now uses %s", failed, Container.localAdress, address)
+			}
+			Container.mainServerAdress = address
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("no primary main container among %v", Container.mainAddresses)
+		}
+		time.Sleep(Container.heartbeatInterval)
+	}
+}
+
+// mainStatus asks the main container at address whether it is the primary one.
+func (Container *Container) mainStatus(address string) (Messages.MainStatusAnswerPayload, error) {
+	message := Messages.Message{
+		Type:           Messages.MainStatus,
+		Sender:         Container.localAdress,
+		ExpectResponse: true,
+		ReplyBy:        time.Now().Add(Container.heartbeatInterval),
+	}
+	if err := message.SetContent(Messages.MainStatusContent, Messages.MainStatusPayload{Address: Container.localAdress}); err != nil {
+		return Messages.MainStatusAnswerPayload{}, err
+	}
+	response, err := Container.networkService.SendMessage(context.Background(), message, address)
+	if err != nil {
+		return Messages.MainStatusAnswerPayload{}, err
+	}
+	return Messages.Decode[Messages.MainStatusAnswerPayload](response)
+}
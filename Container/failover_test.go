@@ -0,0 +1,83 @@
+package Container
+
+import (
+	"FrameworkMultiAgents/Messages"
+	"FrameworkMultiAgents/NetworkService"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestNeedsFailover(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"connection lost", fmt.Errorf("%w: EOF", NetworkService.ErrConnectionLost), true},
+		{"refused by a standby", &NetworkService.RemoteError{Address: "standby", MessageType: Messages.RegisterAgent, Message: ErrNotPrimary.Error()}, true},
+		{"wrapped refusal of a standby", fmt.Errorf("failed: %w", &NetworkService.RemoteError{Message: ErrNotPrimary.Error()}), true},
+		{"other remote error", &NetworkService.RemoteError{Address: "main", Message: ErrNotAdmitted.Error()}, false},
+		{"timeout", fmt.Errorf("%w: %w", NetworkService.ErrTimeout, context.DeadlineExceeded), false},
+		{"lost while timing out", fmt.Errorf("%w: %w", NetworkService.ErrConnectionLost, NetworkService.ErrTimeout), false},
+		{"cancelled", fmt.Errorf("%w: %w", NetworkService.ErrConnectionLost, context.Canceled), false},
+		{"closed", NetworkService.ErrClosed, false},
+		{"other error", errors.New("encoding failed"), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := needsFailover(test.err); got != test.want {
+				t.Errorf("needsFailover(%v) = %v, want %v", test.err, got, test.want)
+			}
+		})
+	}
+}
+
+func TestFailover(t *testing.T) {
+	transport := NetworkService.NewMemoryTransport()
+	heartbeat := WithHeartbeat(20*time.Millisecond, 3)
+	mainContainer, err := NewMainContainer("main", WithTransport(transport), heartbeat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	standby, err := NewStandbyContainer("standby", []string{"main", "standby"}, WithTransport(transport), heartbeat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { standby.Shutdown(shutdownContext(t)) })
+	eventually(t, "the standby follows the main container", func() bool { return standby.followed() == "main" })
+
+	reconnect := WithReconnectPolicy(NetworkService.ReconnectPolicy{InitialBackoff: 10 * time.Millisecond, Multiplier: 1, MaxAttempts: 2})
+	container, err := NewContainer("main", "container-1", WithTransport(transport), WithMainAddresses("standby"), heartbeat, reconnect, WithResolutionTTL(0))
+	if err != nil {
+		t.Fatalf("NewContainer: %v", err)
+	}
+	t.Cleanup(func() { container.Shutdown(shutdownContext(t)) })
+	agentID, err := container.AddAgent("pong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the standby knows the agent", func() bool {
+		_, err := standby.yellowPage.ResolveAgentAddress(agentID)
+		return err == nil
+	})
+	if standby.IsPrimary() {
+		t.Fatal("the standby took over while the main container is alive")
+	}
+
+	mainContainer.Shutdown(shutdownContext(t))
+	eventually(t, "the standby takes over", standby.IsPrimary)
+
+	address, err := container.ResolveAgentAddress(agentID)
+	if err != nil || address != "container-1" {
+		t.Errorf("ResolveAgentAddress after the failover: %q, %v", address, err)
+	}
+	if main := container.currentMain(); main != "standby" {
+		t.Errorf("container uses %s, want standby", main)
+	}
+	if _, err := container.AddAgent("ping"); err != nil {
+		t.Errorf("AddAgent after the failover: %v", err)
+	}
+}
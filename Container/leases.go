@@ -0,0 +1,104 @@
+package Container
+
+import (
+	"FrameworkMultiAgents/Messages"
+	"FrameworkMultiAgents/NetworkService"
+	"FrameworkMultiAgents/YellowPage"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+// DefaultLeaseDuration is how long the main container keeps a container registered without a heartbeat,
+// and MinLeaseDuration the shortest lease it grants, see WithLease.
+const (
+	DefaultLeaseDuration = 10 * time.Second
+	MinLeaseDuration     = 10 * time.Millisecond
+)
+
+// renewLease sends the heartbeats of the container to the main container. A container whose lease
+// expired has been deregistered with its agents, it stops.
+func (Container *Container) renewLease() {
+	interval := Container.heartbeatInterval
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-Container.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		duration, err := Container.sendHeartbeat()
+		var remoteError *NetworkService.RemoteError
+		if errors.As(err, &remoteError) && strings.HasPrefix(remoteError.Message, YellowPage.ErrLeaseExpired.Error()) {
+			log.Printf("Container %s stops: %v", Container.localAdress, err)
+			Container.cancel()
+			return
+		}
+		if err != nil {
+			log.Printf("Heartbeat of container %s failed: %v", Container.localAdress, err)
+			continue
+		}
+		// renew at least three times per lease
+		if duration >= MinLeaseDuration && duration/3 < interval {
+			interval = duration / 3
+			ticker.Reset(interval)
+		}
+	}
+}
+
+func (Container *Container) sendHeartbeat() (time.Duration, error) {
+	response, err := Container.requestMain(Container.ctx, Messages.RenewLease, Messages.RenewLeaseContent, Messages.RenewLeasePayload{
+		Address: Container.localAdress,
+	})
+	if err != nil {
+		return 0, err
+	}
+	answerPayload, err := Messages.Decode[Messages.RenewLeaseAnswerPayload](response)
+	if err != nil {
+		return 0, err
+	}
+	return answerPayload.Duration, nil
+}
+
+// registerLeaseHandler renews the leases of the containers on their heartbeats.
+func (MainContainer *MainContainer) registerLeaseHandler() {
+	MainContainer.networkService.RegisterHandler(Messages.RenewLease, func(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+		payload, err := Messages.Decode[Messages.RenewLeasePayload](message)
+		if err != nil {
+			return Messages.Message{}, err
+		}
+		if MainContainer.leaseDuration > 0 {
+			if err := MainContainer.yellowPage.RenewLease(payload.Address, time.Now().Add(MainContainer.leaseDuration)); err != nil {
+				return Messages.Message{}, err
+			}
+		}
+		return NetworkService.NewResponse(Messages.RenewLeaseAnswer, Messages.RenewLeaseAnswerContent, Messages.RenewLeaseAnswerPayload{
+			Duration: MainContainer.leaseDuration,
+		})
+	})
+}
+
+// expireLeases deregisters the containers that missed their heartbeats for a whole lease,
+// while the main container is the primary one. The subscribers are told their agents are gone.
+func (MainContainer *MainContainer) expireLeases() {
+	ticker := time.NewTicker(MainContainer.leaseDuration / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-MainContainer.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !MainContainer.IsPrimary() {
+			continue
+		}
+		now := time.Now()
+		MainContainer.yellowPage.RenewLease(MainContainer.localAdress, now.Add(MainContainer.leaseDuration))
+		for _, address := range MainContainer.yellowPage.ExpireLeases(now, MainContainer.leaseDuration) {
+			log.Printf("Lease of container %s expired, deregistered with its agents", address)
+		}
+	}
+}
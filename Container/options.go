@@ -0,0 +1,197 @@
+package Container
+
+import (
+	"FrameworkMultiAgents/NetworkService"
+	"net"
+	"time"
+)
+
+// Option configures a container created by NewContainer, NewMainContainer or NewStandbyContainer.
+type Option func(*options)
+
+type options struct {
+	persistenceDirectory string
+	snapshotInterval     int
+	mainAddresses        []string
+	heartbeatInterval    time.Duration
+	missedHeartbeats     int
+	resolutionTTL        time.Duration
+	platformName         string
+	leaseDuration        time.Duration
+	requestTimeout       time.Duration
+	reconnectPolicy      NetworkService.ReconnectPolicy
+	queueLength          int
+	queuePolicy          NetworkService.QueuePolicy
+	transport            NetworkService.Transport
+	listener             net.Listener
+	joinToken            string
+	admission            AdmissionPolicy
+}
+
+// DefaultHeartbeatInterval and DefaultMissedHeartbeats set how fast a standby takes over a failed primary.
+const (
+	DefaultHeartbeatInterval = time.Second
+	DefaultMissedHeartbeats  = 3
+)
+
+func newOptions(opts []Option) options {
+	settings := options{
+		heartbeatInterval: DefaultHeartbeatInterval,
+		missedHeartbeats:  DefaultMissedHeartbeats,
+		resolutionTTL:     DefaultResolutionTTL,
+		leaseDuration:     DefaultLeaseDuration,
+		requestTimeout:    NetworkService.DefaultRequestTimeout,
+		reconnectPolicy:   NetworkService.DefaultReconnectPolicy,
+		queueLength:       NetworkService.DefaultQueueLength,
+		queuePolicy:       NetworkService.BlockWhenFull,
+	}
+	for _, opt := range opts {
+		opt(&settings)
+	}
+	return settings
+}
+
+// WithPersistence keeps the yellow page of the main container in directory, as a log of its operations
+// and periodic snapshots. A main container restarted with the same directory keeps the registered
+// containers and agents and never hands out an agent ID twice.
+func WithPersistence(directory string) Option {
+	return func(settings *options) {
+		settings.persistenceDirectory = directory
+	}
+}
+
+// WithSnapshotInterval sets the number of operations logged between two snapshots of a persistent yellow page.
+func WithSnapshotInterval(operations int) Option {
+	return func(settings *options) {
+		settings.snapshotInterval = operations
+	}
+}
+
+// WithMainAddresses gives a container the standby main containers to turn to, in order,
+// when its main container stops answering.
+func WithMainAddresses(addresses ...string) Option {
+	return func(settings *options) {
+		settings.mainAddresses = append(settings.mainAddresses, addresses...)
+	}
+}
+
+// WithHeartbeat sets how often a standby checks the primary main container, and how many checks
+// must fail before it takes over. Containers look for the new primary for twice that time,
+// and renew their lease at the same interval. An interval or a count below 1 falls back to
+// DefaultHeartbeatInterval or DefaultMissedHeartbeats.
+func WithHeartbeat(interval time.Duration, missed int) Option {
+	return func(settings *options) {
+		if interval <= 0 {
+			interval = DefaultHeartbeatInterval
+		}
+		if missed < 1 {
+			missed = DefaultMissedHeartbeats
+		}
+		settings.heartbeatInterval = interval
+		settings.missedHeartbeats = missed
+	}
+}
+
+// WithResolutionTTL sets how long a container caches the agent addresses resolved by the main container,
+// 0 disables the cache.
+func WithResolutionTTL(ttl time.Duration) Option {
+	return func(settings *options) {
+		settings.resolutionTTL = ttl
+	}
+}
+
+// WithPlatformName names the platform of a main container, its agents are named name@platform.
+// The platform is named after the address of its first main container by default,
+// the standby main containers must be given the same name.
+func WithPlatformName(name string) Option {
+	return func(settings *options) {
+		settings.platformName = name
+	}
+}
+
+// WithLease sets how long the main container keeps a container registered without a heartbeat,
+// before deregistering it with its agents. 0 keeps the containers until they deregister, a duration
+// below MinLeaseDuration is raised to it.
+func WithLease(duration time.Duration) Option {
+	return func(settings *options) {
+		switch {
+		case duration <= 0:
+			duration = 0
+		case duration < MinLeaseDuration:
+			duration = MinLeaseDuration
+		}
+		settings.leaseDuration = duration
+	}
+}
+
+// WithRequestTimeout sets how long the container waits for the response to a request, such as
+// the registration of an agent or the resolution of an address, 0 waits without limit.
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(settings *options) {
+		settings.requestTimeout = timeout
+	}
+}
+
+// WithReconnectPolicy sets how the container dials again the peers it lost the connection to,
+// and how many messages it buffers meanwhile. The main container deregisters a container
+// once it could not reconnect to it.
+func WithReconnectPolicy(policy NetworkService.ReconnectPolicy) Option {
+	return func(settings *options) {
+		settings.reconnectPolicy = policy
+	}
+}
+
+// WithOutboundQueue sets the number of messages waiting to be written on each connection of the container,
+// and whether sending blocks or fails with NetworkService.ErrQueueFull when a queue is full.
+func WithOutboundQueue(length int, policy NetworkService.QueuePolicy) Option {
+	return func(settings *options) {
+		settings.queueLength = length
+		settings.queuePolicy = policy
+	}
+}
+
+// WithTransport sets how the container connects to the others, over websockets by default.
+// The containers sharing a NetworkService.NewMemoryTransport run in one program without opening ports,
+// their addresses are then plain names.
+func WithTransport(transport NetworkService.Transport) Option {
+	return func(settings *options) {
+		settings.transport = transport
+	}
+}
+
+// WithListener serves the container on an already bound listener, such as one opened by
+// net.Listen("tcp", "localhost:0"), instead of listening on its address. The container then takes
+// the address of the listener, see Address.
+func WithListener(listener net.Listener) Option {
+	return func(settings *options) {
+		settings.listener = listener
+	}
+}
+
+// WithJoinToken gives the join token or pre-shared key the container presents to the main container
+// when registering, or a standby main container to the primary, see AdmissionPolicy.
+func WithJoinToken(token string) Option {
+	return func(settings *options) {
+		settings.joinToken = token
+	}
+}
+
+// WithAdmission sets which containers the main container registers, and how many agents they may host.
+// The refused containers get ErrNotAdmitted from NewContainer, and their agents ErrAgentQuota from AddAgent
+// once the quota is reached.
+func WithAdmission(policy AdmissionPolicy) Option {
+	return func(settings *options) {
+		settings.admission = policy
+	}
+}
+
+// listen binds the network service of a new container with its transport.
+func (settings options) listen(networkService *NetworkService.NetworkService) error {
+	if settings.transport != nil {
+		networkService.SetTransport(settings.transport)
+	}
+	if settings.listener != nil {
+		return networkService.ListenOn(settings.listener)
+	}
+	return networkService.Listen()
+}
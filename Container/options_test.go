@@ -0,0 +1,89 @@
+package Container
+
+import (
+	"FrameworkMultiAgents/Messages"
+	"FrameworkMultiAgents/NetworkService"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWithHeartbeat(t *testing.T) {
+	tests := []struct {
+		name         string
+		interval     time.Duration
+		missed       int
+		wantInterval time.Duration
+		wantMissed   int
+	}{
+		{"set", 20 * time.Millisecond, 5, 20 * time.Millisecond, 5},
+		{"no interval", 0, 5, DefaultHeartbeatInterval, 5},
+		{"negative interval", -time.Second, 5, DefaultHeartbeatInterval, 5},
+		{"no missed heartbeat", 20 * time.Millisecond, 0, 20 * time.Millisecond, DefaultMissedHeartbeats},
+		{"negative count", 20 * time.Millisecond, -1, 20 * time.Millisecond, DefaultMissedHeartbeats},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			settings := newOptions([]Option{WithHeartbeat(test.interval, test.missed)})
+			if settings.heartbeatInterval != test.wantInterval || settings.missedHeartbeats != test.wantMissed {
+				t.Errorf("heartbeat every %v, %d missed, want every %v, %d missed",
+					settings.heartbeatInterval, settings.missedHeartbeats, test.wantInterval, test.wantMissed)
+			}
+		})
+	}
+}
+
+func TestWithLease(t *testing.T) {
+	tests := []struct {
+		name     string
+		duration time.Duration
+		want     time.Duration
+	}{
+		{"set", time.Minute, time.Minute},
+		{"minimum", MinLeaseDuration, MinLeaseDuration},
+		{"below the minimum", time.Nanosecond, MinLeaseDuration},
+		{"disabled", 0, 0},
+		{"negative", -time.Second, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := newOptions([]Option{WithLease(test.duration)}).leaseDuration; got != test.want {
+				t.Errorf("lease of %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLeaseExpiry(t *testing.T) {
+	transport := NetworkService.NewMemoryTransport()
+	heartbeat := WithHeartbeat(20*time.Millisecond, 3)
+	mainContainer := startMainContainer(t, "main", WithTransport(transport), heartbeat, WithLease(200*time.Millisecond))
+	container, err := NewContainer("main", "container-1", WithTransport(transport), heartbeat)
+	if err != nil {
+		t.Fatalf("NewContainer: %v", err)
+	}
+	t.Cleanup(func() { container.Shutdown(shutdownContext(t)) })
+
+	// registered by hand, the silent container never renews its lease
+	silent := NetworkService.NewNetworkService("main", "silent")
+	silent.SetTransport(transport)
+	if err := silent.Listen(); err != nil {
+		t.Fatal(err)
+	}
+	go silent.Start()
+	t.Cleanup(func() { silent.Shutdown(shutdownContext(t)) })
+	message := Messages.Message{Type: Messages.RegisterContainer, Sender: "silent", ExpectResponse: true}
+	if err := message.SetContent(Messages.RegisterContainerContent, Messages.RegisterContainerPayload{Address: "silent"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := silent.SendMessage(context.Background(), message, "main"); err != nil {
+		t.Fatalf("registration: %v", err)
+	}
+
+	eventually(t, "the lease of the silent container expires", func() bool {
+		return !mainContainer.yellowPage.IsContainerRegistered("silent")
+	})
+	if !mainContainer.yellowPage.IsContainerRegistered("container-1") {
+		t.Error("the lease of the container sending heartbeats expired")
+	}
+}
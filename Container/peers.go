@@ -0,0 +1,177 @@
+package Container
+
+import (
+	"FrameworkMultiAgents/Messages"
+	"FrameworkMultiAgents/NetworkService"
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// actsFor returns an error wrapping NetworkService.ErrUntrustedPeer unless the request comes from the
+// container at address. The peer is the identifier of the connection, see NetworkService.Peer.
+func actsFor(ctx context.Context, address string) error {
+	peer, ok := NetworkService.Peer(ctx)
+	if !ok || peer != address {
+		return fmt.Errorf("%w: %s cannot act for %s", NetworkService.ErrUntrustedPeer, peer, address)
+	}
+	return nil
+}
+
+// actsForAgent returns an error unless the request comes from the container hosting the agent.
+// An unknown agent is left to the handler.
+func (MainContainer *MainContainer) actsForAgent(ctx context.Context, agentID string) error {
+	address, err := MainContainer.yellowPage.ResolveAgentAddress(agentID)
+	if err != nil {
+		return nil
+	}
+	return actsFor(ctx, address)
+}
+
+// fromMain returns an error wrapping NetworkService.ErrUntrustedPeer unless the request comes from
+// one of the main containers of the container.
+func (Container *Container) fromMain(ctx context.Context) error {
+	peer, _ := NetworkService.Peer(ctx)
+	for _, address := range Container.mainAddresses {
+		if peer == address {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s is not a main container", NetworkService.ErrUntrustedPeer, peer)
+}
+
+// registerDeathCheck makes the container kill its agents on behalf of its main containers only,
+// the other containers go through them, see MainContainer.registerDeathForwarding.
+func (Container *Container) registerDeathCheck() {
+	ns := Container.networkService
+	handler, ok := ns.Handler(Messages.Death)
+	if !ok {
+		return
+	}
+	ns.RegisterHandler(Messages.Death, func(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+		if err := Container.fromMain(ctx); err != nil {
+			return Messages.Message{}, err
+		}
+		return handler(ctx, message)
+	})
+}
+
+// registerDeathForwarding makes the main container kill the agents on behalf of the containers it admitted,
+// the Death message is forwarded to the container hosting the agent.
+func (MainContainer *MainContainer) registerDeathForwarding() {
+	ns := MainContainer.networkService
+	handler, ok := ns.Handler(Messages.Death)
+	if !ok {
+		return
+	}
+	ns.RegisterHandler(Messages.Death, func(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+		if _, err := MainContainer.admitted(ctx); err != nil {
+			return Messages.Message{}, err
+		}
+		payload, err := Messages.Decode[Messages.DeathPayload](message)
+		if err != nil {
+			return Messages.Message{}, err
+		}
+		address, err := MainContainer.yellowPage.ResolveAgentAddress(strconv.Itoa(payload.AgentID))
+		if err != nil {
+			return Messages.Message{}, err
+		}
+		if address == MainContainer.localAdress {
+			return handler(ctx, message)
+		}
+		message.Sender = MainContainer.localAdress
+		_, err = ns.SendMessage(ctx, message, address)
+		return Messages.Message{}, err
+	})
+}
+
+// registerPeerChecks makes the main container refuse the requests a container makes for another
+// container, or for the agents of another container: the addresses the requests carry must be
+// the one of the connection they come from.
+func (MainContainer *MainContainer) registerPeerChecks() {
+	checks := map[Messages.MessageType]func(ctx context.Context, message Messages.Message) error{
+		Messages.RegisterContainer: func(ctx context.Context, message Messages.Message) error {
+			payload, err := Messages.Decode[Messages.RegisterContainerPayload](message)
+			if err != nil {
+				return err
+			}
+			return actsFor(ctx, payload.Address)
+		},
+		Messages.RegisterAgent: func(ctx context.Context, message Messages.Message) error {
+			payload, err := Messages.Decode[Messages.RegisterAgentPayload](message)
+			if err != nil {
+				return err
+			}
+			return actsFor(ctx, payload.ContainerID)
+		},
+		Messages.DeregisterAgent: func(ctx context.Context, message Messages.Message) error {
+			payload, err := Messages.Decode[Messages.DeregisterAgentPayload](message)
+			if err != nil {
+				return err
+			}
+			return MainContainer.actsForAgent(ctx, payload.AgentID)
+		},
+		Messages.DeregisterContainer: func(ctx context.Context, message Messages.Message) error {
+			payload, err := Messages.Decode[Messages.DeregisterContainerPayload](message)
+			if err != nil {
+				return err
+			}
+			return actsFor(ctx, payload.Address)
+		},
+		Messages.RegisterService: func(ctx context.Context, message Messages.Message) error {
+			payload, err := Messages.Decode[Messages.ServiceDescriptionPayload](message)
+			if err != nil {
+				return err
+			}
+			return MainContainer.actsForAgent(ctx, payload.Description.AgentID)
+		},
+		Messages.DeregisterService: func(ctx context.Context, message Messages.Message) error {
+			payload, err := Messages.Decode[Messages.DeregisterServicePayload](message)
+			if err != nil {
+				return err
+			}
+			return MainContainer.actsForAgent(ctx, payload.AgentID)
+		},
+		Messages.SubscribeRegistry: func(ctx context.Context, message Messages.Message) error {
+			payload, err := Messages.Decode[Messages.SubscribeRegistryPayload](message)
+			if err != nil {
+				return err
+			}
+			return actsFor(ctx, payload.Subscription.Address)
+		},
+		Messages.UnsubscribeRegistry: func(ctx context.Context, message Messages.Message) error {
+			payload, err := Messages.Decode[Messages.UnsubscribeRegistryPayload](message)
+			if err != nil {
+				return err
+			}
+			subscription, ok := MainContainer.yellowPage.Subscription(payload.SubscriptionID)
+			if !ok {
+				return nil
+			}
+			return actsFor(ctx, subscription.Address)
+		},
+		Messages.RenewLease: func(ctx context.Context, message Messages.Message) error {
+			payload, err := Messages.Decode[Messages.RenewLeasePayload](message)
+			if err != nil {
+				return err
+			}
+			return actsFor(ctx, payload.Address)
+		},
+	}
+	checks[Messages.ModifyService] = checks[Messages.RegisterService]
+
+	ns := MainContainer.networkService
+	for messageType, check := range checks {
+		handler, ok := ns.Handler(messageType)
+		if !ok {
+			continue
+		}
+		check := check
+		ns.RegisterHandler(messageType, func(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+			if err := check(ctx, message); err != nil {
+				return Messages.Message{}, err
+			}
+			return handler(ctx, message)
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package Container
+
+import (
+	"FrameworkMultiAgents/Messages"
+	"FrameworkMultiAgents/NetworkService"
+	"FrameworkMultiAgents/YellowPage"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNotPrimary is answered by a standby main container to the requests meant for the primary one.
+var ErrNotPrimary = errors.New("not the primary main container")
+
+// replicaQueueLength is the number of changes a standby may lag behind before it gets a full copy again.
+const replicaQueueLength = 1024
+
+// replica is a standby main container fed with the operations of the yellow page of the primary.
+type replica struct {
+	address    string
+	operations chan []YellowPage.Operation
+	stopWatch  func()
+	lagging    atomic.Bool // the queue overflowed, operations are missing
+	mutex      sync.Mutex  // held while sending, no operation is sent once the replica is removed
+	removed    bool
+}
+
+// NewStandbyContainer starts a main container replicating the yellow page of the primary main container,
+// ready to take its place. mainAddresses lists the main containers of the platform in takeover order,
+// this one included: a standby takes over when no primary answers anymore and no main container listed
+// before it is alive. A failed primary restarts as a standby. The errors are the ones of NewMainContainer.
+func NewStandbyContainer(localAddress string, mainAddresses []string, opts ...Option) (*MainContainer, error) {
+	settings := newOptions(opts)
+	if settings.platformName == "" && len(mainAddresses) > 0 {
+		settings.platformName = mainAddresses[0]
+	}
+	standby, err := newMainContainer(localAddress, settings)
+	if err != nil {
+		return nil, err
+	}
+	standby.mainAddresses = append([]string(nil), mainAddresses...)
+	standby.standby.Store(true)
+	go standby.watchPrimary()
+	return standby, nil
+}
+
+// IsPrimary tells whether the main container serves the platform, or is a standby.
+func (MainContainer *MainContainer) IsPrimary() bool {
+	return !MainContainer.standby.Load()
+}
+
+// watchPrimary follows the primary main container, and takes over once it missed enough heartbeats.
+func (MainContainer *MainContainer) watchPrimary() {
+	ticker := time.NewTicker(MainContainer.heartbeatInterval)
+	defer ticker.Stop()
+	following, missed := "", 0
+	for {
+		select {
+		case <-MainContainer.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		primary, status, aheadAlive := MainContainer.findPrimary()
+		if primary == "" {
+			missed++
+			if missed >= MainContainer.missedHeartbeats && !aheadAlive {
+				MainContainer.takeOver(following)
+				return
+			}
+			continue
+		}
+		missed = 0
+		if primary == following && status.Replicating {
+			continue
+		}
+		// the primary replicates as soon as it registers the standby, maybe before answering
+		MainContainer.follow(primary)
+		if err := MainContainer.registerStandby(primary); err != nil {
+			log.Printf("Failed to register standby %s with %s: %v", MainContainer.localAdress, primary, err)
+			continue
+		}
+		if primary != following {
+			log.Printf("Standby %s replicates the primary main container %s", MainContainer.localAdress, primary)
+		}
+		following = primary
+	}
+}
+
+// findPrimary asks the other main containers which one is the primary.
+// aheadAlive tells whether a main container listed before this one answered.
+func (MainContainer *MainContainer) findPrimary() (primary string, status Messages.MainStatusAnswerPayload, aheadAlive bool) {
+	ahead := true
+	for _, address := range MainContainer.mainAddresses {
+		if address == MainContainer.localAdress {
+			ahead = false
+			continue
+		}
+		answer, err := MainContainer.mainStatus(address)
+		if err != nil {
+			continue
+		}
+		if answer.Primary {
+			return address, answer, ahead
+		}
+		aheadAlive = aheadAlive || ahead
+	}
+	return "", status, aheadAlive
+}
+
+// takeOver makes the standby the primary main container.
+func (MainContainer *MainContainer) takeOver(previous string) {
+	MainContainer.standby.Store(false)
+	if previous != "" {
+		// the agents of the failed primary died with it
+		MainContainer.yellowPage.DeregisterContainer(previous)
+	}
+	MainContainer.yellowPage.RegisterContainer(MainContainer.localAdress)
+	log.Printf("Main container %s takes over as primary", MainContainer.localAdress)
+}
+
+// follow makes the standby accept the operations of the primary main container, and only those.
+func (MainContainer *MainContainer) follow(primary string) {
+	MainContainer.followingMutex.Lock()
+	defer MainContainer.followingMutex.Unlock()
+	MainContainer.following = primary
+}
+
+func (MainContainer *MainContainer) followed() string {
+	MainContainer.followingMutex.Lock()
+	defer MainContainer.followingMutex.Unlock()
+	return MainContainer.following
+}
+
+func (MainContainer *MainContainer) registerStandby(primary string) error {
+	message := Messages.Message{
+		Type:           Messages.RegisterStandby,
+		Sender:         MainContainer.localAdress,
+		ExpectResponse: true,
+		ReplyBy:        time.Now().Add(MainContainer.heartbeatInterval),
+	}
+	if err := message.SetContent(Messages.RegisterStandbyContent, Messages.RegisterStandbyPayload{Address: MainContainer.localAdress, Token: MainContainer.joinToken}); err != nil {
+		return err
+	}
+	_, err := MainContainer.networkService.SendMessage(MainContainer.ctx, message, primary)
+	return err
+}
+
+// addReplica starts feeding a standby with a copy of the yellow page, then with each of its changes.
+func (MainContainer *MainContainer) addReplica(address string) {
+	MainContainer.replicasMutex.Lock()
+	previous := MainContainer.replicas[address]
+	MainContainer.replicasMutex.Unlock()
+	if previous != nil {
+		MainContainer.dropReplica(previous)
+	}
+
+	standby := &replica{
+		address:    address,
+		operations: make(chan []YellowPage.Operation, replicaQueueLength),
+	}
+	standby.stopWatch = MainContainer.yellowPage.Watch(func(operations []YellowPage.Operation) {
+		select {
+		case standby.operations <- operations:
+		default:
+			standby.lagging.Store(true)
+		}
+	})
+	MainContainer.replicasMutex.Lock()
+	MainContainer.replicas[address] = standby
+	MainContainer.replicasMutex.Unlock()
+	go MainContainer.feedReplica(standby)
+}
+
+func (MainContainer *MainContainer) feedReplica(standby *replica) {
+	for operations := range standby.operations {
+		standby.mutex.Lock()
+		if standby.removed {
+			standby.mutex.Unlock()
+			return
+		}
+		err := MainContainer.sendOperations(standby.address, operations)
+		standby.mutex.Unlock()
+		if err != nil {
+			log.Printf("Standby %s dropped: %v", standby.address, err)
+			MainContainer.dropReplica(standby)
+			return
+		}
+		if standby.lagging.Load() {
+			// the standby registers again on its next heartbeat and gets a full copy
+			log.Printf("Standby %s dropped: too far behind", standby.address)
+			MainContainer.dropReplica(standby)
+			return
+		}
+	}
+}
+
+func (MainContainer *MainContainer) sendOperations(address string, operations []YellowPage.Operation) error {
+	message := Messages.Message{
+		Type:   Messages.Replicate,
+		Sender: MainContainer.localAdress,
+	}
+	if err := message.SetContent(Messages.ReplicateContent, Messages.ReplicatePayload{Operations: operations}); err != nil {
+		return err
+	}
+	_, err := MainContainer.networkService.SendMessage(MainContainer.ctx, message, address)
+	return err
+}
+
+func (MainContainer *MainContainer) dropReplica(standby *replica) {
+	MainContainer.replicasMutex.Lock()
+	if MainContainer.replicas[standby.address] == standby {
+		delete(MainContainer.replicas, standby.address)
+	}
+	MainContainer.replicasMutex.Unlock()
+
+	standby.stopWatch()
+	standby.mutex.Lock()
+	defer standby.mutex.Unlock()
+	if !standby.removed {
+		standby.removed = true
+		close(standby.operations)
+	}
+}
+
+func (MainContainer *MainContainer) isReplica(address string) bool {
+	MainContainer.replicasMutex.Lock()
+	defer MainContainer.replicasMutex.Unlock()
+	_, ok := MainContainer.replicas[address]
+	return ok
+}
+
+// registerReplicationHandlers lets the main containers find the primary and replicate it,
+// and makes a standby refuse the requests meant for the primary.
+func (MainContainer *MainContainer) registerReplicationHandlers() {
+	ns := MainContainer.networkService
+	primaryOnly := []Messages.MessageType{
+		Messages.RegisterContainer, Messages.RegisterAgent, Messages.GetAgentAdress,
+		Messages.DeregisterAgent, Messages.DeregisterContainer,
+		Messages.RegisterService, Messages.ModifyService, Messages.DeregisterService, Messages.SearchService,
+		Messages.SubscribeRegistry, Messages.UnsubscribeRegistry, Messages.RenewLease,
+	}
+	for _, messageType := range primaryOnly {
+		handler, ok := ns.Handler(messageType)
+		if !ok {
+			continue
+		}
+		ns.RegisterHandler(messageType, func(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+			if !MainContainer.IsPrimary() {
+				return Messages.Message{}, ErrNotPrimary
+			}
+			return handler(ctx, message)
+		})
+	}
+
+	ns.RegisterHandler(Messages.MainStatus, func(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+		payload, err := Messages.Decode[Messages.MainStatusPayload](message)
+		if err != nil {
+			return Messages.Message{}, err
+		}
+		return NetworkService.NewResponse(Messages.MainStatusAnswer, Messages.MainStatusAnswerContent, Messages.MainStatusAnswerPayload{
+			Primary:     MainContainer.IsPrimary(),
+			Replicating: MainContainer.isReplica(payload.Address),
+		})
+	})
+	ns.RegisterHandler(Messages.RegisterStandby, func(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+		if !MainContainer.IsPrimary() {
+			return Messages.Message{}, ErrNotPrimary
+		}
+		payload, err := Messages.Decode[Messages.RegisterStandbyPayload](message)
+		if err != nil {
+			return Messages.Message{}, err
+		}
+		if err := MainContainer.admitStandby(ctx, payload); err != nil {
+			return Messages.Message{}, err
+		}
+		MainContainer.addReplica(payload.Address)
+		return NetworkService.NewResponse(Messages.RegisterStandbyAnswer, Messages.RegisterStandbyAnswerContent, Messages.RegisterStandbyAnswerPayload{
+			Success: true,
+		})
+	})
+	ns.RegisterHandler(Messages.Replicate, func(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+		if MainContainer.IsPrimary() {
+			return Messages.Message{}, errors.New("the primary main container does not replicate another one")
+		}
+		// only the primary the standby registered with replicates to it
+		if primary := MainContainer.followed(); primary == "" || actsFor(ctx, primary) != nil {
+			return Messages.Message{}, fmt.Errorf("%w: %s is not the primary main container followed", NetworkService.ErrUntrustedPeer, message.Sender)
+		}
+		payload, err := Messages.Decode[Messages.ReplicatePayload](message)
+		if err != nil {
+			return Messages.Message{}, err
+		}
+		MainContainer.yellowPage.Apply(payload.Operations...)
+		return Messages.Message{}, nil
+	})
+}
@@ -0,0 +1,209 @@
+package Container
+
+import (
+	"FrameworkMultiAgents/Messages"
+	"FrameworkMultiAgents/YellowPage"
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultResolutionTTL is how long a container trusts an agent address received from the main container.
+// The main container invalidates the addresses that change before, the TTL bounds the staleness
+// when an invalidation is lost.
+const DefaultResolutionTTL = 30 * time.Second
+
+// invalidationQueueLength is the number of yellow page changes waiting to be sent as invalidations.
+const invalidationQueueLength = 1024
+
+// ResolutionStats counts how a container resolved the addresses of the agents of other containers.
+type ResolutionStats struct {
+	Hits          uint64 // answered from the cache
+	Misses        uint64 // asked to the main container
+	Invalidations uint64 // entries removed before their TTL
+	Entries       int    // addresses currently cached
+}
+
+type cachedAddress struct {
+	agentID string
+	address string
+	expires time.Time
+}
+
+// resolutionCache keeps the agent addresses resolved by the main container, by agent ID or name.
+type resolutionCache struct {
+	ttl           time.Duration
+	mutex         sync.Mutex
+	entries       map[string]cachedAddress
+	hits          atomic.Uint64
+	misses        atomic.Uint64
+	invalidations atomic.Uint64
+}
+
+func newResolutionCache(ttl time.Duration) *resolutionCache {
+	return &resolutionCache{
+		ttl:     ttl,
+		entries: make(map[string]cachedAddress),
+	}
+}
+
+func (cache *resolutionCache) get(key string) (string, string, bool) {
+	if cache.ttl <= 0 {
+		cache.misses.Add(1)
+		return "", "", false
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	entry, ok := cache.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		delete(cache.entries, key)
+		cache.misses.Add(1)
+		return "", "", false
+	}
+	cache.hits.Add(1)
+	return entry.agentID, entry.address, true
+}
+
+func (cache *resolutionCache) put(key, agentID, address string) {
+	if cache.ttl <= 0 {
+		return
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.entries[key] = cachedAddress{agentID: agentID, address: address, expires: time.Now().Add(cache.ttl)}
+}
+
+// invalidate removes the agents listed and the agents living at address, or every entry if both are empty.
+func (cache *resolutionCache) invalidate(agentIDs []string, address string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	removed := 0
+	if len(agentIDs) == 0 && address == "" {
+		removed = len(cache.entries)
+		cache.entries = make(map[string]cachedAddress)
+	}
+	invalidated := make(map[string]bool, len(agentIDs))
+	for _, agentID := range agentIDs {
+		invalidated[agentID] = true
+	}
+	for key, entry := range cache.entries {
+		if invalidated[entry.agentID] || (address != "" && entry.address == address) {
+			delete(cache.entries, key)
+			removed++
+		}
+	}
+	cache.invalidations.Add(uint64(removed))
+}
+
+func (cache *resolutionCache) stats() ResolutionStats {
+	cache.mutex.Lock()
+	entries := len(cache.entries)
+	cache.mutex.Unlock()
+	return ResolutionStats{
+		Hits:          cache.hits.Load(),
+		Misses:        cache.misses.Load(),
+		Invalidations: cache.invalidations.Load(),
+		Entries:       entries,
+	}
+}
+
+// ResolutionStats returns the counters of the agent address cache of the container.
+func (Container *Container) ResolutionStats() ResolutionStats {
+	return Container.resolutions.stats()
+}
+
+// registerInvalidationHandler applies the invalidations pushed by the main container.
+func (Container *Container) registerInvalidationHandler() {
+	Container.networkService.RegisterHandler(Messages.InvalidateAgentAddress, func(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+		payload, err := Messages.Decode[Messages.InvalidateAgentAddressPayload](message)
+		if err != nil {
+			return Messages.Message{}, err
+		}
+		Container.resolutions.invalidate(payload.AgentIDs, payload.Address)
+		return Messages.Message{}, nil
+	})
+}
+
+// registerResolutionHandler remembers the containers resolving agent addresses, to send them the invalidations.
+func (MainContainer *MainContainer) registerResolutionHandler() {
+	ns := MainContainer.networkService
+	handler, ok := ns.Handler(Messages.GetAgentAdress)
+	if !ok {
+		return
+	}
+	ns.RegisterHandler(Messages.GetAgentAdress, func(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+		MainContainer.resolversMutex.Lock()
+		MainContainer.resolvers[message.Sender] = true
+		MainContainer.resolversMutex.Unlock()
+		return handler(ctx, message)
+	})
+}
+
+// pushInvalidations tells the containers caching agent addresses about the agents
+// deregistered, registered again elsewhere or gone with their container.
+func (MainContainer *MainContainer) pushInvalidations() {
+	changes := make(chan YellowPage.Operation, invalidationQueueLength)
+	initialState := true
+	stop := MainContainer.yellowPage.Watch(func(operations []YellowPage.Operation) {
+		if initialState {
+			// the containers cached nothing yet
+			initialState = false
+			return
+		}
+		for _, operation := range operations {
+			select {
+			case changes <- operation:
+			default:
+				// the TTL of the cached addresses bounds the staleness
+			}
+		}
+	})
+	defer stop()
+	for {
+		select {
+		case <-MainContainer.ctx.Done():
+			return
+		case operation := <-changes:
+			payload := Messages.InvalidateAgentAddressPayload{}
+			switch operation.Kind {
+			case YellowPage.AgentRegistered, YellowPage.AgentDeregistered:
+				payload.AgentIDs = []string{operation.ID}
+			case YellowPage.ContainerDeregistered:
+				payload.Address = operation.Address
+			default:
+				continue
+			}
+			MainContainer.broadcastInvalidation(payload)
+		}
+	}
+}
+
+func (MainContainer *MainContainer) broadcastInvalidation(payload Messages.InvalidateAgentAddressPayload) {
+	message := Messages.Message{
+		Type:   Messages.InvalidateAgentAddress,
+		Sender: MainContainer.localAdress,
+	}
+	if err := message.SetContent(Messages.InvalidateAgentAddressContent, payload); err != nil {
+		log.Printf("Failed to encode invalidation: %v", err)
+		return
+	}
+	MainContainer.resolversMutex.Lock()
+	resolvers := make([]string, 0, len(MainContainer.resolvers))
+	for address := range MainContainer.resolvers {
+		resolvers = append(resolvers, address)
+	}
+	MainContainer.resolversMutex.Unlock()
+	for _, address := range resolvers {
+		if address == payload.Address {
+			continue
+		}
+		if _, err := MainContainer.networkService.SendMessage(MainContainer.ctx, message, address); err != nil {
+			// gone, it registers again when it resolves an agent
+			MainContainer.resolversMutex.Lock()
+			delete(MainContainer.resolvers, address)
+			MainContainer.resolversMutex.Unlock()
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package Container
+
+import (
+	"FrameworkMultiAgents/Messages"
+	"FrameworkMultiAgents/NetworkService"
+	"FrameworkMultiAgents/YellowPage"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestResolutionCache(t *testing.T) {
+	fill := func(cache *resolutionCache) {
+		cache.put("1", "1", "container-1")
+		cache.put("pong", "1", "container-1")
+		cache.put("2", "2", "container-1")
+		cache.put("3", "3", "container-2")
+	}
+	tests := []struct {
+		name     string
+		agentIDs []string
+		address  string
+		want     string // keys left, in order
+	}{
+		{"agent", []string{"1"}, "", "2 3"},
+		{"agents", []string{"1", "3"}, "", "2"},
+		{"container", nil, "container-1", "3"},
+		{"agent and container", []string{"3"}, "container-1", ""},
+		{"everything", nil, "", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cache := newResolutionCache(time.Minute)
+			fill(cache)
+			cache.invalidate(test.agentIDs, test.address)
+			var left []string
+			for _, key := range []string{"1", "pong", "2", "3"} {
+				if _, _, ok := cache.get(key); ok {
+					left = append(left, key)
+				}
+			}
+			if got := fmt.Sprint(left); got != fmt.Sprint(splitKeys(test.want)) {
+				t.Errorf("left %v, want %v", got, test.want)
+			}
+		})
+	}
+
+	cache := newResolutionCache(0)
+	cache.put("1", "1", "container-1")
+	if _, _, ok := cache.get("1"); ok {
+		t.Error("a cache without TTL kept an address")
+	}
+}
+
+func splitKeys(keys string) []string {
+	var split []string
+	for _, key := range []rune(keys) {
+		if key != ' ' {
+			split = append(split, string(key))
+		}
+	}
+	return split
+}
+
+func TestStaleAddress(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"connection lost", fmt.Errorf("%w: container-2: dial failed", NetworkService.ErrConnectionLost), true},
+		{"agent not hosted", &NetworkService.RemoteError{Address: "container-2", Message: fmt.Sprintf("%v 7", YellowPage.ErrUnknownAgent)}, true},
+		{"other remote error", &NetworkService.RemoteError{Address: "container-2", Message: "mailbox full"}, false},
+		{"timeout", NetworkService.ErrTimeout, false},
+		{"no error", nil, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := staleAddress(test.err); got != test.want {
+				t.Errorf("staleAddress(%v) = %v, want %v", test.err, got, test.want)
+			}
+		})
+	}
+}
+
+func TestStaleAddressEvicted(t *testing.T) {
+	transport := NetworkService.NewMemoryTransport()
+	startMainContainer(t, "main", WithTransport(transport))
+	containers := make([]*Container, 3)
+	for i := range containers {
+		container, err := NewContainer("main", fmt.Sprintf("container-%d", i+1), WithTransport(transport))
+		if err != nil {
+			t.Fatalf("NewContainer: %v", err)
+		}
+		t.Cleanup(func() { container.Shutdown(shutdownContext(t)) })
+		containers[i] = container
+	}
+	senderID, err := containers[0].AddAgent("ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiverID, err := containers[1].AddAgent("pong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender, receiver := containers[0].GetAgent(senderID), containers[1].GetAgent(receiverID)
+	id, _ := strconv.Atoi(receiverID)
+
+	tests := []struct {
+		name           string
+		cached         string // the outdated address of the receiver
+		expectResponse bool   // the container answers only to a message expecting a response
+	}{
+		{"container gone", "container-4", false},
+		{"agent not hosted", "container-3", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			containers[0].resolutions.put(receiverID, receiverID, test.cached)
+			message, err := Messages.NewMessage(Messages.InterAgentAsyncMessage, Messages.InterAgentAsyncMessageContent,
+				Messages.InterAgentAsyncMessagePayload{ReceiverID: id, Content: test.name})
+			if err != nil {
+				t.Fatal(err)
+			}
+			message.ExpectResponse = test.expectResponse
+			sender.SendMail(message, id)
+
+			select {
+			case received := <-receiver.MailBox:
+				if payload, _ := Messages.Decode[Messages.InterAgentAsyncMessagePayload](received); payload.Content != test.name {
+					t.Errorf("received %q, want %q", payload.Content, test.name)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("the message did not reach the receiver")
+			}
+			if _, address, ok := containers[0].resolutions.get(receiverID); ok && address == test.cached {
+				t.Errorf("the outdated address %s is still cached", address)
+			}
+		})
+	}
+}
+
+func TestInvalidationPushed(t *testing.T) {
+	transport := NetworkService.NewMemoryTransport()
+	startMainContainer(t, "main", WithTransport(transport))
+	container, err := NewContainer("main", "container-1", WithTransport(transport))
+	if err != nil {
+		t.Fatalf("NewContainer: %v", err)
+	}
+	t.Cleanup(func() { container.Shutdown(shutdownContext(t)) })
+	other, err := NewContainer("main", "container-2", WithTransport(transport))
+	if err != nil {
+		t.Fatalf("NewContainer: %v", err)
+	}
+	agentID, err := other.AddAgent("pong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// resolved again if the invalidation of the registration comes late
+	eventually(t, "the address of the agent is cached", func() bool {
+		address, err := container.ResolveAgentAddress(agentID)
+		if err != nil || address != "container-2" {
+			t.Fatalf("ResolveAgentAddress: %q, %v", address, err)
+		}
+		return container.ResolutionStats().Hits > 0
+	})
+
+	other.Shutdown(shutdownContext(t))
+	eventually(t, "the address of the agent is invalidated", func() bool {
+		stats := container.ResolutionStats()
+		return stats.Entries == 0 && stats.Invalidations > 0
+	})
+	if _, err := container.ResolveAgentAddress(agentID); err == nil {
+		t.Error("the agent of the stopped container is still resolved")
+	}
+}
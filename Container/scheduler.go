@@ -0,0 +1,227 @@
+package Container
+
+import (
+	"FrameworkMultiAgents/Agent"
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// Turns given to each priority level, from the highest to the lowest, before moving to the next one.
+// Every level gets at least one turn per round so low priority agents are never starved.
+var priorityWeights = [...]int{4, 2, 1}
+
+// DefaultStepBudget is the number of messages an agent handles before giving its worker back.
+const DefaultStepBudget = 16
+
+// Scheduler runs the agents of a container on a bounded pool of workers.
+// An agent is in at most one ready queue at a time, so it never runs on two workers at once.
+type Scheduler struct {
+	workers    int
+	stepBudget int
+
+	mutex   sync.Mutex
+	ready   sync.Cond
+	queues  [len(priorityWeights)][]*scheduledAgent
+	level   int
+	credit  int
+	timers  timerHeap
+	timer   *time.Timer
+	entries map[*Agent.Agent]*scheduledAgent
+	stopped bool
+}
+
+type scheduledAgent struct {
+	agent   *Agent.Agent
+	level   int
+	queued  bool
+	running bool
+	woken   bool      // woken up while running
+	dueAt   time.Time // zero when the agent is not in the timer heap
+	index   int       // index in the timer heap
+}
+
+func NewScheduler(workers int) *Scheduler {
+	if workers <= 0 {
+		workers = 1
+	}
+	scheduler := &Scheduler{
+		workers:    workers,
+		stepBudget: DefaultStepBudget,
+		credit:     priorityWeights[0],
+		entries:    make(map[*Agent.Agent]*scheduledAgent),
+	}
+	scheduler.ready.L = &scheduler.mutex
+	return scheduler
+}
+
+// Start launches the workers, they stop once ctx is cancelled and every agent is dead.
+func (scheduler *Scheduler) Start(ctx context.Context) {
+	scheduler.timer = time.AfterFunc(time.Hour, scheduler.fireTimers)
+	scheduler.timer.Stop()
+	for i := 0; i < scheduler.workers; i++ {
+		go scheduler.work()
+	}
+	go func() {
+		<-ctx.Done()
+		scheduler.mutex.Lock()
+		scheduler.stopped = true
+		for _, entry := range scheduler.entries {
+			scheduler.push(entry)
+		}
+		scheduler.ready.Broadcast()
+		scheduler.mutex.Unlock()
+	}()
+}
+
+// Add attaches the agent to the pool, the agent gets its first turn right away.
+func (scheduler *Scheduler) Add(ctx context.Context, agent *Agent.Agent) bool {
+	entry := &scheduledAgent{agent: agent, level: levelOf(agent.Priority), index: -1}
+	scheduler.mutex.Lock()
+	scheduler.entries[agent] = entry
+	scheduler.mutex.Unlock()
+	if !agent.Attach(ctx, func() { scheduler.wake(entry) }) {
+		scheduler.mutex.Lock()
+		delete(scheduler.entries, agent)
+		scheduler.mutex.Unlock()
+		return false
+	}
+	scheduler.wake(entry)
+	return true
+}
+
+// Len returns the number of agents run by the pool.
+func (scheduler *Scheduler) Len() int {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+	return len(scheduler.entries)
+}
+
+func levelOf(priority Agent.Priority) int {
+	switch {
+	case priority >= Agent.HighPriority:
+		return 0
+	case priority <= Agent.LowPriority:
+		return 2
+	}
+	return 1
+}
+
+func (scheduler *Scheduler) wake(entry *scheduledAgent) {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+	if entry.running {
+		entry.woken = true
+		return
+	}
+	scheduler.push(entry)
+}
+
+// push puts the agent in its ready queue, the mutex must be held.
+func (scheduler *Scheduler) push(entry *scheduledAgent) {
+	if entry.queued || entry.running {
+		return
+	}
+	if entry.index >= 0 {
+		heap.Remove(&scheduler.timers, entry.index)
+		entry.dueAt = time.Time{}
+	}
+	entry.queued = true
+	scheduler.queues[entry.level] = append(scheduler.queues[entry.level], entry)
+	scheduler.ready.Signal()
+}
+
+// pop takes the next agent to run with a weighted round robin over the priority levels,
+// the mutex must be held.
+func (scheduler *Scheduler) pop() *scheduledAgent {
+	for tries := 0; tries <= 2*len(scheduler.queues); tries++ {
+		queue := scheduler.queues[scheduler.level]
+		if scheduler.credit > 0 && len(queue) > 0 {
+			entry := queue[0]
+			queue[0] = nil
+			scheduler.queues[scheduler.level] = queue[1:]
+			scheduler.credit--
+			entry.queued = false
+			return entry
+		}
+		scheduler.level = (scheduler.level + 1) % len(scheduler.queues)
+		scheduler.credit = priorityWeights[scheduler.level]
+	}
+	return nil
+}
+
+func (scheduler *Scheduler) work() {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+	for {
+		entry := scheduler.pop()
+		for entry == nil {
+			if scheduler.stopped && len(scheduler.entries) == 0 {
+				scheduler.timer.Stop()
+				scheduler.ready.Broadcast()
+				return
+			}
+			scheduler.ready.Wait()
+			entry = scheduler.pop()
+		}
+		entry.running = true
+		entry.woken = false
+		scheduler.mutex.Unlock()
+
+		next, alive := entry.agent.Step(scheduler.stepBudget)
+
+		scheduler.mutex.Lock()
+		entry.running = false
+		switch {
+		case !alive:
+			delete(scheduler.entries, entry.agent)
+		case entry.woken || scheduler.stopped || (!next.IsZero() && !next.After(time.Now())):
+			scheduler.push(entry)
+		case !next.IsZero():
+			entry.dueAt = next
+			heap.Push(&scheduler.timers, entry)
+			if scheduler.timers[0] == entry {
+				scheduler.timer.Reset(time.Until(next))
+			}
+		}
+	}
+}
+
+// fireTimers moves the agents whose tick is due to the ready queues.
+func (scheduler *Scheduler) fireTimers() {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+	now := time.Now()
+	for len(scheduler.timers) > 0 && !scheduler.timers[0].dueAt.After(now) {
+		scheduler.push(scheduler.timers[0])
+	}
+	if len(scheduler.timers) > 0 {
+		scheduler.timer.Reset(time.Until(scheduler.timers[0].dueAt))
+	}
+}
+
+type timerHeap []*scheduledAgent
+
+func (timers timerHeap) Len() int           { return len(timers) }
+func (timers timerHeap) Less(i, j int) bool { return timers[i].dueAt.Before(timers[j].dueAt) }
+func (timers timerHeap) Swap(i, j int) {
+	timers[i], timers[j] = timers[j], timers[i]
+	timers[i].index = i
+	timers[j].index = j
+}
+
+func (timers *timerHeap) Push(x any) {
+	entry := x.(*scheduledAgent)
+	entry.index = len(*timers)
+	*timers = append(*timers, entry)
+}
+
+func (timers *timerHeap) Pop() any {
+	old := *timers
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	entry.index = -1
+	*timers = old[:len(old)-1]
+	return entry
+}
@@ -0,0 +1,98 @@
+package Container
+
+import (
+	"FrameworkMultiAgents/Agent"
+	"FrameworkMultiAgents/NetworkService"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLevelOf(t *testing.T) {
+	tests := []struct {
+		priority Agent.Priority
+		want     int
+	}{
+		{Agent.HighPriority + 3, 0},
+		{Agent.HighPriority, 0},
+		{Agent.NormalPriority, 1},
+		{Agent.LowPriority, 2},
+		{Agent.LowPriority - 3, 2},
+	}
+	for _, test := range tests {
+		if got := levelOf(test.priority); got != test.want {
+			t.Errorf("levelOf(%d) = %d, want %d", test.priority, got, test.want)
+		}
+	}
+}
+
+func TestWeightedRoundRobin(t *testing.T) {
+	tests := []struct {
+		name   string
+		queued string // one letter per agent queued, H, N or L for its priority level
+		want   string // in the order the agents are run
+	}{
+		{"empty", "", ""},
+		{"one level", "NNN", "NNN"},
+		{"high first", "LNH", "HNL"},
+		{"high gets four turns", "HHHHHH", "HHHHHH"},
+		{"low is not starved", "HHHHHHLL", "HHHHLHHL"},
+		{"every level", "HHHHHNNNNNLLLLL", "HHHHNNLHNNLNLLL"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scheduler := NewScheduler(1)
+			scheduler.mutex.Lock()
+			defer scheduler.mutex.Unlock()
+			for _, letter := range test.queued {
+				scheduler.push(&scheduledAgent{level: strings.IndexRune("HNL", letter), index: -1})
+			}
+			var got strings.Builder
+			for entry := scheduler.pop(); entry != nil; entry = scheduler.pop() {
+				got.WriteByte("HNL"[entry.level])
+			}
+			if got.String() != test.want {
+				t.Errorf("run %q, want %q", got.String(), test.want)
+			}
+		})
+	}
+}
+
+func TestWorkerPool(t *testing.T) {
+	transport := NetworkService.NewMemoryTransport()
+	startMainContainer(t, "main", WithTransport(transport))
+	container, err := NewContainer("main", "container-1", WithTransport(transport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { container.Shutdown(shutdownContext(t)) })
+	container.UseWorkerPool(2)
+
+	before, err := container.AddAgent("before")
+	if err != nil {
+		t.Fatal(err)
+	}
+	container.Start()
+	after, err := container.AddAgent("after")
+	if err != nil {
+		t.Fatal(err)
+	}
+	container.Start() // does nothing the second time
+	if got := container.scheduler.Len(); got != 2 {
+		t.Fatalf("%d agents in the pool, want 2", got)
+	}
+
+	for _, agentID := range []string{before, after} {
+		agent := container.GetAgent(agentID)
+		if state := agent.State(); state == Agent.Initiated {
+			t.Errorf("agent %s was not started", agentID)
+		}
+		// only a worker of the pool can end an attached agent
+		agent.Kill()
+		select {
+		case <-agent.Done():
+		case <-time.After(2 * time.Second):
+			t.Fatalf("agent %s is not run by the pool", agentID)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package Container
+
+import (
+	"FrameworkMultiAgents/Messages"
+	"FrameworkMultiAgents/NetworkService"
+	"FrameworkMultiAgents/YellowPage"
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+)
+
+// SubscriptionProtocol is the Protocol of the messages notifying an agent of a change of the yellow page,
+// their ConversationID is the ID of the subscription.
+const SubscriptionProtocol = "fipa-subscribe"
+
+// eventQueueLength is the number of yellow page events waiting to be sent to the subscribers.
+const eventQueueLength = 1024
+
+// Subscribe calls handler with the changes of the yellow page selected by filter, such as
+// YellowPage.Filter{Service: &YellowPage.ServiceDescription{Type: "worker"}}, until Unsubscribe.
+// It returns the ID of the subscription. The handler must not block.
+func (Container *Container) Subscribe(filter YellowPage.Filter, handler func(YellowPage.Event)) (string, error) {
+	return Container.SubscribeContext(context.Background(), filter, handler)
+}
+
+// SubscribeContext is Subscribe, giving up when ctx is done.
+func (Container *Container) SubscribeContext(ctx context.Context, filter YellowPage.Filter, handler func(YellowPage.Event)) (string, error) {
+	subscription := YellowPage.Subscription{
+		ID:      Container.newSubscriptionID(),
+		Address: Container.localAdress,
+		Filter:  filter,
+	}
+	Container.subscriptionsMutex.Lock()
+	Container.subscriptions[subscription.ID] = handler
+	Container.subscriptionsMutex.Unlock()
+	if err := Container.addSubscription(ctx, subscription); err != nil {
+		Container.subscriptionsMutex.Lock()
+		delete(Container.subscriptions, subscription.ID)
+		Container.subscriptionsMutex.Unlock()
+		return "", err
+	}
+	return subscription.ID, nil
+}
+
+// SubscribeAgent sends the changes of the yellow page selected by filter to the mailbox of an agent,
+// as RegistryEvent messages following SubscriptionProtocol. The subscription ends with the agent.
+func (Container *Container) SubscribeAgent(agentID string, filter YellowPage.Filter) (string, error) {
+	return Container.SubscribeAgentContext(context.Background(), agentID, filter)
+}
+
+// SubscribeAgentContext is SubscribeAgent, giving up when ctx is done.
+func (Container *Container) SubscribeAgentContext(ctx context.Context, agentID string, filter YellowPage.Filter) (string, error) {
+	subscription := YellowPage.Subscription{
+		ID:      Container.newSubscriptionID(),
+		Address: Container.localAdress,
+		AgentID: agentID,
+		Filter:  filter,
+	}
+	if err := Container.addSubscription(ctx, subscription); err != nil {
+		return "", err
+	}
+	return subscription.ID, nil
+}
+
+// Unsubscribe ends a subscription of the container or of one of its agents.
+func (Container *Container) Unsubscribe(subscriptionID string) error {
+	return Container.UnsubscribeContext(context.Background(), subscriptionID)
+}
+
+// UnsubscribeContext is Unsubscribe, giving up when ctx is done.
+func (Container *Container) UnsubscribeContext(ctx context.Context, subscriptionID string) error {
+	Container.subscriptionsMutex.Lock()
+	delete(Container.subscriptions, subscriptionID)
+	Container.subscriptionsMutex.Unlock()
+	if Container.directory != nil {
+		return Container.directory.Unsubscribe(subscriptionID)
+	}
+	_, err := Container.requestMain(ctx, Messages.UnsubscribeRegistry, Messages.UnsubscribeRegistryContent, Messages.UnsubscribeRegistryPayload{
+		SubscriptionID: subscriptionID,
+	})
+	return err
+}
+
+// newSubscriptionID returns an ID no other container gives, the subscriptions are kept by the main container.
+func (Container *Container) newSubscriptionID() string {
+	return Container.localAdress + "#" + strconv.FormatUint(Container.nextSubscription.Add(1), 10)
+}
+
+func (Container *Container) addSubscription(ctx context.Context, subscription YellowPage.Subscription) error {
+	if Container.directory != nil {
+		return Container.directory.Subscribe(subscription)
+	}
+	_, err := Container.requestMain(ctx, Messages.SubscribeRegistry, Messages.SubscribeRegistryContent, Messages.SubscribeRegistryPayload{
+		Subscription: subscription,
+	})
+	return err
+}
+
+// deliverEvent hands a change of the yellow page to the subscribed agent, or to the handler of the container.
+func (Container *Container) deliverEvent(payload Messages.RegistryEventPayload) error {
+	if payload.AgentID == "" {
+		Container.subscriptionsMutex.Lock()
+		handler, ok := Container.subscriptions[payload.SubscriptionID]
+		Container.subscriptionsMutex.Unlock()
+		if !ok {
+			return fmt.Errorf("unknown subscription %s", payload.SubscriptionID)
+		}
+		handler(payload.Event)
+		return nil
+	}
+	agent := Container.GetAgent(payload.AgentID)
+	if agent == nil {
+		return fmt.Errorf("%w %s", YellowPage.ErrUnknownAgent, payload.AgentID)
+	}
+	message := Messages.Message{
+		Type:           Messages.RegistryEvent,
+		Sender:         Container.currentMain(),
+		Performative:   Messages.Inform,
+		Receivers:      []string{payload.AgentID},
+		ConversationID: payload.SubscriptionID,
+		Protocol:       SubscriptionProtocol,
+	}
+	if message.Sender == "" {
+		message.Sender = Container.localAdress
+	}
+	if err := message.SetContent(Messages.RegistryEventContent, payload); err != nil {
+		return err
+	}
+	if !agent.Deliver(message, false) {
+		return fmt.Errorf("mailbox of agent %s full, event %s dropped", payload.AgentID, payload)
+	}
+	return nil
+}
+
+// registerEventHandler delivers the changes of the yellow page sent by the main container.
+func (Container *Container) registerEventHandler() {
+	Container.networkService.RegisterHandler(Messages.RegistryEvent, func(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+		payload, err := Messages.Decode[Messages.RegistryEventPayload](message)
+		if err != nil {
+			return Messages.Message{}, err
+		}
+		if err := Container.deliverEvent(payload); err != nil {
+			log.Printf("Registry event dropped: %v", err)
+		}
+		return Messages.Message{}, nil
+	})
+}
+
+// registerSubscriptionHandlers records the subscriptions of the other containers.
+func (MainContainer *MainContainer) registerSubscriptionHandlers() {
+	ns := MainContainer.networkService
+	ns.RegisterHandler(Messages.SubscribeRegistry, func(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+		payload, err := Messages.Decode[Messages.SubscribeRegistryPayload](message)
+		if err != nil {
+			return Messages.Message{}, err
+		}
+		if err := MainContainer.yellowPage.Subscribe(payload.Subscription); err != nil {
+			return Messages.Message{}, err
+		}
+		return NetworkService.NewResponse(Messages.SubscribeRegistryAnswer, Messages.ServiceAnswerContent, Messages.ServiceAnswerPayload{Success: true})
+	})
+	ns.RegisterHandler(Messages.UnsubscribeRegistry, func(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+		payload, err := Messages.Decode[Messages.UnsubscribeRegistryPayload](message)
+		if err != nil {
+			return Messages.Message{}, err
+		}
+		if err := MainContainer.yellowPage.Unsubscribe(payload.SubscriptionID); err != nil {
+			return Messages.Message{}, err
+		}
+		return NetworkService.NewResponse(Messages.UnsubscribeRegistryAnswer, Messages.ServiceAnswerContent, Messages.ServiceAnswerPayload{Success: true})
+	})
+}
+
+// notifySubscribers sends the changes of the yellow page to the subscriptions selecting them,
+// while the main container is the primary one.
+func (MainContainer *MainContainer) notifySubscribers() {
+	changes := make(chan YellowPage.Notification, eventQueueLength)
+	stop := MainContainer.yellowPage.WatchNotifications(func(notifications []YellowPage.Notification) {
+		for _, notification := range notifications {
+			select {
+			case changes <- notification:
+			default:
+				log.Printf("Registry event %s dropped: too many pending", notification.Event.Kind)
+			}
+		}
+	})
+	defer stop()
+	for {
+		select {
+		case <-MainContainer.ctx.Done():
+			return
+		case notification := <-changes:
+			if !MainContainer.IsPrimary() {
+				continue
+			}
+			MainContainer.notify(notification.Subscription, notification.Event)
+		}
+	}
+}
+
+func (MainContainer *MainContainer) notify(subscription YellowPage.Subscription, event YellowPage.Event) {
+	payload := Messages.RegistryEventPayload{
+		SubscriptionID: subscription.ID,
+		AgentID:        subscription.AgentID,
+		Event:          event,
+	}
+	if subscription.Address == MainContainer.localAdress {
+		if err := MainContainer.deliverEvent(payload); err != nil {
+			log.Printf("Registry event dropped: %v", err)
+		}
+		return
+	}
+	message := Messages.Message{
+		Type:   Messages.RegistryEvent,
+		Sender: MainContainer.localAdress,
+	}
+	if err := message.SetContent(Messages.RegistryEventContent, payload); err != nil {
+		log.Printf("Failed to encode registry event: %v", err)
+		return
+	}
+	if _, err := MainContainer.networkService.SendMessage(MainContainer.ctx, message, subscription.Address); err != nil {
+		log.Printf("Failed to notify subscription %s: %v", subscription.ID, err)
+	}
+}
@@ -0,0 +1,84 @@
+package Container
+
+import (
+	"FrameworkMultiAgents/Messages"
+	"FrameworkMultiAgents/NetworkService"
+	"FrameworkMultiAgents/YellowPage"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSubscribe(t *testing.T) {
+	transport := NetworkService.NewMemoryTransport()
+	startMainContainer(t, "main", WithTransport(transport))
+	containers := make([]*Container, 2)
+	for i := range containers {
+		container, err := NewContainer("main", fmt.Sprintf("container-%d", i+1), WithTransport(transport))
+		if err != nil {
+			t.Fatalf("NewContainer: %v", err)
+		}
+		t.Cleanup(func() { container.Shutdown(shutdownContext(t)) })
+		containers[i] = container
+	}
+	events := make(chan YellowPage.Event, 16)
+	subscriptionID, err := containers[0].Subscribe(YellowPage.Filter{ContainerID: "container-2"}, func(event YellowPage.Event) { events <- event })
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	watcherID, err := containers[0].AddAgent("watcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher := containers[0].GetAgent(watcherID)
+	agentSubscriptionID, err := containers[0].SubscribeAgent(watcherID, YellowPage.Filter{Kinds: []YellowPage.EventKind{YellowPage.EventAgentDeregistered}})
+	if err != nil {
+		t.Fatalf("SubscribeAgent: %v", err)
+	}
+	next := func(what string) YellowPage.Event {
+		t.Helper()
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %s", what)
+			return YellowPage.Event{}
+		}
+	}
+
+	agentID, err := containers[1].AddAgent("pong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event := next("the registration"); event.Kind != YellowPage.EventAgentRegistered || event.AgentID != agentID || event.AgentName != "pong" {
+		t.Errorf("event %+v, want the registration of pong", event)
+	}
+
+	// the agent gets the events in its mailbox
+	containers[1].GetAgent(agentID).Kill()
+	if event := next("the deregistration"); event.Kind != YellowPage.EventAgentDeregistered {
+		t.Errorf("event %+v, want the deregistration of pong", event)
+	}
+	select {
+	case message := <-watcher.MailBox:
+		payload, err := Messages.Decode[Messages.RegistryEventPayload](message)
+		if err != nil || message.Protocol != SubscriptionProtocol || message.ConversationID != agentSubscriptionID ||
+			payload.Event.Kind != YellowPage.EventAgentDeregistered || payload.Event.AgentID != agentID {
+			t.Errorf("message %+v with %+v, %v, want the deregistration of pong", message, payload, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the agent was not notified")
+	}
+
+	if err := containers[0].Unsubscribe(subscriptionID); err != nil {
+		t.Fatalf("Unsubscribe: %v", err)
+	}
+	if _, err := containers[1].AddAgent("ping"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-events:
+		t.Errorf("event %+v after Unsubscribe", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,139 @@
+package Messages
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Performative is the communicative act of an agent message (FIPA-ACL).
+// Transport messages between containers leave it to NoPerformative.
+type Performative int
+
+const (
+	NoPerformative Performative = iota
+	AcceptProposal
+	Agree
+	Cancel
+	CFP
+	Confirm
+	Disconfirm
+	Failure
+	Inform
+	InformIf
+	InformRef
+	NotUnderstood
+	Propagate
+	Propose
+	Proxy
+	QueryIf
+	QueryRef
+	Refuse
+	RejectProposal
+	Request
+	RequestWhen
+	RequestWhenever
+	Subscribe
+)
+
+var performativeNames = [...]string{
+	NoPerformative:  "",
+	AcceptProposal:  "ACCEPT_PROPOSAL",
+	Agree:           "AGREE",
+	Cancel:          "CANCEL",
+	CFP:             "CFP",
+	Confirm:         "CONFIRM",
+	Disconfirm:      "DISCONFIRM",
+	Failure:         "FAILURE",
+	Inform:          "INFORM",
+	InformIf:        "INFORM_IF",
+	InformRef:       "INFORM_REF",
+	NotUnderstood:   "NOT_UNDERSTOOD",
+	Propagate:       "PROPAGATE",
+	Propose:         "PROPOSE",
+	Proxy:           "PROXY",
+	QueryIf:         "QUERY_IF",
+	QueryRef:        "QUERY_REF",
+	Refuse:          "REFUSE",
+	RejectProposal:  "REJECT_PROPOSAL",
+	Request:         "REQUEST",
+	RequestWhen:     "REQUEST_WHEN",
+	RequestWhenever: "REQUEST_WHENEVER",
+	Subscribe:       "SUBSCRIBE",
+}
+
+func (performative Performative) String() string {
+	if performative < 0 || int(performative) >= len(performativeNames) {
+		return fmt.Sprintf("Performative(%d)", int(performative))
+	}
+	return performativeNames[performative]
+}
+
+// ParsePerformative reads the FIPA name of a performative, such as "INFORM" or "not-understood".
+func ParsePerformative(name string) (Performative, error) {
+	name = strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+	for performative, performativeName := range performativeNames {
+		if performativeName == name {
+			return Performative(performative), nil
+		}
+	}
+	return NoPerformative, fmt.Errorf("unknown performative %q", name)
+}
+
+// performatives travel with their FIPA name
+func (performative Performative) MarshalText() ([]byte, error) {
+	return []byte(performative.String()), nil
+}
+
+func (performative *Performative) UnmarshalText(text []byte) error {
+	parsed, err := ParsePerformative(string(text))
+	if err != nil {
+		return err
+	}
+	*performative = parsed
+	return nil
+}
+
+// CreateReply prepares the answer to an agent message: it goes to the ReplyTo agents (or the sender),
+// keeps the conversation, language, ontology and protocol, and refers to the ReplyWith of the message.
+// The performative is copied and usually changed by the caller.
+func (message Message) CreateReply() Message {
+	receivers := message.ReplyTo
+	if len(receivers) == 0 {
+		receivers = []string{message.Sender}
+	}
+	return Message{
+		Type:           message.Type,
+		ContentType:    message.ContentType,
+		Performative:   message.Performative,
+		Receivers:      append([]string(nil), receivers...),
+		ConversationID: message.ConversationID,
+		InReplyTo:      message.ReplyWith,
+		Language:       message.Language,
+		Ontology:       message.Ontology,
+		Protocol:       message.Protocol,
+	}
+}
+
+func MatchPerformative(performative Performative) MessageTemplate {
+	return func(message Message) bool {
+		return message.Performative == performative
+	}
+}
+
+func MatchInReplyTo(replyWith string) MessageTemplate {
+	return func(message Message) bool {
+		return message.InReplyTo == replyWith
+	}
+}
+
+func MatchOntology(ontology string) MessageTemplate {
+	return func(message Message) bool {
+		return message.Ontology == ontology
+	}
+}
+
+func MatchProtocol(protocol string) MessageTemplate {
+	return func(message Message) bool {
+		return message.Protocol == protocol
+	}
+}
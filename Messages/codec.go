@@ -0,0 +1,194 @@
+package Messages
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// UserContentType is the first ContentType value left to applications,
+// the framework registers its own payloads below it.
+const UserContentType ContentType = 1000
+
+// ErrEmptyContent is returned when decoding a message without content, see DecodeOptional.
+var ErrEmptyContent = errors.New("empty content")
+
+// The codec registry maps every ContentType to the Go type of its payload.
+var contentTypes = struct {
+	sync.RWMutex
+	types map[ContentType]reflect.Type
+}{types: make(map[ContentType]reflect.Type)}
+
+func init() {
+	mustRegister[RegisterContainerPayload](RegisterContainerContent)
+	mustRegister[RegisterContainerAnswerPayload](RegisterContainerAnswerContent)
+	mustRegister[RegisterAgentPayload](RegisterAgentContent)
+	mustRegister[RegisterAgentAnswerPayload](RegisterAgentAnswerContent)
+	mustRegister[InterAgentAsyncMessagePayload](InterAgentAsyncMessageContent)
+	mustRegister[GetAgentAdressPayload](GetAgentAdressContent)
+	mustRegister[GetAgentAdressAnswerPayload](GetAgentAdressAnswerContent)
+	mustRegister[SetSyncCommunicationPayload](SetSyncCommunicationContent)
+	mustRegister[SetSyncCommunicationAnswerPayload](SetSyncCommunicationAnswerContent)
+	mustRegister[InterAgentSyncMessagePayload](InterAgentSyncMessageContent)
+	mustRegister[DeathPayload](DeathContent)
+	mustRegister[DeregisterAgentPayload](DeregisterAgentContent)
+	mustRegister[DeregisterAgentAnswerPayload](DeregisterAgentAnswerContent)
+	mustRegister[string](TextContent)
+	mustRegister[ErrorPayload](ErrorContent)
+	mustRegister[ServiceDescriptionPayload](ServiceDescriptionContent)
+	mustRegister[DeregisterServicePayload](DeregisterServiceContent)
+	mustRegister[SearchServicePayload](SearchServiceContent)
+	mustRegister[SearchServiceAnswerPayload](SearchServiceAnswerContent)
+	mustRegister[ServiceAnswerPayload](ServiceAnswerContent)
+	mustRegister[DeregisterContainerPayload](DeregisterContainerContent)
+	mustRegister[DeregisterContainerAnswerPayload](DeregisterContainerAnswerContent)
+	mustRegister[MainStatusPayload](MainStatusContent)
+	mustRegister[MainStatusAnswerPayload](MainStatusAnswerContent)
+	mustRegister[RegisterStandbyPayload](RegisterStandbyContent)
+	mustRegister[RegisterStandbyAnswerPayload](RegisterStandbyAnswerContent)
+	mustRegister[ReplicatePayload](ReplicateContent)
+	mustRegister[InvalidateAgentAddressPayload](InvalidateAgentAddressContent)
+	mustRegister[SubscribeRegistryPayload](SubscribeRegistryContent)
+	mustRegister[UnsubscribeRegistryPayload](UnsubscribeRegistryContent)
+	mustRegister[RegistryEventPayload](RegistryEventContent)
+	mustRegister[RenewLeasePayload](RenewLeaseContent)
+	mustRegister[RenewLeaseAnswerPayload](RenewLeaseAnswerContent)
+}
+
+func mustRegister[T any](contentType ContentType) {
+	if err := RegisterContentType[T](contentType); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterContentType declares T as the payload of contentType. Registering the same pair twice is allowed,
+// giving another type to a registered ContentType is an error.
+func RegisterContentType[T any](contentType ContentType) error {
+	payloadType := reflect.TypeOf((*T)(nil)).Elem()
+	contentTypes.Lock()
+	defer contentTypes.Unlock()
+	if registered, ok := contentTypes.types[contentType]; ok && registered != payloadType {
+		return fmt.Errorf("content type %d is already registered for %v", contentType, registered)
+	}
+	contentTypes.types[contentType] = payloadType
+	return nil
+}
+
+// PayloadType returns the Go type registered for contentType.
+func PayloadType(contentType ContentType) (reflect.Type, bool) {
+	contentTypes.RLock()
+	defer contentTypes.RUnlock()
+	payloadType, ok := contentTypes.types[contentType]
+	return payloadType, ok
+}
+
+func checkPayloadType[T any](contentType ContentType) error {
+	registered, ok := PayloadType(contentType)
+	if !ok {
+		return fmt.Errorf("content type %d is not registered", contentType)
+	}
+	if payloadType := reflect.TypeOf((*T)(nil)).Elem(); registered != payloadType {
+		return fmt.Errorf("content type %d carries %v, not %v", contentType, registered, payloadType)
+	}
+	return nil
+}
+
+// Encode serializes the payload of a message whose content type is contentType.
+func Encode[T any](contentType ContentType, payload T) (json.RawMessage, error) {
+	if err := checkPayloadType[T](contentType); err != nil {
+		return nil, err
+	}
+	content, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding content type %d: %w", contentType, err)
+	}
+	return content, nil
+}
+
+// SetContent encodes payload as the content of the message, its dynamic type must be registered for contentType.
+func (message *Message) SetContent(contentType ContentType, payload any) error {
+	registered, ok := PayloadType(contentType)
+	if !ok {
+		return fmt.Errorf("content type %d is not registered", contentType)
+	}
+	if payloadType := reflect.TypeOf(payload); registered != payloadType {
+		return fmt.Errorf("content type %d carries %v, not %v", contentType, registered, payloadType)
+	}
+	content, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error encoding content type %d: %w", contentType, err)
+	}
+	message.ContentType = contentType
+	message.Content = content
+	return nil
+}
+
+// Decode reads the payload of a message, T must be the type registered for its ContentType.
+func Decode[T any](message Message) (T, error) {
+	var payload T
+	if err := checkPayloadType[T](message.ContentType); err != nil {
+		return payload, err
+	}
+	if len(message.Content) == 0 {
+		return payload, fmt.Errorf("content type %d: %w", message.ContentType, ErrEmptyContent)
+	}
+	if err := json.Unmarshal(message.Content, &payload); err != nil {
+		return payload, fmt.Errorf("error decoding content type %d: %w", message.ContentType, err)
+	}
+	return payload, nil
+}
+
+// DecodeOptional reads the payload of a message like Decode, but a message without content gives the zero payload.
+func DecodeOptional[T any](message Message) (T, error) {
+	if len(message.Content) == 0 {
+		var payload T
+		return payload, checkPayloadType[T](message.ContentType)
+	}
+	return Decode[T](message)
+}
+
+// DecodeContent reads the payload of a message into a new value of the type registered for its ContentType.
+func DecodeContent(message Message) (any, error) {
+	payloadType, ok := PayloadType(message.ContentType)
+	if !ok {
+		return nil, fmt.Errorf("content type %d is not registered", message.ContentType)
+	}
+	if len(message.Content) == 0 {
+		return nil, fmt.Errorf("content type %d: %w", message.ContentType, ErrEmptyContent)
+	}
+	payload := reflect.New(payloadType)
+	if err := json.Unmarshal(message.Content, payload.Interface()); err != nil {
+		return nil, fmt.Errorf("error decoding content type %d: %w", message.ContentType, err)
+	}
+	return payload.Elem().Interface(), nil
+}
+
+// NewMessage builds a message carrying payload.
+func NewMessage[T any](messageType MessageType, contentType ContentType, payload T) (Message, error) {
+	content, err := Encode(contentType, payload)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{
+		Type:        messageType,
+		ContentType: contentType,
+		Content:     content,
+	}, nil
+}
+
+// Text builds an agent message whose content is a plain string.
+func Text(text string) Message {
+	content, _ := json.Marshal(text)
+	return Message{
+		Type:        InterAgentAsyncMessage,
+		ContentType: TextContent,
+		Content:     content,
+	}
+}
+
+// Text returns the content of a TextContent message.
+func (message Message) Text() (string, error) {
+	return Decode[string](message)
+}
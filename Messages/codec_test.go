@@ -0,0 +1,43 @@
+package Messages
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	death, err := NewMessage(Death, DeathContent, DeathPayload{AgentID: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		message      Message
+		want         DeathPayload
+		wantErr      bool
+		wantOptional bool // DecodeOptional succeeds
+	}{
+		{"payload", death, DeathPayload{AgentID: 3}, false, true},
+		{"empty content", Message{Type: Death, ContentType: DeathContent}, DeathPayload{}, true, true},
+		{"other content type", Message{Type: Death, ContentType: TextContent}, DeathPayload{}, true, false},
+		{"invalid content", Message{Type: Death, ContentType: DeathContent, Content: []byte("{")}, DeathPayload{}, true, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			payload, err := Decode[DeathPayload](test.message)
+			if (err != nil) != test.wantErr || payload != test.want {
+				t.Errorf("Decode() = %v, %v, want %v", payload, err, test.want)
+			}
+			if _, err := DecodeContent(test.message); (err != nil) != test.wantErr {
+				t.Errorf("DecodeContent() error = %v", err)
+			}
+			payload, err = DecodeOptional[DeathPayload](test.message)
+			if (err == nil) != test.wantOptional || payload != test.want {
+				t.Errorf("DecodeOptional() = %v, %v, want %v", payload, err, test.want)
+			}
+		})
+	}
+	if _, err := Decode[DeathPayload](Message{ContentType: DeathContent}); !errors.Is(err, ErrEmptyContent) {
+		t.Errorf("Decode() of an empty content: %v, want %v", err, ErrEmptyContent)
+	}
+}
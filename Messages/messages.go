@@ -1,6 +1,12 @@
 package Messages
 
-import "strconv"
+import (
+	"FrameworkMultiAgents/YellowPage"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type MessageType int
 type ContentType int
@@ -17,8 +23,37 @@ const (
 	SetSyncCommunication
 	SetSyncCommunicationAnswer
 	InterAgentSyncMessage
+	DeregisterAgent
+	DeregisterAgentAnswer
+	Error
+	RegisterService
+	RegisterServiceAnswer
+	ModifyService
+	ModifyServiceAnswer
+	DeregisterService
+	DeregisterServiceAnswer
+	SearchService
+	SearchServiceAnswer
+	DeregisterContainer
+	DeregisterContainerAnswer
+	MainStatus
+	MainStatusAnswer
+	RegisterStandby
+	RegisterStandbyAnswer
+	Replicate
+	InvalidateAgentAddress
+	SubscribeRegistry
+	SubscribeRegistryAnswer
+	UnsubscribeRegistry
+	UnsubscribeRegistryAnswer
+	RegistryEvent
+	RenewLease
+	RenewLeaseAnswer
 )
 
+// UserMessageType is the first MessageType value left to applications, see NetworkService.RegisterHandler.
+const UserMessageType MessageType = 1000
+
 const (
 	RegisterContainerContent ContentType = iota
 	RegisterContainerAnswerContent
@@ -30,27 +65,66 @@ const (
 	SetSyncCommunicationContent
 	SetSyncCommunicationAnswerContent
 	InterAgentSyncMessageContent
+	DeathContent
+	DeregisterAgentContent
+	DeregisterAgentAnswerContent
+	TextContent
+	ErrorContent
+	ServiceDescriptionContent
+	DeregisterServiceContent
+	SearchServiceContent
+	SearchServiceAnswerContent
+	ServiceAnswerContent
+	DeregisterContainerContent
+	DeregisterContainerAnswerContent
+	MainStatusContent
+	MainStatusAnswerContent
+	RegisterStandbyContent
+	RegisterStandbyAnswerContent
+	ReplicateContent
+	InvalidateAgentAddressContent
+	SubscribeRegistryContent
+	UnsubscribeRegistryContent
+	RegistryEventContent
+	RenewLeaseContent
+	RenewLeaseAnswerContent
 )
 
 type Message struct {
 	Type           MessageType
 	Sender         string
 	ContentType    ContentType
-	Content        string // Serialized content
-	CorrelationID  int64  // Unique ID for matching requests and responses
-	ExpectResponse bool   `json:"expectResponse"`
+	Content        json.RawMessage // Serialized payload, see Encode and Decode
+	CorrelationID  int64           // Unique ID for matching requests and responses
+	ExpectResponse bool            `json:"expectResponse"`
+	IsResponse     bool            `json:"isResponse,omitempty"` // Answer to the request with the same CorrelationID
+
+	// Agent communication envelope (FIPA-ACL), see acl.go
+	Performative   Performative
+	Receivers      []string  // IDs of the agents the message is sent to
+	ReplyTo        []string  // IDs of the agents the replies go to, the sender when empty
+	ConversationID string    // Set by the agents to follow the messages of a protocol
+	ReplyWith      string    // Expected in the InReplyTo of the answers
+	InReplyTo      string    // ReplyWith of the message answered
+	Language       string    // Language of the content
+	Ontology       string    // Ontology giving meaning to the content
+	Protocol       string    // Interaction protocol followed by the conversation
+	ReplyBy        time.Time // Latest time for an answer, zero if none
 }
 
 type RegisterContainerPayload struct {
 	Address string
+	Token   string // join token or pre-shared key, when the main container requires one
 }
 
 type RegisterContainerAnswerPayload struct {
 	ContainerID string
+	Platform    string // name of the platform, the agents are named name@platform
 }
 
 type RegisterAgentPayload struct {
 	ContainerID string
+	Name        string // reserved for the agent, empty for an agent known by its ID only
 }
 
 type InterAgentAsyncMessagePayload struct {
@@ -64,10 +138,12 @@ type RegisterAgentAnswerPayload struct {
 
 type GetAgentAdressPayload struct {
 	AgentID string
+	Name    string // resolves the agent by name when set
 }
 
 type GetAgentAdressAnswerPayload struct {
-	Adress string
+	Adress  string
+	AgentID string
 }
 
 type SetSyncCommunicationPayload struct {
@@ -83,6 +159,114 @@ type InterAgentSyncMessagePayload struct {
 	Content    string
 }
 
+type DeathPayload struct {
+	AgentID int
+}
+
+type DeregisterAgentPayload struct {
+	AgentID string
+}
+
+type DeregisterAgentAnswerPayload struct {
+	Success bool
+}
+
+type DeregisterContainerPayload struct {
+	Address string
+}
+
+type DeregisterContainerAnswerPayload struct {
+	Success bool
+}
+
+// MainStatusPayload asks a main container whether it is the primary one.
+type MainStatusPayload struct {
+	Address string // of the container asking
+}
+
+type MainStatusAnswerPayload struct {
+	Primary     bool
+	Replicating bool // the asking container receives the operations of the primary
+}
+
+// RegisterStandbyPayload asks the primary main container to replicate its yellow page to a standby.
+type RegisterStandbyPayload struct {
+	Address string
+	Token   string // join token, when the primary main container requires one
+}
+
+type RegisterStandbyAnswerPayload struct {
+	Success bool
+}
+
+// ReplicatePayload carries yellow page operations from the primary main container to a standby.
+type ReplicatePayload struct {
+	Operations []YellowPage.Operation
+}
+
+// InvalidateAgentAddressPayload tells the containers to forget the cached addresses of the agents listed
+// and of the agents living at Address, or every address if both are empty.
+type InvalidateAgentAddressPayload struct {
+	AgentIDs []string
+	Address  string
+}
+
+// SubscribeRegistryPayload asks the main container for the yellow page events selected by the filter,
+// answered with ServiceAnswerPayload.
+type SubscribeRegistryPayload struct {
+	Subscription YellowPage.Subscription
+}
+
+type UnsubscribeRegistryPayload struct {
+	SubscriptionID string
+}
+
+// RegistryEventPayload notifies a subscriber of a change of the yellow page.
+type RegistryEventPayload struct {
+	SubscriptionID string
+	AgentID        string // subscribed agent, empty for the container
+	Event          YellowPage.Event
+}
+
+// RenewLeasePayload is the heartbeat of a container, keeping it and its agents registered.
+type RenewLeasePayload struct {
+	Address string
+}
+
+type RenewLeaseAnswerPayload struct {
+	Duration time.Duration // of the lease granted
+}
+
+// ErrorPayload is the answer to a request that could not be handled.
+type ErrorPayload struct {
+	MessageType MessageType // type of the request
+	Error       string
+}
+
+// ServiceDescriptionPayload is the directory entry sent by RegisterService and ModifyService.
+type ServiceDescriptionPayload struct {
+	Description YellowPage.AgentDescription
+}
+
+type DeregisterServicePayload struct {
+	AgentID string
+}
+
+type SearchServicePayload struct {
+	Template   YellowPage.ServiceDescription
+	MaxResults int // 0 for every agent found
+}
+
+type SearchServiceAnswerPayload struct {
+	Results []YellowPage.AgentDescription
+}
+
+// ServiceAnswerPayload answers RegisterService, ModifyService and DeregisterService,
+// failures are answered with an Error message.
+type ServiceAnswerPayload struct {
+	Success bool
+}
+
 func (registerContainerPayload RegisterContainerPayload) String() string {
 	return registerContainerPayload.Address
 }
@@ -104,6 +288,9 @@ func (registerAgentAnswerPayload RegisterAgentAnswerPayload) String() string {
 }
 
 func (getAgentAdressPayload GetAgentAdressPayload) String() string {
+	if getAgentAdressPayload.Name != "" {
+		return getAgentAdressPayload.Name
+	}
 	return getAgentAdressPayload.AgentID
 }
 
@@ -119,6 +306,101 @@ func (setSyncCommunicationAnswerPayload SetSyncCommunicationAnswerPayload) Strin
 	return strconv.FormatBool(setSyncCommunicationAnswerPayload.Success)
 }
 
+func (deathPayload DeathPayload) String() string {
+	return strconv.Itoa(deathPayload.AgentID)
+}
+
+func (deregisterAgentPayload DeregisterAgentPayload) String() string {
+	return deregisterAgentPayload.AgentID
+}
+
+func (deregisterAgentAnswerPayload DeregisterAgentAnswerPayload) String() string {
+	return strconv.FormatBool(deregisterAgentAnswerPayload.Success)
+}
+
+func (deregisterContainerPayload DeregisterContainerPayload) String() string {
+	return deregisterContainerPayload.Address
+}
+
+func (deregisterContainerAnswerPayload DeregisterContainerAnswerPayload) String() string {
+	return strconv.FormatBool(deregisterContainerAnswerPayload.Success)
+}
+
+func (mainStatusPayload MainStatusPayload) String() string {
+	return mainStatusPayload.Address
+}
+
+func (mainStatusAnswerPayload MainStatusAnswerPayload) String() string {
+	return strconv.FormatBool(mainStatusAnswerPayload.Primary)
+}
+
+func (registerStandbyPayload RegisterStandbyPayload) String() string {
+	return registerStandbyPayload.Address
+}
+
+func (registerStandbyAnswerPayload RegisterStandbyAnswerPayload) String() string {
+	return strconv.FormatBool(registerStandbyAnswerPayload.Success)
+}
+
+func (replicatePayload ReplicatePayload) String() string {
+	return strconv.Itoa(len(replicatePayload.Operations))
+}
+
+func (invalidateAgentAddressPayload InvalidateAgentAddressPayload) String() string {
+	if invalidateAgentAddressPayload.Address != "" {
+		return invalidateAgentAddressPayload.Address
+	}
+	return strings.Join(invalidateAgentAddressPayload.AgentIDs, ",")
+}
+
+func (subscribeRegistryPayload SubscribeRegistryPayload) String() string {
+	return subscribeRegistryPayload.Subscription.ID
+}
+
+func (unsubscribeRegistryPayload UnsubscribeRegistryPayload) String() string {
+	return unsubscribeRegistryPayload.SubscriptionID
+}
+
+func (registryEventPayload RegistryEventPayload) String() string {
+	event := registryEventPayload.Event
+	if event.AgentID != "" {
+		return event.Kind.String() + " " + event.AgentID
+	}
+	return event.Kind.String() + " " + event.ContainerID
+}
+
+func (renewLeasePayload RenewLeasePayload) String() string {
+	return renewLeasePayload.Address
+}
+
+func (renewLeaseAnswerPayload RenewLeaseAnswerPayload) String() string {
+	return renewLeaseAnswerPayload.Duration.String()
+}
+
+func (errorPayload ErrorPayload) String() string {
+	return errorPayload.Error
+}
+
+func (serviceDescriptionPayload ServiceDescriptionPayload) String() string {
+	return serviceDescriptionPayload.Description.AgentID
+}
+
+func (deregisterServicePayload DeregisterServicePayload) String() string {
+	return deregisterServicePayload.AgentID
+}
+
+func (searchServicePayload SearchServicePayload) String() string {
+	return searchServicePayload.Template.Type
+}
+
+func (searchServiceAnswerPayload SearchServiceAnswerPayload) String() string {
+	return strconv.Itoa(len(searchServiceAnswerPayload.Results))
+}
+
+func (serviceAnswerPayload ServiceAnswerPayload) String() string {
+	return strconv.FormatBool(serviceAnswerPayload.Success)
+}
+
 func (message Message) String() string {
-	return message.Sender + message.Content
+	return message.Sender + string(message.Content)
 }
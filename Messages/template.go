@@ -0,0 +1,68 @@
+package Messages
+
+// MessageTemplate selects messages, a nil template matches every message.
+type MessageTemplate func(message Message) bool
+
+func (template MessageTemplate) Match(message Message) bool {
+	return template == nil || template(message)
+}
+
+func MatchType(messageType MessageType) MessageTemplate {
+	return func(message Message) bool {
+		return message.Type == messageType
+	}
+}
+
+func MatchContentType(contentType ContentType) MessageTemplate {
+	return func(message Message) bool {
+		return message.ContentType == contentType
+	}
+}
+
+func MatchSender(sender string) MessageTemplate {
+	return func(message Message) bool {
+		return message.Sender == sender
+	}
+}
+
+func MatchCorrelationID(correlationID int64) MessageTemplate {
+	return func(message Message) bool {
+		return message.CorrelationID == correlationID
+	}
+}
+
+func MatchConversationID(conversationID string) MessageTemplate {
+	return func(message Message) bool {
+		return message.ConversationID == conversationID
+	}
+}
+
+// And matches the messages matched by every template.
+func And(templates ...MessageTemplate) MessageTemplate {
+	return func(message Message) bool {
+		for _, template := range templates {
+			if !template.Match(message) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or matches the messages matched by at least one template.
+func Or(templates ...MessageTemplate) MessageTemplate {
+	return func(message Message) bool {
+		for _, template := range templates {
+			if template.Match(message) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func Not(template MessageTemplate) MessageTemplate {
+	return func(message Message) bool {
+		return !template.Match(message)
+	}
+}
@@ -0,0 +1,54 @@
+package Messages
+
+import "testing"
+
+func TestMessageTemplates(t *testing.T) {
+	message := Message{
+		Type:           InterAgentAsyncMessage,
+		ContentType:    TextContent,
+		Sender:         "ping@main",
+		CorrelationID:  7,
+		ConversationID: "game-1",
+		Performative:   Request,
+		InReplyTo:      "ping-3",
+		Ontology:       "ping-pong",
+		Protocol:       "fipa-request",
+	}
+	tests := []struct {
+		name     string
+		template MessageTemplate
+		want     bool
+	}{
+		{"nil matches everything", nil, true},
+		{"type", MatchType(InterAgentAsyncMessage), true},
+		{"other type", MatchType(InterAgentSyncMessage), false},
+		{"content type", MatchContentType(TextContent), true},
+		{"sender", MatchSender("ping@main"), true},
+		{"other sender", MatchSender("pong@main"), false},
+		{"correlation ID", MatchCorrelationID(7), true},
+		{"other correlation ID", MatchCorrelationID(8), false},
+		{"conversation ID", MatchConversationID("game-1"), true},
+		{"performative", MatchPerformative(Request), true},
+		{"other performative", MatchPerformative(Inform), false},
+		{"in reply to", MatchInReplyTo("ping-3"), true},
+		{"ontology", MatchOntology("ping-pong"), true},
+		{"protocol", MatchProtocol("fipa-contract-net"), false},
+		{"and of matches", And(MatchType(InterAgentAsyncMessage), MatchSender("ping@main")), true},
+		{"and with a mismatch", And(MatchType(InterAgentAsyncMessage), MatchSender("pong@main")), false},
+		{"empty and", And(), true},
+		{"and with nil", And(nil, MatchSender("ping@main")), true},
+		{"or with a match", Or(MatchSender("pong@main"), MatchConversationID("game-1")), true},
+		{"or of mismatches", Or(MatchSender("pong@main"), MatchConversationID("game-2")), false},
+		{"empty or", Or(), false},
+		{"not of a mismatch", Not(MatchSender("pong@main")), true},
+		{"not of nil", Not(nil), false},
+		{"nested", And(Or(MatchPerformative(Inform), MatchPerformative(Request)), Not(MatchCorrelationID(0))), true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.template.Match(message); got != test.want {
+				t.Errorf("Match() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package NetworkService
+
+import (
+	"FrameworkMultiAgents/Messages"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Codec encodes the messages exchanged on a connection.
+// The codec of a connection is negotiated during the identifier handshake.
+type Codec interface {
+	Name() string
+	FrameType() int // TextFrame or BinaryFrame
+	Marshal(message Messages.Message) ([]byte, error)
+	Unmarshal(data []byte, message *Messages.Message) error
+}
+
+// DefaultCodecs lists the codecs proposed by a new NetworkService, the preferred one first.
+// JSON is understood by every peer and is used when the handshake does not name a codec.
+var DefaultCodecs = []string{"binary", "gob", "json"}
+
+var codecs = struct {
+	sync.RWMutex
+	byName map[string]Codec
+}{byName: map[string]Codec{
+	"json":   JSONCodec{},
+	"gob":    GobCodec{},
+	"binary": BinaryCodec{},
+}}
+
+// RegisterCodec makes a codec available to the handshake under its name.
+func RegisterCodec(codec Codec) {
+	codecs.Lock()
+	defer codecs.Unlock()
+	codecs.byName[codec.Name()] = codec
+}
+
+// GetCodec returns the codec registered under name.
+func GetCodec(name string) (Codec, bool) {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	codec, ok := codecs.byName[name]
+	return codec, ok
+}
+
+// negotiateCodec picks the first codec proposed by the peer that is also accepted locally.
+func negotiateCodec(proposed, accepted []string) (Codec, error) {
+	if len(proposed) == 0 {
+		proposed = []string{"json"}
+	}
+	for _, name := range proposed {
+		for _, acceptedName := range accepted {
+			if name != acceptedName {
+				continue
+			}
+			if codec, ok := GetCodec(name); ok {
+				return codec, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no common codec in %v and %v", proposed, accepted)
+}
+
+// JSONCodec sends messages as text frames.
+type JSONCodec struct{}
+
+func (JSONCodec) Name() string   { return "json" }
+func (JSONCodec) FrameType() int { return TextFrame }
+
+func (JSONCodec) Marshal(message Messages.Message) ([]byte, error) {
+	return json.Marshal(message)
+}
+
+func (JSONCodec) Unmarshal(data []byte, message *Messages.Message) error {
+	return json.Unmarshal(data, message)
+}
+
+// GobCodec sends every message as a self-contained gob stream.
+type GobCodec struct{}
+
+func (GobCodec) Name() string   { return "gob" }
+func (GobCodec) FrameType() int { return BinaryFrame }
+
+func (GobCodec) Marshal(message Messages.Message) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(message); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (GobCodec) Unmarshal(data []byte, message *Messages.Message) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(message)
+}
+
+// BinaryCodec writes the fields of a message in a fixed order,
+// integers as varints and strings and content prefixed by their length.
+type BinaryCodec struct{}
+
+const binaryCodecVersion = 2
+
+var errShortMessage = errors.New("binary message is truncated")
+
+func (BinaryCodec) Name() string   { return "binary" }
+func (BinaryCodec) FrameType() int { return BinaryFrame }
+
+func (BinaryCodec) Marshal(message Messages.Message) ([]byte, error) {
+	data := make([]byte, 0, 64+len(message.Sender)+len(message.Content))
+	data = append(data, binaryCodecVersion)
+	data = binary.AppendVarint(data, int64(message.Type))
+	data = appendString(data, message.Sender)
+	data = binary.AppendVarint(data, int64(message.ContentType))
+	data = appendBytes(data, message.Content)
+	data = binary.AppendVarint(data, message.CorrelationID)
+	data = appendBool(data, message.ExpectResponse)
+	data = appendBool(data, message.IsResponse)
+	data = binary.AppendVarint(data, int64(message.Performative))
+	data = appendStrings(data, message.Receivers)
+	data = appendStrings(data, message.ReplyTo)
+	data = appendString(data, message.ConversationID)
+	data = appendString(data, message.ReplyWith)
+	data = appendString(data, message.InReplyTo)
+	data = appendString(data, message.Language)
+	data = appendString(data, message.Ontology)
+	data = appendString(data, message.Protocol)
+	var replyBy int64
+	if !message.ReplyBy.IsZero() {
+		replyBy = message.ReplyBy.UnixNano()
+	}
+	data = binary.AppendVarint(data, replyBy)
+	return data, nil
+}
+
+func (BinaryCodec) Unmarshal(data []byte, message *Messages.Message) error {
+	if len(data) == 0 {
+		return errShortMessage
+	}
+	if data[0] != binaryCodecVersion {
+		return fmt.Errorf("unsupported binary message version %d", data[0])
+	}
+	reader := binaryReader{data: data[1:]}
+	message.Type = Messages.MessageType(reader.varint())
+	message.Sender = reader.string()
+	message.ContentType = Messages.ContentType(reader.varint())
+	message.Content = reader.bytes()
+	message.CorrelationID = reader.varint()
+	message.ExpectResponse = reader.bool()
+	message.IsResponse = reader.bool()
+	message.Performative = Messages.Performative(reader.varint())
+	message.Receivers = reader.strings()
+	message.ReplyTo = reader.strings()
+	message.ConversationID = reader.string()
+	message.ReplyWith = reader.string()
+	message.InReplyTo = reader.string()
+	message.Language = reader.string()
+	message.Ontology = reader.string()
+	message.Protocol = reader.string()
+	message.ReplyBy = time.Time{}
+	if replyBy := reader.varint(); replyBy != 0 {
+		message.ReplyBy = time.Unix(0, replyBy)
+	}
+	return reader.err
+}
+
+func appendBytes(data, value []byte) []byte {
+	data = binary.AppendUvarint(data, uint64(len(value)))
+	return append(data, value...)
+}
+
+func appendString(data []byte, value string) []byte {
+	data = binary.AppendUvarint(data, uint64(len(value)))
+	return append(data, value...)
+}
+
+func appendStrings(data []byte, values []string) []byte {
+	data = binary.AppendUvarint(data, uint64(len(values)))
+	for _, value := range values {
+		data = appendString(data, value)
+	}
+	return data
+}
+
+func appendBool(data []byte, value bool) []byte {
+	if value {
+		return append(data, 1)
+	}
+	return append(data, 0)
+}
+
+// binaryReader reads the fields written by BinaryCodec, the first error stops the reading.
+type binaryReader struct {
+	data []byte
+	err  error
+}
+
+func (reader *binaryReader) varint() int64 {
+	if reader.err != nil {
+		return 0
+	}
+	value, n := binary.Varint(reader.data)
+	if n <= 0 {
+		reader.err = errShortMessage
+		return 0
+	}
+	reader.data = reader.data[n:]
+	return value
+}
+
+func (reader *binaryReader) length() int {
+	if reader.err != nil {
+		return 0
+	}
+	value, n := binary.Uvarint(reader.data)
+	if n <= 0 || value > uint64(len(reader.data)-n) {
+		reader.err = errShortMessage
+		return 0
+	}
+	reader.data = reader.data[n:]
+	return int(value)
+}
+
+func (reader *binaryReader) bytes() []byte {
+	length := reader.length()
+	if reader.err != nil || length == 0 {
+		return nil
+	}
+	value := make([]byte, length)
+	copy(value, reader.data)
+	reader.data = reader.data[length:]
+	return value
+}
+
+func (reader *binaryReader) string() string {
+	length := reader.length()
+	if reader.err != nil {
+		return ""
+	}
+	value := string(reader.data[:length])
+	reader.data = reader.data[length:]
+	return value
+}
+
+func (reader *binaryReader) strings() []string {
+	count := reader.length()
+	if reader.err != nil || count == 0 {
+		return nil
+	}
+	values := make([]string, count)
+	for i := range values {
+		values[i] = reader.string()
+	}
+	return values
+}
+
+func (reader *binaryReader) bool() bool {
+	if reader.err != nil {
+		return false
+	}
+	if len(reader.data) == 0 {
+		reader.err = errShortMessage
+		return false
+	}
+	value := reader.data[0] != 0
+	reader.data = reader.data[1:]
+	return value
+}
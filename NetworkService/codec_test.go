@@ -0,0 +1,76 @@
+package NetworkService
+
+import (
+	"FrameworkMultiAgents/Messages"
+	"testing"
+)
+
+func TestNegotiateCodec(t *testing.T) {
+	tests := []struct {
+		name     string
+		proposed []string
+		accepted []string
+		want     string // empty when the negotiation fails
+	}{
+		{"first proposed wins", []string{"binary", "gob", "json"}, []string{"json", "gob", "binary"}, "binary"},
+		{"skips the codecs not accepted", []string{"binary", "gob"}, []string{"json", "gob"}, "gob"},
+		{"old peer proposes nothing", nil, DefaultCodecs, "json"},
+		{"old peer and no json", nil, []string{"binary"}, ""},
+		{"unknown codec", []string{"protobuf"}, []string{"protobuf", "json"}, ""},
+		{"unknown codec then a known one", []string{"protobuf", "json"}, []string{"protobuf", "json"}, "json"},
+		{"nothing in common", []string{"gob"}, []string{"binary"}, ""},
+		{"nothing accepted", []string{"json"}, nil, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			codec, err := negotiateCodec(test.proposed, test.accepted)
+			if test.want == "" {
+				if err == nil {
+					t.Fatalf("negotiated %s, want an error", codec.Name())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if codec.Name() != test.want {
+				t.Errorf("negotiated %s, want %s", codec.Name(), test.want)
+			}
+		})
+	}
+}
+
+func TestCodecsRoundTrip(t *testing.T) {
+	message := Messages.Message{
+		Type:           Messages.InterAgentAsyncMessage,
+		Sender:         "ping@localhost:8080",
+		Receivers:      []string{"pong", "7"},
+		ContentType:    Messages.TextContent,
+		Content:        []byte(`"Ping 1"`),
+		CorrelationID:  42,
+		ExpectResponse: true,
+		ConversationID: "game-1",
+		Performative:   Messages.Request,
+	}
+	for _, name := range DefaultCodecs {
+		t.Run(name, func(t *testing.T) {
+			codec, ok := GetCodec(name)
+			if !ok {
+				t.Fatalf("codec %s is not registered", name)
+			}
+			data, err := codec.Marshal(message)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			var decoded Messages.Message
+			if err := codec.Unmarshal(data, &decoded); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if decoded.Sender != message.Sender || decoded.CorrelationID != message.CorrelationID ||
+				decoded.ConversationID != message.ConversationID || decoded.Performative != message.Performative ||
+				string(decoded.Content) != string(message.Content) || len(decoded.Receivers) != len(message.Receivers) {
+				t.Errorf("decoded %+v, want %+v", decoded, message)
+			}
+		})
+	}
+}
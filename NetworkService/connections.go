@@ -0,0 +1,201 @@
+package NetworkService
+
+import (
+	"FrameworkMultiAgents/Messages"
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// ReconnectPolicy sets how the connection to a peer is dialed again when it is lost.
+type ReconnectPolicy struct {
+	InitialBackoff time.Duration // before the first attempt
+	MaxBackoff     time.Duration
+	Multiplier     float64 // growth of the backoff after each failed attempt
+	Jitter         float64 // fraction of the backoff drawn at random, so that the peers do not reconnect at once
+	MaxAttempts    int     // before the peer is considered gone, 0 disables the reconnection
+	BufferSize     int     // messages without response kept until reconnected, 0 fails them with ErrConnectionLost
+}
+
+// DefaultReconnectPolicy retries for about three seconds and buffers no message.
+var DefaultReconnectPolicy = ReconnectPolicy{
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     2 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+	MaxAttempts:    5,
+}
+
+// reconnection is a lost connection being dialed again.
+type reconnection struct {
+	queue []Messages.Message // sent once reconnected, in order
+	done  chan struct{}      // closed when the peer is connected again or considered gone
+}
+
+// SetReconnectPolicy sets how the lost connections are dialed again.
+func (ns *NetworkService) SetReconnectPolicy(policy ReconnectPolicy) {
+	ns.connPoolMutex.Lock()
+	defer ns.connPoolMutex.Unlock()
+	ns.reconnectPolicy = policy
+}
+
+// backoff returns the wait before the attempt-th reconnection attempt, counted from 0.
+func (policy ReconnectPolicy) backoff(attempt int) time.Duration {
+	backoff := float64(policy.InitialBackoff)
+	for i := 0; i < attempt && backoff < float64(policy.MaxBackoff); i++ {
+		backoff *= policy.Multiplier
+	}
+	if policy.MaxBackoff > 0 && backoff > float64(policy.MaxBackoff) {
+		backoff = float64(policy.MaxBackoff)
+	}
+	backoff += backoff * policy.Jitter * (2*rand.Float64() - 1)
+	return time.Duration(backoff)
+}
+
+// connectionFor returns the connection to the peer at address, waiting for a reconnection in progress.
+// It returns a nil connection when the message was buffered until the reconnection.
+func (ns *NetworkService) connectionFor(ctx context.Context, message Messages.Message, address string) (*connection, error) {
+	ns.connPoolMutex.Lock()
+	if conn := ns.connPool[address]; conn != nil {
+		// the peer connected again on its own
+		ns.connPoolMutex.Unlock()
+		return conn, nil
+	}
+	pending := ns.reconnecting[address]
+	if pending != nil && !message.ExpectResponse {
+		if len(pending.queue) >= ns.reconnectPolicy.BufferSize {
+			ns.connPoolMutex.Unlock()
+			return nil, fmt.Errorf("%w: reconnecting to %s, message dropped", ErrConnectionLost, address)
+		}
+		pending.queue = append(pending.queue, message)
+		ns.connPoolMutex.Unlock()
+		return nil, nil
+	}
+	ns.connPoolMutex.Unlock()
+
+	if pending != nil {
+		select {
+		case <-pending.done:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: reconnecting to %s: %w", ErrConnectionLost, address, ctx.Err())
+		}
+	}
+	conn, err := ns.getConnection(ctx, address)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrConnectionLost, address, err)
+	}
+	return conn, nil
+}
+
+// lost handles the end of the pooled connection to a peer: it is dialed again following the
+// reconnect policy, and the peer is considered gone once the attempts are exhausted.
+func (ns *NetworkService) lost(conn *connection) {
+	ns.connPoolMutex.Lock()
+	if ns.connPool[conn.peer] != conn {
+		// replaced by a newer connection, the peer is still there
+		ns.connPoolMutex.Unlock()
+		return
+	}
+	delete(ns.connPool, conn.peer)
+	policy := ns.reconnectPolicy
+	if policy.MaxAttempts <= 0 || ns.reconnecting[conn.peer] != nil {
+		ns.connPoolMutex.Unlock()
+		if policy.MaxAttempts <= 0 {
+			ns.peerGone(conn.peer)
+		}
+		return
+	}
+	pending := &reconnection{done: make(chan struct{})}
+	ns.reconnecting[conn.peer] = pending
+	ns.connPoolMutex.Unlock()
+	go ns.reconnect(conn.peer, pending, policy)
+}
+
+func (ns *NetworkService) reconnect(address string, pending *reconnection, policy ReconnectPolicy) {
+	for attempt := 0; attempt < policy.MaxAttempts && !ns.isClosed(); attempt++ {
+		time.Sleep(policy.backoff(attempt))
+		conn, dialed := ns.pooled(address), false
+		if conn == nil {
+			var err error
+			conn, err = ns.dialWithTimeout(address)
+			if err != nil {
+				continue
+			}
+			dialed = true
+		}
+		ns.flush(address, pending, conn, dialed)
+		log.Printf("Reconnected to %s after %d attempts", address, attempt+1)
+		return
+	}
+
+	ns.connPoolMutex.Lock()
+	delete(ns.reconnecting, address)
+	dropped := len(pending.queue)
+	ns.connPoolMutex.Unlock()
+	close(pending.done)
+	if dropped > 0 {
+		log.Printf("%d messages to %s dropped", dropped, address)
+	}
+	if !ns.isClosed() {
+		ns.peerGone(address)
+	}
+}
+
+// flush sends the buffered messages on the new connection, then pools it. A connection dialed
+// for the reconnection is listened to once pooled, so that its loss starts another reconnection.
+func (ns *NetworkService) flush(address string, pending *reconnection, conn *connection, dialed bool) {
+	for {
+		ns.connPoolMutex.Lock()
+		queue := pending.queue
+		pending.queue = nil
+		if len(queue) == 0 {
+			switch {
+			case ns.closed:
+				conn.close()
+			case ns.connPool[address] == nil:
+				ns.connPool[address] = conn
+				if dialed {
+					ns.listenTo(conn)
+				}
+			case dialed:
+				// the peer connected again on its own meanwhile, and may answer on either connection
+				ns.listenTo(conn)
+			}
+			delete(ns.reconnecting, address)
+			ns.connPoolMutex.Unlock()
+			close(pending.done)
+			return
+		}
+		ns.connPoolMutex.Unlock()
+		for _, message := range queue {
+			if err := ns.write(context.Background(), conn, message); err != nil {
+				log.Printf("Buffered message to %s dropped: %v", address, err)
+			}
+		}
+	}
+}
+
+func (ns *NetworkService) pooled(address string) *connection {
+	ns.connPoolMutex.Lock()
+	defer ns.connPoolMutex.Unlock()
+	return ns.connPool[address]
+}
+
+func (ns *NetworkService) dialWithTimeout(address string) (*connection, error) {
+	ctx := context.Background()
+	if timeout := time.Duration(ns.requestTimeout.Load()); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return ns.dial(ctx, address)
+}
+
+// peerGone tells the container a peer is unreachable, the main container purges its registrations.
+func (ns *NetworkService) peerGone(address string) {
+	if ns.containerOps != nil && ns.containerOps.DeregisterContainer(address) {
+		log.Printf("Container %s disconnected, its agents are deregistered", address)
+	}
+}
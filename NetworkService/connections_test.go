@@ -0,0 +1,121 @@
+package NetworkService
+
+import (
+	"FrameworkMultiAgents/Messages"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	policy := ReconnectPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: 500 * time.Millisecond, Multiplier: 2}
+	for attempt, want := range []time.Duration{100, 200, 400, 500, 500} {
+		if got := policy.backoff(attempt); got != want*time.Millisecond {
+			t.Errorf("backoff(%d) = %v, want %v", attempt, got, want*time.Millisecond)
+		}
+	}
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := policy.backoff(0); got < 50*time.Millisecond || got > 150*time.Millisecond {
+			t.Fatalf("backoff(0) with jitter = %v, want between 50ms and 150ms", got)
+		}
+	}
+}
+
+// recordSenders reports the senders of the InterAgentAsyncMessage messages, which expect no response.
+func recordSenders(ns *NetworkService) <-chan string {
+	senders := make(chan string, 16)
+	ns.RegisterHandler(Messages.InterAgentAsyncMessage, func(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+		senders <- message.Sender
+		return Messages.Message{}, nil
+	})
+	return senders
+}
+
+// waitReconnecting waits until the service dials the peer at address again.
+func waitReconnecting(t *testing.T, ns *NetworkService, address string) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		ns.connPoolMutex.Lock()
+		reconnecting := ns.reconnecting[address] != nil
+		ns.connPoolMutex.Unlock()
+		if reconnecting {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("not reconnecting to %s", address)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	transport := NewMemoryTransport()
+	dialer := newMemoryService(t, transport, "dialer")
+	dialer.SetReconnectPolicy(ReconnectPolicy{InitialBackoff: 10 * time.Millisecond, Multiplier: 1, MaxAttempts: 100, BufferSize: 2})
+	listener := newMemoryService(t, transport, "listener")
+	recordRequests(listener)
+	if _, err := dialer.SendMessage(context.Background(), mainStatus("dialer"), "listener"); err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+
+	listener.Shutdown(context.Background())
+	waitReconnecting(t, dialer, "listener")
+	for i := 1; i <= 3; i++ {
+		message := Messages.Message{Type: Messages.InterAgentAsyncMessage, Sender: fmt.Sprint(i)}
+		_, err := dialer.SendMessage(context.Background(), message, "listener")
+		if i <= 2 && err != nil {
+			t.Errorf("message %d not buffered: %v", i, err)
+		}
+		if i == 3 && !errors.Is(err, ErrConnectionLost) {
+			t.Errorf("message %d past the buffer: %v, want %v", i, err, ErrConnectionLost)
+		}
+	}
+
+	// back at the same address, the peer gets the buffered messages in order
+	restarted := newMemoryService(t, transport, "listener")
+	senders := recordSenders(restarted)
+	for _, want := range []string{"1", "2"} {
+		select {
+		case sender := <-senders:
+			if sender != want {
+				t.Errorf("message %s received, want %s", sender, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("buffered message %s not received", want)
+		}
+	}
+	if _, err := dialer.SendMessage(context.Background(), Messages.Message{Type: Messages.InterAgentAsyncMessage, Sender: "4"}, "listener"); err != nil {
+		t.Errorf("SendMessage after the reconnection: %v", err)
+	}
+	if sender := <-senders; sender != "4" {
+		t.Errorf("message %s received, want 4", sender)
+	}
+}
+
+func TestReconnectGivesUp(t *testing.T) {
+	transport := NewMemoryTransport()
+	dialer := newMemoryService(t, transport, "dialer")
+	dialer.SetReconnectPolicy(ReconnectPolicy{InitialBackoff: time.Millisecond, Multiplier: 1, MaxAttempts: 3, BufferSize: 2})
+	listener := newMemoryService(t, transport, "listener")
+	recordRequests(listener)
+	if _, err := dialer.SendMessage(context.Background(), mainStatus("dialer"), "listener"); err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+
+	listener.Shutdown(context.Background())
+	waitReconnecting(t, dialer, "listener")
+	// a request waits for the end of the reconnection
+	if _, err := dialer.SendMessage(context.Background(), mainStatus("dialer"), "listener"); !errors.Is(err, ErrConnectionLost) {
+		t.Errorf("request to a peer gone: %v, want %v", err, ErrConnectionLost)
+	}
+	dialer.connPoolMutex.Lock()
+	reconnecting := dialer.reconnecting["listener"] != nil
+	dialer.connPoolMutex.Unlock()
+	if reconnecting {
+		t.Error("still reconnecting after the last attempt")
+	}
+}
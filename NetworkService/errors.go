@@ -0,0 +1,29 @@
+package NetworkService
+
+import (
+	"FrameworkMultiAgents/Messages"
+	"errors"
+	"fmt"
+)
+
+// ErrTimeout is returned by SendMessage when the response did not come before the deadline.
+// The error returned also wraps context.DeadlineExceeded.
+var ErrTimeout = errors.New("timeout waiting for the response")
+
+// ErrConnectionLost is returned by SendMessage when the peer cannot be reached,
+// or when its connection closed before the response came.
+var ErrConnectionLost = errors.New("connection lost")
+
+// ErrClosed is returned once the network service is shut down, see Shutdown.
+var ErrClosed = errors.New("network service closed")
+
+// RemoteError is returned by SendMessage when the peer answers a request with an Error message.
+type RemoteError struct {
+	Address     string               // of the peer
+	MessageType Messages.MessageType // type of the request
+	Message     string
+}
+
+func (err *RemoteError) Error() string {
+	return fmt.Sprintf("error from %s: %s", err.Address, err.Message)
+}
@@ -0,0 +1,212 @@
+package NetworkService
+
+import (
+	"FrameworkMultiAgents/Messages"
+	"FrameworkMultiAgents/YellowPage"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Handler processes an incoming message of the type it is registered for.
+// When the sender expects a response, the returned message is sent back under the CorrelationID
+// of the request, and an error is sent back as an Error message.
+// Handlers run on the goroutine reading the connection, so they must not wait for a response on it.
+type Handler func(ctx context.Context, message Messages.Message) (Messages.Message, error)
+
+// RegisterHandler makes handler process the incoming messages of type messageType,
+// replacing the handler registered before for this type.
+func (ns *NetworkService) RegisterHandler(messageType Messages.MessageType, handler Handler) {
+	ns.handlersMutex.Lock()
+	defer ns.handlersMutex.Unlock()
+	ns.handlers[messageType] = handler
+}
+
+// RemoveHandler stops processing the messages of type messageType.
+func (ns *NetworkService) RemoveHandler(messageType Messages.MessageType) {
+	ns.handlersMutex.Lock()
+	defer ns.handlersMutex.Unlock()
+	delete(ns.handlers, messageType)
+}
+
+// Handler returns the handler processing the messages of type messageType, for instance to wrap it.
+func (ns *NetworkService) Handler(messageType Messages.MessageType) (Handler, bool) {
+	return ns.handlerFor(messageType)
+}
+
+func (ns *NetworkService) handlerFor(messageType Messages.MessageType) (Handler, bool) {
+	ns.handlersMutex.RLock()
+	defer ns.handlersMutex.RUnlock()
+	handler, ok := ns.handlers[messageType]
+	return handler, ok
+}
+
+// NewResponse builds the message returned by a handler.
+func NewResponse(messageType Messages.MessageType, contentType Messages.ContentType, payload any) (Messages.Message, error) {
+	response := Messages.Message{Type: messageType}
+	if err := response.SetContent(contentType, payload); err != nil {
+		return Messages.Message{}, err
+	}
+	return response, nil
+}
+
+func (ns *NetworkService) registerBuiltinHandlers() {
+	ns.RegisterHandler(Messages.RegisterContainer, ns.handleRegisterContainer)
+	ns.RegisterHandler(Messages.RegisterAgent, ns.handleRegisterAgent)
+	ns.RegisterHandler(Messages.InterAgentAsyncMessage, ns.handleInterAgentAsyncMessage)
+	ns.RegisterHandler(Messages.GetAgentAdress, ns.handleGetAgentAdress)
+	ns.RegisterHandler(Messages.SetSyncCommunication, ns.handleSetSyncCommunication)
+	ns.RegisterHandler(Messages.InterAgentSyncMessage, ns.handleInterAgentSyncMessage)
+	ns.RegisterHandler(Messages.Death, ns.handleDeath)
+	ns.RegisterHandler(Messages.DeregisterAgent, ns.handleDeregisterAgent)
+	ns.RegisterHandler(Messages.DeregisterContainer, ns.handleDeregisterContainer)
+}
+
+func (ns *NetworkService) handleRegisterContainer(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+	payload, err := Messages.Decode[Messages.RegisterContainerPayload](message)
+	if err != nil {
+		return Messages.Message{}, err
+	}
+	id := ns.containerOps.RegisterContainer(payload.Address)
+	return NewResponse(Messages.RegisterContainerAnswer, Messages.RegisterContainerAnswerContent, Messages.RegisterContainerAnswerPayload{
+		ContainerID: id,
+		Platform:    ns.containerOps.PlatformName(),
+	})
+}
+
+func (ns *NetworkService) handleRegisterAgent(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+	payload, err := Messages.Decode[Messages.RegisterAgentPayload](message)
+	if err != nil {
+		return Messages.Message{}, err
+	}
+	agentID, err := ns.containerOps.RegisterAgent(payload.ContainerID, payload.Name)
+	if err != nil {
+		return Messages.Message{}, err
+	}
+	id, err := strconv.Atoi(agentID)
+	if err != nil {
+		return Messages.Message{}, fmt.Errorf("invalid agent ID %q: %w", agentID, err)
+	}
+	return NewResponse(Messages.RegisterAgentAnswer, Messages.RegisterAgentAnswerContent, Messages.RegisterAgentAnswerPayload{
+		ID: id,
+	})
+}
+
+func (ns *NetworkService) handleInterAgentAsyncMessage(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+	if len(message.Receivers) > 0 {
+		// agent message, delivered once to each of its receivers living in this container
+		delivered := make(map[int]bool)
+		for _, receiver := range message.Receivers {
+			if receiverID, ok := ns.containerOps.LocalAgentID(receiver); ok && !delivered[receiverID] {
+				delivered[receiverID] = true
+				ns.containerOps.PutMessageInMailBox(message, receiverID)
+			}
+		}
+		return Messages.Message{}, nil
+	}
+	payload, err := Messages.Decode[Messages.InterAgentAsyncMessagePayload](message)
+	if err != nil {
+		return Messages.Message{}, err
+	}
+	if _, ok := ns.containerOps.LocalAgentID(strconv.Itoa(payload.ReceiverID)); !ok {
+		// the sender resolved the agent to an outdated address
+		return Messages.Message{}, fmt.Errorf("%w %d", YellowPage.ErrUnknownAgent, payload.ReceiverID)
+	}
+	ns.containerOps.PutMessageInMailBox(message, payload.ReceiverID)
+	return Messages.Message{}, nil
+}
+
+func (ns *NetworkService) handleGetAgentAdress(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+	payload, err := Messages.Decode[Messages.GetAgentAdressPayload](message)
+	if err != nil {
+		return Messages.Message{}, err
+	}
+	agentID := payload.AgentID
+	var address string
+	if payload.Name != "" {
+		agentID, address, err = ns.containerOps.ResolveAgentName(payload.Name)
+	} else {
+		address, err = ns.containerOps.ResolveAgentAddress(agentID)
+	}
+	if err != nil && !errors.Is(err, YellowPage.ErrUnknownAgent) {
+		return Messages.Message{}, fmt.Errorf("error resolving agent address: %w", err)
+	}
+	// an unknown agent is answered with an empty address
+	return NewResponse(Messages.GetAgentAdressAnswer, Messages.GetAgentAdressAnswerContent, Messages.GetAgentAdressAnswerPayload{
+		Adress:  address,
+		AgentID: agentID,
+	})
+}
+
+func (ns *NetworkService) handleSetSyncCommunication(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+	payload, err := Messages.Decode[Messages.SetSyncCommunicationPayload](message)
+	if err != nil {
+		return Messages.Message{}, err
+	}
+	ns.syncChannelsMutex.Lock()
+	if _, exists := ns.syncChannels[payload.AgentID]; exists {
+		ns.syncChannelsMutex.Unlock()
+		return NewResponse(Messages.SetSyncCommunicationAnswer, Messages.SetSyncCommunicationAnswerContent, Messages.SetSyncCommunicationAnswerPayload{
+			Success: false,
+		})
+	}
+	syncChannel := make(chan Messages.Message)
+	ns.syncChannels[payload.AgentID] = SyncCommunication{
+		receiverID:     payload.AgentID,
+		receiverAdress: message.Sender,
+		syncChannel:    syncChannel,
+	}
+	ns.syncChannelsMutex.Unlock()
+
+	ns.containerOps.UpdateAgentSyncChannel(strconv.Itoa(payload.AgentID), syncChannel)
+	go ns.ListenToSyncChannel(syncChannel, message.Sender)
+	return NewResponse(Messages.SetSyncCommunicationAnswer, Messages.SetSyncCommunicationAnswerContent, Messages.SetSyncCommunicationAnswerPayload{
+		Success: true,
+	})
+}
+
+func (ns *NetworkService) handleInterAgentSyncMessage(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+	payload, err := Messages.Decode[Messages.InterAgentSyncMessagePayload](message)
+	if err != nil {
+		return Messages.Message{}, err
+	}
+	syncChannel, err := ns.GetSyncChannel(payload.ReceiverID)
+	if err != nil {
+		return Messages.Message{}, err
+	}
+	message.Sender = "NetworkService"
+	syncChannel <- message
+	return Messages.Message{}, nil
+}
+
+// handleDeath kills a local agent. The containers accept it from their main containers only,
+// which check the container asking for it.
+func (ns *NetworkService) handleDeath(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+	payload, err := Messages.Decode[Messages.DeathPayload](message)
+	if err != nil {
+		return Messages.Message{}, err
+	}
+	ns.containerOps.PutMessageInMailBox(message, payload.AgentID)
+	return Messages.Message{}, nil
+}
+
+func (ns *NetworkService) handleDeregisterAgent(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+	payload, err := Messages.Decode[Messages.DeregisterAgentPayload](message)
+	if err != nil {
+		return Messages.Message{}, err
+	}
+	return NewResponse(Messages.DeregisterAgentAnswer, Messages.DeregisterAgentAnswerContent, Messages.DeregisterAgentAnswerPayload{
+		Success: ns.containerOps.DeregisterAgent(payload.AgentID),
+	})
+}
+
+func (ns *NetworkService) handleDeregisterContainer(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+	payload, err := Messages.Decode[Messages.DeregisterContainerPayload](message)
+	if err != nil {
+		return Messages.Message{}, err
+	}
+	return NewResponse(Messages.DeregisterContainerAnswer, Messages.DeregisterContainerAnswerContent, Messages.DeregisterContainerAnswerPayload{
+		Success: ns.containerOps.DeregisterContainer(payload.Address),
+	})
+}
@@ -0,0 +1,179 @@
+package NetworkService
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// authority signs the certificates of the containers of a test.
+type authority struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+	pool        *x509.CertPool
+}
+
+func newAuthority(t *testing.T) *authority {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test authority"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(certificate)
+	return &authority{certificate: certificate, key: key, pool: pool}
+}
+
+// issue returns a certificate valid for 127.0.0.1, naming the URIs.
+func (ca *authority) issue(t *testing.T, uris ...string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "container"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	for _, uri := range uris {
+		parsed, err := url.Parse(uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template.URIs = append(template.URIs, parsed)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.certificate, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// transport returns a mutual TLS transport presenting the certificates.
+func (ca *authority) transport(certificates ...tls.Certificate) *WebsocketTransport {
+	return &WebsocketTransport{Path: "/", TLS: &tls.Config{
+		Certificates: certificates,
+		RootCAs:      ca.pool,
+		ClientCAs:    ca.pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}}
+}
+
+func TestMutualTLSIdentifiers(t *testing.T) {
+	ca := newAuthority(t)
+	listener := NewNetworkService("", "")
+	listener.SetTransport(ca.transport(ca.issue(t)))
+	netListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := listener.ListenOn(netListener); err != nil {
+		t.Fatal(err)
+	}
+	go listener.Start()
+	t.Cleanup(func() { listener.Shutdown(context.Background()) })
+	seen := recordRequests(listener)
+
+	tests := []struct {
+		name          string
+		uris          []string
+		identifier    string
+		noCertificate bool
+		wantErr       bool
+	}{
+		{name: "identifier named by the certificate", uris: []string{"container://127.0.0.1:9001"}, identifier: "127.0.0.1:9001"},
+		{name: "one of the identifiers", uris: []string{"container://127.0.0.1:9002", "container://127.0.0.1:9001"}, identifier: "127.0.0.1:9001"},
+		{name: "certificate naming its host only", identifier: "127.0.0.1:9001"},
+		{name: "URI of another scheme", uris: []string{"spiffe://platform/container"}, identifier: "127.0.0.1:9001"},
+		{name: "identifier of the listener", uris: []string{"container://127.0.0.1:9001"}, identifier: listener.LocalAddress, wantErr: true},
+		{name: "other port", uris: []string{"container://127.0.0.1:9002"}, identifier: "127.0.0.1:9001", wantErr: true},
+		{name: "other host", identifier: "10.0.0.1:9001", wantErr: true},
+		{name: "no certificate", identifier: "127.0.0.1:9001", noCertificate: true, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var certificates []tls.Certificate
+			if !test.noCertificate {
+				certificates = append(certificates, ca.issue(t, test.uris...))
+			}
+			dialer := NewNetworkService("", test.identifier)
+			dialer.SetTransport(ca.transport(certificates...))
+			dialer.SetRequestTimeout(2 * time.Second)
+			dialer.SetReconnectPolicy(ReconnectPolicy{})
+			t.Cleanup(func() { dialer.Shutdown(context.Background()) })
+
+			_, err := dialer.SendMessage(context.Background(), mainStatus(test.identifier), listener.LocalAddress)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("the listener accepted the identifier")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SendMessage: %v", err)
+			}
+			if got := (<-seen).peer; got != test.identifier {
+				t.Errorf("peer %q, want %q", got, test.identifier)
+			}
+		})
+	}
+}
+
+func TestSenderBoundToConnection(t *testing.T) {
+	tests := []struct {
+		name       string
+		sender     string
+		wantSender string
+	}{
+		{"honest sender", "dialer", "dialer"},
+		{"spoofed sender", "main", "dialer"},
+		{"empty sender", "", "dialer"},
+	}
+	transport := NewMemoryTransport()
+	dialer := newMemoryService(t, transport, "dialer")
+	listener := newMemoryService(t, transport, "listener")
+	seen := recordRequests(listener)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := dialer.SendMessage(context.Background(), mainStatus(test.sender), "listener"); err != nil {
+				t.Fatalf("SendMessage: %v", err)
+			}
+			got := <-seen
+			if got.sender != test.wantSender || got.peer != test.wantSender {
+				t.Errorf("handler saw sender %q and peer %q, want %q", got.sender, got.peer, test.wantSender)
+			}
+		})
+	}
+}
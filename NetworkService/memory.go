@@ -0,0 +1,162 @@
+package NetworkService
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// memoryBufferLength is the number of frames a memory connection holds before Send blocks.
+const memoryBufferLength = 64
+
+// MemoryTransport connects the containers of a single program through channels, without opening ports.
+// The containers sharing a MemoryTransport reach each other by their addresses, which are plain names.
+type MemoryTransport struct {
+	mutex     sync.Mutex
+	listeners map[string]*memoryListener
+}
+
+func NewMemoryTransport() *MemoryTransport {
+	return &MemoryTransport{listeners: make(map[string]*memoryListener)}
+}
+
+func (transport *MemoryTransport) Dial(ctx context.Context, address string) (Conn, error) {
+	transport.mutex.Lock()
+	listener := transport.listeners[address]
+	transport.mutex.Unlock()
+	if listener == nil {
+		return nil, fmt.Errorf("nothing listens on %s", address)
+	}
+	local, remote := newMemoryPipe()
+	select {
+	case listener.conns <- remote:
+		return local, nil
+	case <-listener.done:
+		return nil, fmt.Errorf("nothing listens on %s", address)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (transport *MemoryTransport) Listen(address string) (Listener, error) {
+	transport.mutex.Lock()
+	defer transport.mutex.Unlock()
+	if _, taken := transport.listeners[address]; taken {
+		return nil, fmt.Errorf("address %s already in use", address)
+	}
+	listener := &memoryListener{
+		transport: transport,
+		address:   address,
+		conns:     make(chan Conn),
+		done:      make(chan struct{}),
+	}
+	transport.listeners[address] = listener
+	return listener, nil
+}
+
+type memoryListener struct {
+	transport *MemoryTransport
+	address   string
+	conns     chan Conn
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (listener *memoryListener) Accept() (Conn, error) {
+	select {
+	case conn := <-listener.conns:
+		return conn, nil
+	case <-listener.done:
+		return nil, ErrListenerClosed
+	}
+}
+
+func (listener *memoryListener) Close() error {
+	listener.closeOnce.Do(func() {
+		close(listener.done)
+		listener.transport.mutex.Lock()
+		if listener.transport.listeners[listener.address] == listener {
+			delete(listener.transport.listeners, listener.address)
+		}
+		listener.transport.mutex.Unlock()
+	})
+	return nil
+}
+
+func (listener *memoryListener) Addr() string {
+	return listener.address
+}
+
+// memoryFrame is a frame in flight between the two ends of a memory pipe.
+type memoryFrame struct {
+	frameType int
+	data      []byte
+}
+
+// memoryConn is an end of a memory pipe, closing either end closes both.
+type memoryConn struct {
+	in       chan memoryFrame
+	out      chan memoryFrame
+	closed   chan struct{}
+	close    *sync.Once
+	deadline chan time.Time // holds the read deadline, see SetReadDeadline
+}
+
+func newMemoryPipe() (*memoryConn, *memoryConn) {
+	forward := make(chan memoryFrame, memoryBufferLength)
+	backward := make(chan memoryFrame, memoryBufferLength)
+	closed := make(chan struct{})
+	once := &sync.Once{}
+	local := &memoryConn{in: backward, out: forward, closed: closed, close: once, deadline: make(chan time.Time, 1)}
+	remote := &memoryConn{in: forward, out: backward, closed: closed, close: once, deadline: make(chan time.Time, 1)}
+	local.deadline <- time.Time{}
+	remote.deadline <- time.Time{}
+	return local, remote
+}
+
+func (conn *memoryConn) Send(frameType int, data []byte) error {
+	frame := memoryFrame{frameType: frameType, data: append([]byte(nil), data...)}
+	select {
+	case <-conn.closed:
+		return io.ErrClosedPipe
+	default:
+	}
+	select {
+	case conn.out <- frame:
+		return nil
+	case <-conn.closed:
+		return io.ErrClosedPipe
+	}
+}
+
+func (conn *memoryConn) Receive() (int, []byte, error) {
+	deadline := <-conn.deadline
+	conn.deadline <- deadline
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case frame := <-conn.in:
+		return frame.frameType, frame.data, nil
+	case <-conn.closed:
+		return 0, nil, io.EOF
+	case <-timeout:
+		return 0, nil, fmt.Errorf("read deadline exceeded")
+	}
+}
+
+func (conn *memoryConn) SetReadDeadline(deadline time.Time) error {
+	<-conn.deadline
+	conn.deadline <- deadline
+	return nil
+}
+
+func (conn *memoryConn) Close() error {
+	conn.close.Do(func() { close(conn.closed) })
+	return nil
+}
@@ -0,0 +1,96 @@
+package NetworkService
+
+import (
+	"FrameworkMultiAgents/Messages"
+	"context"
+	"testing"
+	"time"
+)
+
+// newMemoryService starts a network service listening on the memory transport.
+func newMemoryService(t *testing.T, transport *MemoryTransport, address string, codecs ...string) *NetworkService {
+	t.Helper()
+	ns := NewNetworkService("", address)
+	ns.SetTransport(transport)
+	ns.SetRequestTimeout(2 * time.Second)
+	if len(codecs) > 0 {
+		if err := ns.SetCodecs(codecs...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ns.Listen(); err != nil {
+		t.Fatal(err)
+	}
+	go ns.Start()
+	t.Cleanup(func() { ns.Shutdown(context.Background()) })
+	return ns
+}
+
+// request is what a handler saw of a request.
+type request struct {
+	peer   string
+	sender string
+}
+
+// recordRequests answers MainStatus requests and reports the requests seen.
+func recordRequests(ns *NetworkService) <-chan request {
+	seen := make(chan request, 1)
+	ns.RegisterHandler(Messages.MainStatus, func(ctx context.Context, message Messages.Message) (Messages.Message, error) {
+		peer, _ := Peer(ctx)
+		seen <- request{peer: peer, sender: message.Sender}
+		return NewResponse(Messages.MainStatusAnswer, Messages.MainStatusAnswerContent, Messages.MainStatusAnswerPayload{Primary: true})
+	})
+	return seen
+}
+
+func mainStatus(sender string) Messages.Message {
+	message := Messages.Message{Type: Messages.MainStatus, Sender: sender, ExpectResponse: true}
+	message.SetContent(Messages.MainStatusContent, Messages.MainStatusPayload{Address: sender})
+	return message
+}
+
+func TestHandshake(t *testing.T) {
+	tests := []struct {
+		name      string
+		dialer    []string
+		listener  []string
+		wantCodec string // empty when the handshake fails
+	}{
+		{"default codecs", nil, nil, DefaultCodecs[0]},
+		{"the dialer's preference wins", []string{"json", "binary"}, []string{"binary", "json"}, "json"},
+		{"common codec", []string{"binary", "gob"}, []string{"json", "gob"}, "gob"},
+		{"no common codec", []string{"binary"}, []string{"json"}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transport := NewMemoryTransport()
+			dialer := newMemoryService(t, transport, "dialer", test.dialer...)
+			listener := newMemoryService(t, transport, "listener", test.listener...)
+			seen := recordRequests(listener)
+
+			response, err := dialer.SendMessage(context.Background(), mainStatus("dialer"), "listener")
+			if test.wantCodec == "" {
+				if err == nil {
+					t.Fatal("the request went through without a common codec")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SendMessage: %v", err)
+			}
+			if answer, err := Messages.Decode[Messages.MainStatusAnswerPayload](response); err != nil || !answer.Primary {
+				t.Errorf("answer %+v, %v", answer, err)
+			}
+			if got := (<-seen).peer; got != "dialer" {
+				t.Errorf("peer %q, want dialer", got)
+			}
+			conn := dialer.pooled("listener")
+			if conn == nil {
+				t.Fatal("no pooled connection to the listener")
+			}
+			if conn.codec.Name() != test.wantCodec {
+				t.Errorf("codec %s, want %s", conn.codec.Name(), test.wantCodec)
+			}
+		})
+	}
+}
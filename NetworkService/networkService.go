@@ -3,13 +3,13 @@ package NetworkService
 import (
 	"FrameworkMultiAgents/Messages"
 	"FrameworkMultiAgents/containerOps"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/gorilla/websocket"
-	"io/ioutil"
 	"log"
-	"net/http"
-	"strconv"
+	"net"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -21,98 +21,240 @@ type SyncCommunication struct {
 	syncChannel    chan Messages.Message
 }
 
+// pendingRequest is a request waiting for its response.
+type pendingRequest struct {
+	responses chan Messages.Message
+	conn      *connection   // the request was written to
+	lost      chan struct{} // closed when conn fails before the response
+}
+
+// DefaultRequestTimeout is how long SendMessage waits for a response when neither the context
+// nor the ReplyBy of the message give a deadline, see SetRequestTimeout.
+const DefaultRequestTimeout = 30 * time.Second
+
+// handshake is the first message of a connection, sent as JSON whatever the codec.
+type handshake struct {
+	Identifier string   `json:"identifier"`
+	Codecs     []string `json:"codecs,omitempty"` // proposed codecs, the preferred one first
+}
+
+// handshakeAnswer tells the dialing peer which codec the connection uses.
+type handshakeAnswer struct {
+	Codec string `json:"codec,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
 type NetworkService struct {
 	MainContainerAddress string
 	LocalAddress         string
 	requestCounter       int64 // For generating unique correlation IDs
 	handlerMutex         sync.Mutex
-	responseHandlers     map[int64]chan Messages.Message // Map to track response handlers
-	connPool             map[string]*websocket.Conn
+	responseHandlers     map[int64]*pendingRequest // requests waiting for a response, by CorrelationID
+	requestTimeout       atomic.Int64              // time.Duration, see SetRequestTimeout
+	connPool             map[string]*connection
 	connPoolMutex        sync.Mutex
+	reconnecting         map[string]*reconnection // lost connections being dialed again, see connections.go
+	reconnectPolicy      ReconnectPolicy
+	queueLength          atomic.Int64 // of the outbound queues, see writer.go
+	queuePolicy          atomic.Int32
 	containerOps         containerOps.ContainerOps
 	syncChannels         map[int]SyncCommunication
+	syncChannelsMutex    sync.Mutex
+	handlers             map[Messages.MessageType]Handler
+	handlersMutex        sync.RWMutex
+	codecs               atomic.Pointer[[]string] // codecs accepted on the connections, the preferred one first
+	conns                map[*connection]bool     // every connection read, pooled or not, see listenTo
+	transport            Transport
+	listener             Listener // set by Listen
+	closed               bool     // set by Shutdown
+	serving              sync.WaitGroup
 }
 
 func NewNetworkService(mainContainerAddress, localAddress string) *NetworkService {
 	ns := &NetworkService{
 		MainContainerAddress: mainContainerAddress,
 		LocalAddress:         localAddress,
-		responseHandlers:     make(map[int64]chan Messages.Message),
-		connPool:             make(map[string]*websocket.Conn),
+		responseHandlers:     make(map[int64]*pendingRequest),
+		connPool:             make(map[string]*connection),
+		reconnecting:         make(map[string]*reconnection),
+		reconnectPolicy:      DefaultReconnectPolicy,
 		syncChannels:         make(map[int]SyncCommunication),
+		handlers:             make(map[Messages.MessageType]Handler),
+		conns:                make(map[*connection]bool),
+		transport:            NewWebsocketTransport(),
 	}
+	defaultCodecs := append([]string(nil), DefaultCodecs...)
+	ns.codecs.Store(&defaultCodecs)
+	ns.requestTimeout.Store(int64(DefaultRequestTimeout))
+	ns.SetOutboundQueue(DefaultQueueLength, BlockWhenFull)
+	ns.registerBuiltinHandlers()
 	return ns
 }
 
+// SetRequestTimeout sets how long SendMessage waits for a response when neither the context
+// nor the ReplyBy of the message give a deadline, 0 waits until the context is done.
+func (ns *NetworkService) SetRequestTimeout(timeout time.Duration) {
+	ns.requestTimeout.Store(int64(timeout))
+}
+
+// SetTransport chooses how the connections are dialed and listened to, before Listen.
+func (ns *NetworkService) SetTransport(transport Transport) {
+	ns.connPoolMutex.Lock()
+	defer ns.connPoolMutex.Unlock()
+	ns.transport = transport
+}
+
 func (ns *NetworkService) SetContainerOps(ops containerOps.ContainerOps) {
 	ns.containerOps = ops
 }
 
-func (ns *NetworkService) SendMessage(message Messages.Message, address string) (Messages.Message, error) {
-	correlationID := atomic.AddInt64(&ns.requestCounter, 1)
-	message.CorrelationID = correlationID
+// SetCodecs chooses the codecs proposed and accepted on the next connections, the preferred one first.
+func (ns *NetworkService) SetCodecs(names ...string) error {
+	if len(names) == 0 {
+		return fmt.Errorf("at least one codec is required")
+	}
+	for _, name := range names {
+		if _, ok := GetCodec(name); !ok {
+			return fmt.Errorf("unknown codec %s", name)
+		}
+	}
+	accepted := append([]string(nil), names...)
+	ns.codecs.Store(&accepted)
+	return nil
+}
 
-	var responseChan chan Messages.Message
+// SendMessage sends the message to the container at address. When the message expects a response,
+// it waits for it until ctx is done, until the ReplyBy of the message if set, or for the request timeout
+// when ctx has no deadline. It returns ErrTimeout when the deadline passes, ErrConnectionLost when
+// the peer cannot be reached or its connection closes, ErrQueueFull when the message cannot be queued
+// for writing, see SetOutboundQueue, and a *RemoteError if the peer answers an error.
+func (ns *NetworkService) SendMessage(ctx context.Context, message Messages.Message, address string) (Messages.Message, error) {
+	if err := ctx.Err(); err != nil {
+		return Messages.Message{}, err
+	}
+	// responses keep the CorrelationID of the request they answer
+	correlationID := message.CorrelationID
+	if correlationID == 0 {
+		correlationID = atomic.AddInt64(&ns.requestCounter, 1)
+		message.CorrelationID = correlationID
+	}
 	if message.ExpectResponse {
-		responseChan = make(chan Messages.Message)
-		ns.addHandler(correlationID, responseChan)
-		defer ns.removeHandler(correlationID)
+		var cancel context.CancelFunc
+		ctx, cancel = ns.requestContext(ctx, message)
+		defer cancel()
 	}
 
-	conn, err := ns.getConnection(address)
+	conn, err := ns.connectionFor(ctx, message, address)
 	if err != nil {
-		return Messages.Message{}, fmt.Errorf("error getting connection: %w", err)
+		return Messages.Message{}, err
+	}
+	if conn == nil {
+		// buffered until the peer is connected again
+		return Messages.Message{}, nil
 	}
 
-	messageBytes, err := json.Marshal(message)
-	if err != nil {
-		return Messages.Message{}, fmt.Errorf("error marshaling message: %w", err)
+	var pending *pendingRequest
+	if message.ExpectResponse {
+		pending = &pendingRequest{
+			responses: make(chan Messages.Message, 1),
+			conn:      conn,
+			lost:      make(chan struct{}),
+		}
+		ns.addHandler(correlationID, pending)
+		defer ns.removeHandler(correlationID)
 	}
 
-	if err := conn.WriteMessage(websocket.TextMessage, messageBytes); err != nil {
-		return Messages.Message{}, fmt.Errorf("WriteMessage error: %w", err)
+	if err := ns.write(ctx, conn, message); err != nil {
+		return Messages.Message{}, err
 	}
 
-	if message.ExpectResponse {
-		select {
-		case response := <-responseChan:
-			return response, nil
-		case <-time.After(3000 * time.Second): // Consider making this timeout configurable
-			return Messages.Message{}, fmt.Errorf("timeout waiting for response to message with CorrelationID %d", correlationID)
+	// If no response is expected, return immediately
+	if !message.ExpectResponse {
+		return Messages.Message{}, nil
+	}
+	select {
+	case response := <-pending.responses:
+		if response.Type == Messages.Error {
+			payload, err := Messages.Decode[Messages.ErrorPayload](response)
+			if err != nil {
+				return response, err
+			}
+			return response, &RemoteError{Address: address, MessageType: payload.MessageType, Message: payload.Error}
+		}
+		return response, nil
+	case <-pending.lost:
+		return Messages.Message{}, fmt.Errorf("%w: %s closed before the response to message with CorrelationID %d", ErrConnectionLost, address, correlationID)
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return Messages.Message{}, fmt.Errorf("%w to message with CorrelationID %d from %s (%w)", ErrTimeout, correlationID, address, ctx.Err())
 		}
+		return Messages.Message{}, fmt.Errorf("message with CorrelationID %d to %s: %w", correlationID, address, ctx.Err())
 	}
+}
 
-	// If no response is expected, return immediately
-	return Messages.Message{}, nil
+// requestContext bounds the wait for a response by the ReplyBy of the message,
+// or by the request timeout when ctx has no deadline.
+func (ns *NetworkService) requestContext(ctx context.Context, message Messages.Message) (context.Context, context.CancelFunc) {
+	if !message.ReplyBy.IsZero() {
+		return context.WithDeadline(ctx, message.ReplyBy)
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		if timeout := time.Duration(ns.requestTimeout.Load()); timeout > 0 {
+			return context.WithTimeout(ctx, timeout)
+		}
+	}
+	return context.WithCancel(ctx)
 }
 
-func (ns *NetworkService) getConnection(address string) (*websocket.Conn, error) {
+func (ns *NetworkService) getConnection(ctx context.Context, address string) (*connection, error) {
+	// Use existing connection if available
+	if conn, closed := ns.pooledOrClosed(address); conn != nil {
+		return conn, nil
+	} else if closed {
+		return nil, ErrClosed
+	}
+
+	// Create a new connection without holding the pool, the peer may be dialing us meanwhile
+	dialed, err := ns.dial(ctx, address)
+	if err != nil {
+		return nil, err
+	}
 	ns.connPoolMutex.Lock()
 	defer ns.connPoolMutex.Unlock()
-
-	// Use existing connection if available
-	if conn, exists := ns.connPool[address]; exists && conn != nil {
+	if ns.closed {
+		dialed.close()
+		return nil, ErrClosed
+	}
+	// The dialed connection is read even when another one was pooled meanwhile: the peer may answer on it
+	ns.listenTo(dialed)
+	if conn := ns.connPool[address]; conn != nil {
 		return conn, nil
 	}
+	ns.connPool[address] = dialed
+	return dialed, nil
+}
+
+func (ns *NetworkService) pooledOrClosed(address string) (*connection, bool) {
+	ns.connPoolMutex.Lock()
+	defer ns.connPoolMutex.Unlock()
+	return ns.connPool[address], ns.closed
+}
 
-	// Create a new connection and add it to the pool
-	conn, resp, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://%s", address), nil)
+// dial connects to the peer at address and agrees with it on one of the codecs of the service.
+// It must not be called with connPoolMutex held, as the peer may be accepting our connection meanwhile.
+func (ns *NetworkService) dial(ctx context.Context, address string) (*connection, error) {
+	codecs := *ns.codecs.Load()
+	ns.connPoolMutex.Lock()
+	transport := ns.transport
+	ns.connPoolMutex.Unlock()
+	conn, err := transport.Dial(ctx, address)
 	if err != nil {
-		// Read the response body on bad handshake
-		if resp != nil {
-			bodyBytes, errRead := ioutil.ReadAll(resp.Body)
-			resp.Body.Close()
-			if errRead == nil {
-				log.Printf("Handshake failed with status %d and body: %s\n", resp.StatusCode, string(bodyBytes))
-			}
-		}
-		return nil, fmt.Errorf("WebSocket Dial Error: %w", err)
+		return nil, err
 	}
 
-	initMsg := struct {
-		Identifier string `json:"identifier"`
-	}{
+	initMsg := handshake{
 		Identifier: ns.LocalAddress,
+		Codecs:     codecs,
 	}
 	msgBytes, err := json.Marshal(initMsg)
 	if err != nil {
@@ -120,196 +262,192 @@ func (ns *NetworkService) getConnection(address string) (*websocket.Conn, error)
 		return nil, fmt.Errorf("Error marshaling init message: %w", err)
 	}
 
-	if err := conn.WriteMessage(websocket.TextMessage, msgBytes); err != nil {
+	if err := conn.Send(TextFrame, msgBytes); err != nil {
 		conn.Close() // Close the connection on error
 		return nil, fmt.Errorf("error sending identifier message: %w", err)
 	}
 
-	ns.connPool[address] = conn
-	go ns.startListening(conn)
-	return conn, nil
+	// The peer answers with the codec it picked among ours
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetReadDeadline(deadline)
+	}
+	var answer handshakeAnswer
+	_, answerBytes, err := conn.Receive()
+	if err == nil {
+		err = json.Unmarshal(answerBytes, &answer)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error reading identifier answer: %w", err)
+	}
+	conn.SetReadDeadline(time.Time{})
+	if answer.Error != "" {
+		conn.Close()
+		if strings.HasPrefix(answer.Error, ErrUntrustedPeer.Error()) {
+			return nil, fmt.Errorf("connection refused by %s: %w%s", address, ErrUntrustedPeer, strings.TrimPrefix(answer.Error, ErrUntrustedPeer.Error()))
+		}
+		return nil, fmt.Errorf("connection refused by %s: %s", address, answer.Error)
+	}
+	codec, err := negotiateCodec([]string{answer.Codec}, codecs)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return ns.newConnection(conn, codec, address), nil
+}
+
+func (ns *NetworkService) addHandler(correlationID int64, pending *pendingRequest) {
+	ns.handlerMutex.Lock()
+	defer ns.handlerMutex.Unlock()
+	ns.responseHandlers[correlationID] = pending
 }
 
-func (ns *NetworkService) addHandler(correlationID int64, ch chan Messages.Message) {
+// connectionLost fails the requests waiting for a response on the connection.
+func (ns *NetworkService) connectionLost(conn *connection) {
 	ns.handlerMutex.Lock()
 	defer ns.handlerMutex.Unlock()
-	ns.responseHandlers[correlationID] = ch
+	for correlationID, pending := range ns.responseHandlers {
+		if pending.conn == conn {
+			close(pending.lost)
+			delete(ns.responseHandlers, correlationID)
+		}
+	}
+}
+
+// listenTo starts reading a connection, with connPoolMutex held so that Shutdown closes it and waits for it.
+func (ns *NetworkService) listenTo(conn *connection) {
+	ns.conns[conn] = true
+	ns.serving.Add(1)
+	go ns.startListening(conn)
 }
 
-// startListening reads messages from the WebSocket connection and processes them.
-func (ns *NetworkService) startListening(conn *websocket.Conn) {
-	defer conn.Close()
+// startListening reads messages from the connection and processes them.
+func (ns *NetworkService) startListening(conn *connection) {
+	defer ns.serving.Done()
+	defer func() {
+		ns.connPoolMutex.Lock()
+		delete(ns.conns, conn)
+		ns.connPoolMutex.Unlock()
+	}()
+	defer conn.close()
 	for {
-		_, messageBytes, err := conn.ReadMessage()
+		_, messageBytes, err := conn.conn.Receive()
 		if err != nil {
 			// Log the error and exit the loop if the connection is closed or encounters an error
-			log.Printf("Error reading message: %v", err)
+			if !ns.isClosed() {
+				log.Printf("Error reading message: %v", err)
+			}
 			break // Exit the loop and end the goroutine
 		}
 
 		var message Messages.Message
-		if err := json.Unmarshal(messageBytes, &message); err != nil {
+		if err := conn.codec.Unmarshal(messageBytes, &message); err != nil {
 			log.Printf("Error unmarshaling message: %v", err)
 			continue // Continue the loop, waiting for the next message
 		}
 
 		// Process the incoming message
-		ns.processIncomingMessage(message)
+		if err := ns.processIncomingMessage(conn.peer, message); err != nil {
+			log.Printf("Error processing message of type %d from %s: %v", message.Type, conn.peer, err)
+		}
 	}
 
-	// Perform any cleanup if necessary, e.g., remove the connection from the pool
+	ns.connectionLost(conn)
+	ns.lost(conn)
 }
 
-func (ns *NetworkService) processIncomingMessage(message Messages.Message) {
-	ns.handlerMutex.Lock()
-	defer ns.handlerMutex.Unlock()
-	if ch, exists := ns.responseHandlers[message.CorrelationID]; exists {
-		ch <- message
-	} else {
-		if message.Type == Messages.RegisterContainer {
-			var payload Messages.RegisterContainerPayload
-			if err := json.Unmarshal([]byte(message.Content), &payload); err != nil {
-				fmt.Printf("Error unmarshaling RegisterContainerPayload: %v", err)
-				return
-			}
-			id := ns.containerOps.RegisterContainer(payload.Address)
-			// send response without expecting a reply
-			payload2 := Messages.RegisterContainerAnswerPayload{
-				ContainerID: id,
-			}
-			payloadStr, _ := json.Marshal(payload2)
-			response := Messages.Message{
-				Type:           Messages.RegisterContainerAnswer,
-				Sender:         ns.LocalAddress,
-				ContentType:    Messages.RegisterContainerAnswerContent,
-				Content:        string(payloadStr),
-				CorrelationID:  message.CorrelationID,
-				ExpectResponse: false,
-			}
-			ns.SendMessage(response, message.Sender)
-		} else if message.Type == Messages.RegisterAgent {
-			var payload Messages.RegisterAgentPayload
-			if err := json.Unmarshal([]byte(message.Content), &payload); err != nil {
-				fmt.Printf("Error unmarshaling RegisterAgentPayload: %v", err)
-				return
-			}
-			id, _ := strconv.Atoi(ns.containerOps.RegisterAgent(payload.ContainerID))
+// peerKey keys the identifier of the peer in the context of the handlers, see Peer.
+type peerKey struct{}
 
-			payload2 := Messages.RegisterAgentAnswerPayload{
-				ID: id,
-			}
-			payloadStr, _ := json.Marshal(payload2)
-			response := Messages.Message{
-				Type:           Messages.RegisterAgentAnswer,
-				Sender:         ns.LocalAddress,
-				ContentType:    Messages.RegisterAgentAnswerContent,
-				Content:        string(payloadStr),
-				CorrelationID:  message.CorrelationID,
-				ExpectResponse: false,
-			}
-			ns.SendMessage(response, message.Sender)
-		} else if message.Type == Messages.InterAgentAsyncMessage {
-			var payload Messages.InterAgentAsyncMessagePayload
-			if err := json.Unmarshal([]byte(message.Content), &payload); err != nil {
-				fmt.Printf("Error unmarshaling InterAgentMessagePayload: %v", err)
-				return
-			}
-			ns.containerOps.PutMessageInMailBox(message, payload.ReceiverID)
-		} else if message.Type == Messages.GetAgentAdress {
-			var payload Messages.GetAgentAdressPayload
-			if err := json.Unmarshal([]byte(message.Content), &payload); err != nil {
-				fmt.Printf("Error unmarshaling GetAgentAdressPayload: %v", err)
-				return
-			}
-			address, err := ns.containerOps.ResolveAgentAddress(payload.AgentID)
-			if err != nil {
-				fmt.Printf("Error resolving agent address: %v", err)
-				return
-			}
-			payload2 := Messages.GetAgentAdressAnswerPayload{
-				Adress: address,
-			}
-			payloadStr, _ := json.Marshal(payload2)
-			response := Messages.Message{
-				Type: Messages.GetAgentAdressAnswer,
-
-				Sender:         ns.LocalAddress,
-				ContentType:    Messages.GetAgentAdressAnswerContent,
-				Content:        string(payloadStr),
-				CorrelationID:  message.CorrelationID,
-				ExpectResponse: false,
-			}
-			ns.SendMessage(response, message.Sender)
-		} else if message.Type == Messages.SetSyncCommunication {
-			var payload Messages.SetSyncCommunicationPayload
-			if err := json.Unmarshal([]byte(message.Content), &payload); err != nil {
-				fmt.Printf("Error unmarshaling SetSyncCommunicationPayload: %v", err)
-				return
-			}
-			if _, exists := ns.syncChannels[payload.AgentID]; exists {
-				payload2 := Messages.SetSyncCommunicationAnswerPayload{
-					Success: false,
-				}
-				payloadStr, _ := json.Marshal(payload2)
-				response := Messages.Message{
-					Type:           Messages.SetSyncCommunicationAnswer,
-					Sender:         ns.LocalAddress,
-					ContentType:    Messages.SetSyncCommunicationAnswerContent,
-					Content:        string(payloadStr),
-					CorrelationID:  message.CorrelationID,
-					ExpectResponse: false,
-				}
-				ns.SendMessage(response, message.Sender)
-			} else {
-				ns.syncChannels[payload.AgentID] = SyncCommunication{
-					receiverID:     payload.AgentID,
-					receiverAdress: message.Sender,
-					syncChannel:    make(chan Messages.Message),
-				}
-
-				ns.containerOps.UpdateAgentSyncChannel(strconv.Itoa(payload.AgentID), ns.syncChannels[payload.AgentID].syncChannel)
-				go ns.ListenToSyncChannel(ns.syncChannels[payload.AgentID].syncChannel, message.Sender)
-				payload2 := Messages.SetSyncCommunicationAnswerPayload{
-					Success: true,
-				}
-				payloadStr, _ := json.Marshal(payload2)
-				response := Messages.Message{
-					Type:           Messages.SetSyncCommunicationAnswer,
-					Sender:         ns.LocalAddress,
-					ContentType:    Messages.SetSyncCommunicationAnswerContent,
-					Content:        string(payloadStr),
-					CorrelationID:  message.CorrelationID,
-					ExpectResponse: false,
-				}
-				ns.SendMessage(response, message.Sender)
-			}
-		} else if message.Type == Messages.InterAgentSyncMessage {
-			var payload Messages.InterAgentSyncMessagePayload
-			if err := json.Unmarshal([]byte(message.Content), &payload); err != nil {
-				fmt.Printf("Error unmarshaling InterAgentSyncMessagePayload: %v", err)
-				return
+// Peer returns the identifier of the container a handled message came from: the identifier it gave
+// in the handshake of the connection, verified when the transport authenticates the peers, see
+// AuthenticatedConn, or the address dialed to reach it.
+func Peer(ctx context.Context) (string, bool) {
+	peer, ok := ctx.Value(peerKey{}).(string)
+	return peer, ok
+}
+
+// agentMessage tells whether the Sender of a message names an agent rather than a container.
+func agentMessage(messageType Messages.MessageType) bool {
+	return messageType == Messages.InterAgentAsyncMessage || messageType == Messages.InterAgentSyncMessage
+}
+
+// processIncomingMessage handles a message read on the connection to peer.
+func (ns *NetworkService) processIncomingMessage(peer string, message Messages.Message) error {
+	// A container speaks only for itself: its messages carry the identifier of its connection
+	if !agentMessage(message.Type) {
+		message.Sender = peer
+	}
+	if message.IsResponse {
+		ns.handlerMutex.Lock()
+		defer ns.handlerMutex.Unlock()
+		if pending, exists := ns.responseHandlers[message.CorrelationID]; exists {
+			if pending.conn.peer != peer {
+				return fmt.Errorf("%w: %s answered the request with CorrelationID %d sent to %s", ErrUntrustedPeer, peer, message.CorrelationID, pending.conn.peer)
 			}
-			if _, exists := ns.syncChannels[payload.ReceiverID]; exists {
-				message.Sender = "NetworkService"
-				ns.syncChannels[payload.ReceiverID].syncChannel <- message
-			} else {
-				fmt.Printf("No synchronous channel found for agent with ID %d", payload.ReceiverID)
+			select {
+			case pending.responses <- message:
+			default: // the request already got its response
 			}
+			return nil
+		}
+		return fmt.Errorf("no request waiting for the response with CorrelationID %d", message.CorrelationID)
+	}
 
-		} else {
-			fmt.Printf("No handler found for message with CorrelationID %d", message.CorrelationID)
+	handler, exists := ns.handlerFor(message.Type)
+	if !exists {
+		err := fmt.Errorf("no handler found for message of type %d with CorrelationID %d", message.Type, message.CorrelationID)
+		if message.ExpectResponse {
+			ns.replyError(peer, message, err)
 		}
-		return
+		return err
+	}
+
+	ctx := context.WithValue(context.Background(), peerKey{}, peer)
+	if !message.ReplyBy.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, message.ReplyBy)
+		defer cancel()
+	}
+	response, err := handler(ctx, message)
+	if !message.ExpectResponse {
+		return err
+	}
+	if err != nil {
+		ns.replyError(peer, message, err)
+		return err
+	}
+	return ns.respond(peer, message, response)
+}
+
+// respond sends the response to a request back to the peer it came from, under the CorrelationID of the request.
+func (ns *NetworkService) respond(peer string, request, response Messages.Message) error {
+	response.Sender = ns.LocalAddress
+	response.CorrelationID = request.CorrelationID
+	response.ExpectResponse = false
+	response.IsResponse = true
+	_, err := ns.SendMessage(context.Background(), response, peer)
+	return err
+}
+
+func (ns *NetworkService) replyError(peer string, request Messages.Message, err error) {
+	response, encodeErr := NewResponse(Messages.Error, Messages.ErrorContent, Messages.ErrorPayload{
+		MessageType: request.Type,
+		Error:       err.Error(),
+	})
+	if encodeErr == nil {
+		encodeErr = ns.respond(peer, request, response)
+	}
+	if encodeErr != nil {
+		log.Printf("Error answering message with CorrelationID %d: %v", request.CorrelationID, encodeErr)
 	}
 }
 
 func (ns *NetworkService) removeHandler(correlationID int64) {
 	ns.handlerMutex.Lock()
 	defer ns.handlerMutex.Unlock()
-	if ch, exists := ns.responseHandlers[correlationID]; exists {
-		close(ch)
-		delete(ns.responseHandlers, correlationID)
-	}
+	delete(ns.responseHandlers, correlationID)
 }
 
 /*
@@ -362,7 +500,7 @@ func (ns *NetworkService) ListenToSyncChannel(ch chan Messages.Message, address
 			if message.Sender == "NetworkService" {
 				ch <- message
 			} else {
-				ns.SendMessage(message, address)
+				ns.SendMessage(context.Background(), message, address)
 			}
 		} else {
 			fmt.Printf("Channel closed")
@@ -373,6 +511,8 @@ func (ns *NetworkService) ListenToSyncChannel(ch chan Messages.Message, address
 }
 
 func (ns *NetworkService) CreateSyncChannel(agentID int, receiverAdress string) (chan Messages.Message, error) {
+	ns.syncChannelsMutex.Lock()
+	defer ns.syncChannelsMutex.Unlock()
 	if _, exists := ns.syncChannels[agentID]; exists {
 		return nil, fmt.Errorf("The agent already has a synchronous communication")
 	}
@@ -385,46 +525,221 @@ func (ns *NetworkService) CreateSyncChannel(agentID int, receiverAdress string)
 }
 
 func (ns *NetworkService) GetSyncChannel(agentID int) (chan Messages.Message, error) {
+	ns.syncChannelsMutex.Lock()
+	defer ns.syncChannelsMutex.Unlock()
 	if syncComm, exists := ns.syncChannels[agentID]; exists {
 		return syncComm.syncChannel, nil
 	}
 	return nil, fmt.Errorf("no synchronous channel found for agent with ID %d", agentID)
 }
 
+// Listen binds the local address, so that the peers can connect once Listen returns.
+// On port 0, the port chosen by the system replaces it in LocalAddress.
+func (ns *NetworkService) Listen() error {
+	ns.connPoolMutex.Lock()
+	defer ns.connPoolMutex.Unlock()
+	if ns.listener != nil {
+		return nil
+	}
+	listener, err := ns.transport.Listen(ns.LocalAddress)
+	if err != nil {
+		return fmt.Errorf("error listening on %s: %w", ns.LocalAddress, err)
+	}
+	ns.bind(listener)
+	return nil
+}
+
+// ListenOn serves the connections of the peers on an already bound listener, which the transport
+// must support, as the websocket one does. LocalAddress takes the address of the listener when
+// empty or on port 0.
+func (ns *NetworkService) ListenOn(netListener net.Listener) error {
+	ns.connPoolMutex.Lock()
+	defer ns.connPoolMutex.Unlock()
+	if ns.listener != nil {
+		return fmt.Errorf("already listening on %s", ns.listener.Addr())
+	}
+	server, ok := ns.transport.(interface {
+		Serve(net.Listener) (Listener, error)
+	})
+	if !ok {
+		return fmt.Errorf("transport %T cannot serve a net.Listener", ns.transport)
+	}
+	listener, err := server.Serve(netListener)
+	if err != nil {
+		return err
+	}
+	ns.bind(listener)
+	return nil
+}
+
+// bind keeps the listener, with connPoolMutex held.
+func (ns *NetworkService) bind(listener Listener) {
+	ns.listener = listener
+	address := boundAddress(ns.LocalAddress, listener.Addr())
+	if ns.MainContainerAddress == ns.LocalAddress {
+		// the main container itself
+		ns.MainContainerAddress = address
+	}
+	ns.LocalAddress = address
+}
+
+// boundAddress returns the address the peers reach a listener on, the requested one unless it is empty or on port 0.
+func boundAddress(requested, bound string) string {
+	if requested == "" {
+		return bound
+	}
+	host, port, err := net.SplitHostPort(requested)
+	if err != nil || port != "0" {
+		return requested
+	}
+	if _, boundPort, err := net.SplitHostPort(bound); err == nil {
+		return net.JoinHostPort(host, boundPort)
+	}
+	return bound
+}
+
+// Addr returns the address of the listener, or the empty string before Listen.
+func (ns *NetworkService) Addr() string {
+	ns.connPoolMutex.Lock()
+	defer ns.connPoolMutex.Unlock()
+	if ns.listener == nil {
+		return ""
+	}
+	return ns.listener.Addr()
+}
+
 // create server endpoint
 
+// Start accepts the connections of the peers, calling Listen first if needed. It returns ErrClosed after Shutdown.
 func (ns *NetworkService) Start() error {
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		conn, err := websocket.Upgrade(w, r, nil, 1024, 1024)
+	if err := ns.Listen(); err != nil {
+		return err
+	}
+	ns.connPoolMutex.Lock()
+	listener := ns.listener
+	if ns.closed {
+		ns.connPoolMutex.Unlock()
+		return ErrClosed
+	}
+	ns.serving.Add(1)
+	ns.connPoolMutex.Unlock()
+	defer ns.serving.Done()
+
+	fmt.Printf("Server started on %s\n", listener.Addr())
+	for {
+		conn, err := listener.Accept()
+		if errors.Is(err, ErrListenerClosed) && ns.isClosed() {
+			return ErrClosed
+		}
 		if err != nil {
-			fmt.Printf("Error upgrading connection: %v", err)
-			return
+			return err
 		}
+		go ns.accept(conn)
+	}
+}
 
-		// Read the first message to get the client-provided identifier
-		_, messageBytes, err := conn.ReadMessage()
-		if err != nil {
-			log.Printf("Error reading initial message: %v", err)
+// Shutdown stops accepting connections, closes those to the peers, failing the requests waiting for
+// a response and dropping the messages still queued, then waits until the connections are released
+// or ctx is done. The network service cannot be started again.
+func (ns *NetworkService) Shutdown(ctx context.Context) error {
+	ns.connPoolMutex.Lock()
+	if ns.closed {
+		ns.connPoolMutex.Unlock()
+		return nil
+	}
+	ns.closed = true
+	listener := ns.listener
+	conns := make([]*connection, 0, len(ns.conns))
+	for conn := range ns.conns {
+		conns = append(conns, conn)
+	}
+	ns.connPool = make(map[string]*connection)
+	ns.connPoolMutex.Unlock()
+
+	var err error
+	if listener != nil {
+		err = listener.Close()
+	}
+	for _, conn := range conns {
+		conn.close()
+	}
+	released := make(chan struct{})
+	go func() {
+		ns.serving.Wait()
+		close(released)
+	}()
+	select {
+	case <-released:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (ns *NetworkService) isClosed() bool {
+	ns.connPoolMutex.Lock()
+	defer ns.connPoolMutex.Unlock()
+	return ns.closed
+}
+
+// accept agrees on a codec with the peer that dialed conn, then pools the connection.
+func (ns *NetworkService) accept(conn Conn) {
+	// Read the first message to get the client-provided identifier
+	_, messageBytes, err := conn.Receive()
+	if err != nil {
+		log.Printf("Error reading initial message: %v", err)
+		conn.Close()
+		return
+	}
+
+	var initMsg handshake
+	if err := json.Unmarshal(messageBytes, &initMsg); err != nil {
+		log.Printf("Error unmarshaling initial message: %v", err)
+		conn.Close()
+		return
+	}
+
+	// The identifier is trusted only as far as the transport authenticates the peer
+	if authenticated, ok := conn.(AuthenticatedConn); ok {
+		if err := authenticated.VerifyIdentifier(initMsg.Identifier); err != nil {
+			log.Printf("Connection refused: %v", err)
+			sendJSON(conn, handshakeAnswer{Error: err.Error()})
 			conn.Close()
 			return
 		}
+	}
 
-		var initMsg struct {
-			Identifier string `json:"identifier"`
-		}
-		if err := json.Unmarshal(messageBytes, &initMsg); err != nil {
-			log.Printf("Error unmarshaling initial message: %v", err)
+	codec, err := negotiateCodec(initMsg.Codecs, *ns.codecs.Load())
+	if err != nil {
+		log.Printf("Error negotiating codec with %s: %v", initMsg.Identifier, err)
+		sendJSON(conn, handshakeAnswer{Error: err.Error()})
+		conn.Close()
+		return
+	}
+	// Peers proposing no codec predate the negotiation and do not expect an answer
+	if len(initMsg.Codecs) > 0 {
+		if err := sendJSON(conn, handshakeAnswer{Codec: codec.Name()}); err != nil {
+			log.Printf("Error answering initial message: %v", err)
 			conn.Close()
 			return
 		}
+	}
 
-		ns.connPoolMutex.Lock()
-		ns.connPool[initMsg.Identifier] = conn
-		ns.connPoolMutex.Unlock()
-
-		go ns.startListening(conn)
-	})
+	pooled := ns.newConnection(conn, codec, initMsg.Identifier)
+	ns.connPoolMutex.Lock()
+	defer ns.connPoolMutex.Unlock()
+	if ns.closed {
+		pooled.close()
+		return
+	}
+	ns.connPool[initMsg.Identifier] = pooled
+	ns.listenTo(pooled)
+}
 
-	fmt.Printf("Websocket server started on %s\n", ns.LocalAddress)
-	return http.ListenAndServe(ns.LocalAddress, nil)
+func sendJSON(conn Conn, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return conn.Send(TextFrame, data)
 }
@@ -0,0 +1,280 @@
+package NetworkService
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Frame types of the messages sent on a Conn, with the values of the websocket protocol.
+const (
+	TextFrame   = websocket.TextMessage
+	BinaryFrame = websocket.BinaryMessage
+)
+
+// ErrListenerClosed is returned by Accept once the listener is closed.
+var ErrListenerClosed = errors.New("listener closed")
+
+// ErrUntrustedPeer is returned when a peer claims an identifier its certificate does not allow.
+var ErrUntrustedPeer = errors.New("untrusted peer")
+
+// IdentifierURIScheme is the scheme of the URI SANs binding a certificate to container identifiers,
+// such as container://10.0.0.2:8081, see WebsocketTransport.TLS.
+const IdentifierURIScheme = "container"
+
+// Transport connects the network services of the containers. The websocket transport is the default,
+// the memory transport connects the containers of a single program.
+type Transport interface {
+	Dial(ctx context.Context, address string) (Conn, error)
+	Listen(address string) (Listener, error)
+}
+
+// Listener accepts the connections dialed to its address.
+type Listener interface {
+	Accept() (Conn, error)
+	Close() error
+	Addr() string
+}
+
+// Conn is a message-oriented connection, each frame sent is received whole and in order.
+// Send must not be called concurrently, nor Receive.
+type Conn interface {
+	Send(frameType int, data []byte) error
+	Receive() (frameType int, data []byte, err error)
+	SetReadDeadline(deadline time.Time) error
+	Close() error
+}
+
+// AuthenticatedConn is a Conn whose peer may prove its identity, such as a websocket over mutual TLS.
+// The identifier a peer sends in its handshake is accepted only if VerifyIdentifier allows it.
+type AuthenticatedConn interface {
+	Conn
+	VerifyIdentifier(identifier string) error
+}
+
+// WebsocketTransport carries the messages over websockets, each listener serving its own HTTP server.
+type WebsocketTransport struct {
+	Path string // of the websocket endpoint, the same for every container, "/" when empty
+	// TLS, when set, carries the websockets over TLS (wss://). The same configuration serves and dials:
+	// Certificates is presented to the peers and RootCAs verifies the servers. With ClientAuth set to
+	// tls.RequireAndVerifyClientCert and ClientCAs, the dialing containers must present a certificate
+	// naming the identifier they claim in a URI SAN of the IdentifierURIScheme. A certificate without
+	// such URI is valid for every port of the hosts it names: any container of these hosts may then
+	// claim the identifier of another. See NewTLSTransport.
+	TLS *tls.Config
+}
+
+func NewWebsocketTransport() *WebsocketTransport {
+	return &WebsocketTransport{Path: "/"}
+}
+
+// NewTLSTransport returns a websocket transport over TLS presenting the certificate of certFile and keyFile,
+// and trusting the peers whose certificate is signed by an authority of caFile. With mutual, the dialing
+// containers must present a certificate too, valid for their address, see WebsocketTransport.TLS.
+func NewTLSTransport(certFile, keyFile, caFile string, mutual bool) (*WebsocketTransport, error) {
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading certificate: %w", err)
+	}
+	caBytes, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading certificate authorities: %w", err)
+	}
+	authorities := x509.NewCertPool()
+	if !authorities.AppendCertsFromPEM(caBytes) {
+		return nil, fmt.Errorf("no certificate authority found in %s", caFile)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		RootCAs:      authorities,
+		ClientCAs:    authorities,
+		MinVersion:   tls.VersionTLS12,
+	}
+	if mutual {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return &WebsocketTransport{Path: "/", TLS: config}, nil
+}
+
+func (transport *WebsocketTransport) path() string {
+	if transport.Path == "" {
+		return "/"
+	}
+	return transport.Path
+}
+
+func (transport *WebsocketTransport) Dial(ctx context.Context, address string) (Conn, error) {
+	dialer, scheme := *websocket.DefaultDialer, "ws"
+	if transport.TLS != nil {
+		// the certificate of the peer must be valid for the host of its address
+		dialer.TLSClientConfig, scheme = transport.TLS.Clone(), "wss"
+	}
+	conn, resp, err := dialer.DialContext(ctx, fmt.Sprintf("%s://%s%s", scheme, address, transport.path()), nil)
+	if err != nil {
+		// Read the response body on bad handshake
+		if resp != nil {
+			bodyBytes, errRead := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if errRead == nil {
+				log.Printf("Handshake failed with status %d and body: %s\n", resp.StatusCode, string(bodyBytes))
+			}
+		}
+		return nil, fmt.Errorf("WebSocket Dial Error: %w", err)
+	}
+	return websocketConn{conn: conn}, nil
+}
+
+func (transport *WebsocketTransport) Listen(address string) (Listener, error) {
+	netListener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	return transport.Serve(netListener)
+}
+
+// Serve accepts the websockets on an already bound listener, which is closed with the returned Listener.
+func (transport *WebsocketTransport) Serve(netListener net.Listener) (Listener, error) {
+	listener := &websocketListener{
+		listener: netListener,
+		conns:    make(chan Conn),
+		done:     make(chan struct{}),
+	}
+	if transport.TLS != nil {
+		if len(transport.TLS.Certificates) == 0 && transport.TLS.GetCertificate == nil {
+			return nil, fmt.Errorf("the TLS configuration has no certificate to serve")
+		}
+		listener.mutual = transport.TLS.ClientAuth == tls.RequireAndVerifyClientCert
+		netListener = tls.NewListener(netListener, transport.TLS.Clone())
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(transport.path(), listener.upgrade)
+	listener.server = &http.Server{Handler: mux}
+	go func() {
+		if err := listener.server.Serve(netListener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Websocket server on %s stopped: %v", netListener.Addr(), err)
+		}
+		listener.Close()
+	}()
+	return listener, nil
+}
+
+type websocketListener struct {
+	listener  net.Listener
+	server    *http.Server
+	conns     chan Conn
+	done      chan struct{}
+	closeOnce sync.Once
+	mutual    bool // the peers must present a certificate
+}
+
+func (listener *websocketListener) upgrade(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.Upgrade(w, r, nil, 1024, 1024)
+	if err != nil {
+		log.Printf("Error upgrading connection: %v", err)
+		return
+	}
+	select {
+	case listener.conns <- websocketConn{conn: conn, mutual: listener.mutual}:
+	case <-listener.done:
+		conn.Close()
+	}
+}
+
+func (listener *websocketListener) Accept() (Conn, error) {
+	select {
+	case conn := <-listener.conns:
+		return conn, nil
+	case <-listener.done:
+		return nil, ErrListenerClosed
+	}
+}
+
+func (listener *websocketListener) Close() error {
+	var err error
+	listener.closeOnce.Do(func() {
+		close(listener.done)
+		err = listener.server.Close()
+	})
+	return err
+}
+
+func (listener *websocketListener) Addr() string {
+	return listener.listener.Addr().String()
+}
+
+type websocketConn struct {
+	conn   *websocket.Conn
+	mutual bool
+}
+
+func (conn websocketConn) Send(frameType int, data []byte) error {
+	return conn.conn.WriteMessage(frameType, data)
+}
+
+func (conn websocketConn) Receive() (int, []byte, error) {
+	return conn.conn.ReadMessage()
+}
+
+func (conn websocketConn) SetReadDeadline(deadline time.Time) error {
+	return conn.conn.SetReadDeadline(deadline)
+}
+
+func (conn websocketConn) Close() error {
+	return conn.conn.Close()
+}
+
+// VerifyIdentifier allows the identifier, an address, when the certificate presented by the peer
+// is valid for it, see verifyCertificate. Without TLS, or when the peer presented no certificate
+// while not required to, any identifier is allowed.
+func (conn websocketConn) VerifyIdentifier(identifier string) error {
+	tlsConn, ok := conn.conn.UnderlyingConn().(*tls.Conn)
+	if !ok {
+		return nil
+	}
+	certificates := tlsConn.ConnectionState().PeerCertificates
+	if len(certificates) == 0 {
+		if conn.mutual {
+			return fmt.Errorf("%w: %s presented no certificate", ErrUntrustedPeer, identifier)
+		}
+		return nil
+	}
+	return verifyCertificate(certificates[0], identifier)
+}
+
+// verifyCertificate allows the identifier when the certificate names it in a URI SAN of the
+// IdentifierURIScheme. A certificate naming no identifier is only bound to hosts, it allows
+// the identifiers whose host it is valid for, whatever their port.
+func verifyCertificate(certificate *x509.Certificate, identifier string) error {
+	bound := false
+	for _, uri := range certificate.URIs {
+		if uri.Scheme != IdentifierURIScheme {
+			continue
+		}
+		if uri.Host == identifier {
+			return nil
+		}
+		bound = true
+	}
+	if bound {
+		return fmt.Errorf("%w: %s: the certificate is bound to other identifiers", ErrUntrustedPeer, identifier)
+	}
+	host, _, err := net.SplitHostPort(identifier)
+	if err != nil {
+		host = identifier
+	}
+	if err := certificate.VerifyHostname(host); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrUntrustedPeer, identifier, err)
+	}
+	return nil
+}
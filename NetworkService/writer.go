@@ -0,0 +1,172 @@
+package NetworkService
+
+import (
+	"FrameworkMultiAgents/Messages"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrQueueFull is returned by SendMessage when the outbound queue of the connection is full
+// and the queue policy is FailWhenFull.
+var ErrQueueFull = errors.New("outbound queue full")
+
+// QueuePolicy tells SendMessage what to do when the outbound queue of a connection is full.
+type QueuePolicy int
+
+const (
+	BlockWhenFull QueuePolicy = iota // wait for room until the context is done
+	FailWhenFull                     // return ErrQueueFull at once
+)
+
+// DefaultQueueLength is the number of messages waiting to be written on a connection, see SetOutboundQueue.
+const DefaultQueueLength = 256
+
+// QueueStats describes the outbound queue of a connection.
+type QueueStats struct {
+	Depth    int    // messages waiting to be written
+	MaxDepth int    // highest depth reached
+	Written  uint64 // messages written on the connection
+	Rejected uint64 // messages refused because the queue was full
+}
+
+// frame is a message encoded with the codec of the connection.
+type frame struct {
+	frameType int
+	data      []byte
+}
+
+// connection is a pooled Conn with the codec agreed with the peer. A Conn supports
+// one writer at a time: once the handshake is done, only writeLoop writes on it.
+type connection struct {
+	conn      Conn
+	codec     Codec
+	peer      string // identifier of the peer
+	outbound  chan frame
+	closed    chan struct{}
+	closeOnce sync.Once
+	maxDepth  atomic.Int64
+	written   atomic.Uint64
+	rejected  atomic.Uint64
+}
+
+// SetOutboundQueue sets the length of the outbound queue of the next connections,
+// and what SendMessage does when a queue is full.
+func (ns *NetworkService) SetOutboundQueue(length int, policy QueuePolicy) {
+	if length < 1 {
+		length = 1
+	}
+	ns.queueLength.Store(int64(length))
+	ns.queuePolicy.Store(int32(policy))
+}
+
+// QueueStats returns the state of the outbound queue of each pooled connection, by peer.
+func (ns *NetworkService) QueueStats() map[string]QueueStats {
+	ns.connPoolMutex.Lock()
+	defer ns.connPoolMutex.Unlock()
+	stats := make(map[string]QueueStats, len(ns.connPool))
+	for peer, conn := range ns.connPool {
+		stats[peer] = conn.stats()
+	}
+	return stats
+}
+
+// newConnection starts the writer of a Conn whose handshake is done.
+func (ns *NetworkService) newConnection(conn Conn, codec Codec, peer string) *connection {
+	pooled := &connection{
+		conn:     conn,
+		codec:    codec,
+		peer:     peer,
+		outbound: make(chan frame, ns.queueLength.Load()),
+		closed:   make(chan struct{}),
+	}
+	go pooled.writeLoop()
+	return pooled
+}
+
+// write queues a message on the connection, following the queue policy when the queue is full.
+func (ns *NetworkService) write(ctx context.Context, conn *connection, message Messages.Message) error {
+	data, err := conn.codec.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("error marshaling message: %w", err)
+	}
+	return conn.enqueue(ctx, frame{frameType: conn.codec.FrameType(), data: data}, QueuePolicy(ns.queuePolicy.Load()))
+}
+
+func (conn *connection) enqueue(ctx context.Context, next frame, policy QueuePolicy) error {
+	// checked first, a select would pick at random between a closed connection and a queue with room
+	select {
+	case <-conn.closed:
+		return fmt.Errorf("%w: connection to %s closed", ErrConnectionLost, conn.peer)
+	default:
+	}
+	select {
+	case conn.outbound <- next:
+		conn.queued()
+		return nil
+	default:
+	}
+	if policy == FailWhenFull {
+		conn.rejected.Add(1)
+		return fmt.Errorf("%w: %d messages waiting for %s", ErrQueueFull, cap(conn.outbound), conn.peer)
+	}
+	select {
+	case <-conn.closed:
+		return fmt.Errorf("%w: connection to %s closed", ErrConnectionLost, conn.peer)
+	case conn.outbound <- next:
+		conn.queued()
+		return nil
+	case <-ctx.Done():
+		conn.rejected.Add(1)
+		return fmt.Errorf("%w: %d messages waiting for %s: %w", ErrQueueFull, cap(conn.outbound), conn.peer, ctx.Err())
+	}
+}
+
+// queued records the depth reached by the queue.
+func (conn *connection) queued() {
+	depth := int64(len(conn.outbound))
+	for {
+		maxDepth := conn.maxDepth.Load()
+		if depth <= maxDepth || conn.maxDepth.CompareAndSwap(maxDepth, depth) {
+			return
+		}
+	}
+}
+
+// writeLoop writes the queued messages in order. A failed write closes the connection,
+// its read loop then ends and the peer is dialed again.
+func (conn *connection) writeLoop() {
+	for {
+		select {
+		case <-conn.closed:
+			return
+		case next := <-conn.outbound:
+			if err := conn.conn.Send(next.frameType, next.data); err != nil {
+				log.Printf("Error writing to %s: %v", conn.peer, err)
+				conn.close()
+				return
+			}
+			conn.written.Add(1)
+		}
+	}
+}
+
+// close stops the writer and closes the Conn, the messages still queued are dropped.
+func (conn *connection) close() {
+	conn.closeOnce.Do(func() {
+		close(conn.closed)
+		conn.conn.Close()
+	})
+}
+
+func (conn *connection) stats() QueueStats {
+	return QueueStats{
+		Depth:    len(conn.outbound),
+		MaxDepth: int(conn.maxDepth.Load()),
+		Written:  conn.written.Load(),
+		Rejected: conn.rejected.Load(),
+	}
+}
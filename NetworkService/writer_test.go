@@ -0,0 +1,115 @@
+package NetworkService
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// gatedConn is a Conn whose Send reports each frame, then waits for the gate to open.
+type gatedConn struct {
+	sending chan string
+	gate    chan struct{}
+}
+
+func newGatedConn() *gatedConn {
+	return &gatedConn{sending: make(chan string, 16), gate: make(chan struct{})}
+}
+
+func (conn *gatedConn) Send(frameType int, data []byte) error {
+	conn.sending <- string(data)
+	<-conn.gate
+	return nil
+}
+func (conn *gatedConn) Receive() (int, []byte, error)            { select {} }
+func (conn *gatedConn) SetReadDeadline(deadline time.Time) error { return nil }
+func (conn *gatedConn) Close() error                             { return nil }
+
+func TestOutboundQueue(t *testing.T) {
+	timedOut := func() context.Context {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		t.Cleanup(cancel)
+		return ctx
+	}
+	tests := []struct {
+		name    string
+		policy  QueuePolicy
+		ctx     func() context.Context
+		open    bool  // the writer resumes while the frame waits for room
+		wantErr error // for the frame sent to the full queue
+	}{
+		{"fail when full", FailWhenFull, context.Background, false, ErrQueueFull},
+		{"block until the context is done", BlockWhenFull, timedOut, false, context.DeadlineExceeded},
+		{"block until there is room", BlockWhenFull, context.Background, true, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ns := NewNetworkService("", "local")
+			ns.SetOutboundQueue(2, test.policy)
+			gated := newGatedConn()
+			conn := ns.newConnection(gated, nil, "peer")
+			t.Cleanup(conn.close)
+			send := func(ctx context.Context, data string) error {
+				return conn.enqueue(ctx, frame{data: []byte(data)}, test.policy)
+			}
+
+			// the writer holds the first frame, the next two fill the queue
+			if err := send(context.Background(), "1"); err != nil {
+				t.Fatal(err)
+			}
+			<-gated.sending
+			for _, data := range []string{"2", "3"} {
+				if err := send(context.Background(), data); err != nil {
+					t.Fatalf("frame %s: %v", data, err)
+				}
+			}
+			if test.open {
+				time.AfterFunc(10*time.Millisecond, func() { close(gated.gate) })
+			}
+			err := send(test.ctx(), "4")
+			if test.wantErr == nil && err != nil || test.wantErr != nil && !errors.Is(err, test.wantErr) {
+				t.Fatalf("frame sent to the full queue: %v, want %v", err, test.wantErr)
+			}
+			stats := conn.stats()
+			if stats.MaxDepth != 2 || (stats.Rejected == 1) != (test.wantErr != nil) {
+				t.Errorf("stats %+v", stats)
+			}
+			if !test.open {
+				close(gated.gate)
+			}
+
+			// the frames are written in order
+			want := []string{"2", "3"}
+			if test.wantErr == nil {
+				want = append(want, "4")
+			}
+			for _, data := range want {
+				select {
+				case sent := <-gated.sending:
+					if sent != data {
+						t.Errorf("frame %s written, want %s", sent, data)
+					}
+				case <-time.After(time.Second):
+					t.Fatalf("frame %s not written", data)
+				}
+			}
+		})
+	}
+}
+
+func TestClosedConnection(t *testing.T) {
+	ns := NewNetworkService("", "local")
+	gated := newGatedConn()
+	close(gated.gate)
+	conn := ns.newConnection(gated, nil, "peer")
+	conn.close()
+	for _, policy := range []QueuePolicy{BlockWhenFull, FailWhenFull} {
+		t.Run(fmt.Sprint(policy), func(t *testing.T) {
+			if err := conn.enqueue(context.Background(), frame{data: []byte("1")}, policy); !errors.Is(err, ErrConnectionLost) {
+				t.Errorf("frame sent on a closed connection: %v, want %v", err, ErrConnectionLost)
+			}
+		})
+	}
+}
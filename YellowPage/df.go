@@ -0,0 +1,126 @@
+package YellowPage
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ServiceDescription describes a service offered by an agent (FIPA Directory Facilitator).
+// In a search template, the empty fields match any service.
+type ServiceDescription struct {
+	Type       string
+	Name       string
+	Protocols  []string
+	Ontologies []string
+	Languages  []string
+	Properties map[string]string
+}
+
+// AgentDescription is the entry of an agent in the directory.
+type AgentDescription struct {
+	AgentID  string
+	Services []ServiceDescription
+}
+
+// Matches tells whether the service has the fields of the template: same type and name,
+// every protocol, ontology and language of the template, and the same value for its properties.
+func (service ServiceDescription) Matches(template ServiceDescription) bool {
+	if template.Type != "" && template.Type != service.Type {
+		return false
+	}
+	if template.Name != "" && template.Name != service.Name {
+		return false
+	}
+	if !containsAll(service.Protocols, template.Protocols) ||
+		!containsAll(service.Ontologies, template.Ontologies) ||
+		!containsAll(service.Languages, template.Languages) {
+		return false
+	}
+	for key, value := range template.Properties {
+		if serviceValue, ok := service.Properties[key]; !ok || serviceValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+func containsAll(values, wanted []string) bool {
+	for _, w := range wanted {
+		found := false
+		for _, value := range values {
+			if value == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// RegisterService adds the description of a registered agent to the directory.
+func (yellowPage *YellowPage) RegisterService(description AgentDescription) error {
+	yellowPage.mutex.Lock()
+	defer yellowPage.mutex.Unlock()
+	if _, ok := yellowPage.AgentRegistry[description.AgentID]; !ok {
+		return fmt.Errorf("%w %s", ErrUnknownAgent, description.AgentID)
+	}
+	if _, ok := yellowPage.ServiceRegistry[description.AgentID]; ok {
+		return fmt.Errorf("agent %s is already registered in the directory", description.AgentID)
+	}
+	yellowPage.commit(Operation{Kind: ServiceRegistered, ID: description.AgentID, Description: &description})
+	return nil
+}
+
+// ModifyService replaces the description of an agent already in the directory.
+func (yellowPage *YellowPage) ModifyService(description AgentDescription) error {
+	yellowPage.mutex.Lock()
+	defer yellowPage.mutex.Unlock()
+	if _, ok := yellowPage.ServiceRegistry[description.AgentID]; !ok {
+		return fmt.Errorf("agent %s is not registered in the directory", description.AgentID)
+	}
+	yellowPage.commit(Operation{Kind: ServiceRegistered, ID: description.AgentID, Description: &description})
+	return nil
+}
+
+// DeregisterService removes an agent and its services from the directory.
+func (yellowPage *YellowPage) DeregisterService(agentID string) error {
+	yellowPage.mutex.Lock()
+	defer yellowPage.mutex.Unlock()
+	if _, ok := yellowPage.ServiceRegistry[agentID]; !ok {
+		return fmt.Errorf("agent %s is not registered in the directory", agentID)
+	}
+	yellowPage.commit(Operation{Kind: ServiceDeregistered, ID: agentID})
+	return nil
+}
+
+// SearchServices returns the agents offering a service that matches the template,
+// keeping only the matching services. maxResults <= 0 returns every agent found.
+func (yellowPage *YellowPage) SearchServices(template ServiceDescription, maxResults int) []AgentDescription {
+	yellowPage.mutex.Lock()
+	defer yellowPage.mutex.Unlock()
+	results := []AgentDescription{}
+	for _, description := range yellowPage.ServiceRegistry {
+		var services []ServiceDescription
+		for _, service := range description.Services {
+			if service.Matches(template) {
+				services = append(services, service)
+			}
+		}
+		if len(services) == 0 {
+			continue
+		}
+		results = append(results, AgentDescription{AgentID: description.AgentID, Services: services})
+	}
+	// numeric IDs in increasing order
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i].AgentID, results[j].AgentID
+		return len(a) < len(b) || (len(a) == len(b) && a < b)
+	})
+	if maxResults > 0 && len(results) > maxResults {
+		results = results[:maxResults]
+	}
+	return results
+}
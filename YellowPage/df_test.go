@@ -0,0 +1,116 @@
+package YellowPage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestServiceMatches(t *testing.T) {
+	service := ServiceDescription{
+		Type:       "game",
+		Name:       "ping-pong",
+		Protocols:  []string{"fipa-request", "fipa-query"},
+		Ontologies: []string{"ping-pong"},
+		Languages:  []string{"json"},
+		Properties: map[string]string{"players": "2", "speed": "fast"},
+	}
+	tests := []struct {
+		name     string
+		template ServiceDescription
+		want     bool
+	}{
+		{"empty template", ServiceDescription{}, true},
+		{"type", ServiceDescription{Type: "game"}, true},
+		{"other type", ServiceDescription{Type: "worker"}, false},
+		{"type and name", ServiceDescription{Type: "game", Name: "ping-pong"}, true},
+		{"other name", ServiceDescription{Name: "chess"}, false},
+		{"one protocol", ServiceDescription{Protocols: []string{"fipa-query"}}, true},
+		{"every protocol", ServiceDescription{Protocols: []string{"fipa-query", "fipa-request"}}, true},
+		{"missing protocol", ServiceDescription{Protocols: []string{"fipa-request", "fipa-contract-net"}}, false},
+		{"ontology", ServiceDescription{Ontologies: []string{"ping-pong"}}, true},
+		{"missing language", ServiceDescription{Languages: []string{"xml"}}, false},
+		{"property", ServiceDescription{Properties: map[string]string{"players": "2"}}, true},
+		{"other property value", ServiceDescription{Properties: map[string]string{"players": "4"}}, false},
+		{"missing property", ServiceDescription{Properties: map[string]string{"board": "square"}}, false},
+		{"empty property value", ServiceDescription{Properties: map[string]string{"speed": ""}}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := service.Matches(test.template); got != test.want {
+				t.Errorf("Matches() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSearchServices(t *testing.T) {
+	yellowPage := NewYellowPage()
+	yellowPage.RegisterContainer("container-1")
+	descriptions := []AgentDescription{
+		{Services: []ServiceDescription{{Type: "game", Name: "ping-pong"}, {Type: "worker"}}},
+		{Services: []ServiceDescription{{Type: "worker", Properties: map[string]string{"gpu": "yes"}}}},
+		{Services: []ServiceDescription{{Type: "game", Name: "chess"}}},
+	}
+	for i := range descriptions {
+		descriptions[i].AgentID = yellowPage.RegisterAgent("container-1")
+		if err := yellowPage.RegisterService(descriptions[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first, second, third := descriptions[0].AgentID, descriptions[1].AgentID, descriptions[2].AgentID
+
+	tests := []struct {
+		name       string
+		template   ServiceDescription
+		maxResults int
+		want       []AgentDescription
+	}{
+		{"keeps the matching services only", ServiceDescription{Type: "worker"}, 0, []AgentDescription{
+			{AgentID: first, Services: []ServiceDescription{{Type: "worker"}}},
+			{AgentID: second, Services: descriptions[1].Services},
+		}},
+		{"by name", ServiceDescription{Name: "chess"}, 0, []AgentDescription{
+			{AgentID: third, Services: descriptions[2].Services},
+		}},
+		{"by property", ServiceDescription{Properties: map[string]string{"gpu": "yes"}}, 0, []AgentDescription{
+			{AgentID: second, Services: descriptions[1].Services},
+		}},
+		{"max results", ServiceDescription{}, 1, []AgentDescription{descriptions[0]}},
+		{"nothing found", ServiceDescription{Type: "printer"}, 0, []AgentDescription{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := yellowPage.SearchServices(test.template, test.maxResults)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("SearchServices() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestServiceRegistrationErrors(t *testing.T) {
+	yellowPage := NewYellowPage()
+	yellowPage.RegisterContainer("container-1")
+	agentID := yellowPage.RegisterAgent("container-1")
+	description := AgentDescription{AgentID: agentID, Services: []ServiceDescription{{Type: "worker"}}}
+
+	tests := []struct {
+		name    string
+		do      func() error
+		wantErr bool
+	}{
+		{"unknown agent", func() error { return yellowPage.RegisterService(AgentDescription{AgentID: "404"}) }, true},
+		{"modify before registering", func() error { return yellowPage.ModifyService(description) }, true},
+		{"register", func() error { return yellowPage.RegisterService(description) }, false},
+		{"register twice", func() error { return yellowPage.RegisterService(description) }, true},
+		{"modify", func() error { return yellowPage.ModifyService(description) }, false},
+		{"deregister", func() error { return yellowPage.DeregisterService(agentID) }, false},
+		{"deregister twice", func() error { return yellowPage.DeregisterService(agentID) }, true},
+	}
+	// the steps depend on each other, they run in order
+	for _, test := range tests {
+		if err := test.do(); (err != nil) != test.wantErr {
+			t.Errorf("%s: error %v, want error %v", test.name, err, test.wantErr)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package YellowPage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrLeaseExpired is returned when renewing the lease of a container that is not registered anymore.
+var ErrLeaseExpired = errors.New("lease expired")
+
+// RenewLease keeps the container registered at address until expires, or until the next renewal.
+// The leases are not persisted nor replicated, see ExpireLeases.
+func (yellowPage *YellowPage) RenewLease(address string, expires time.Time) error {
+	yellowPage.mutex.Lock()
+	defer yellowPage.mutex.Unlock()
+	if !yellowPage.containerRegistered(address) {
+		return fmt.Errorf("%w: container %s is not registered", ErrLeaseExpired, address)
+	}
+	if yellowPage.leases == nil {
+		yellowPage.leases = make(map[string]time.Time)
+	}
+	yellowPage.leases[address] = expires
+	return nil
+}
+
+// ExpireLeases deregisters the containers whose lease ended before now, with their agents,
+// and returns their addresses. The containers without a lease, registered before the yellow page
+// was loaded or replicated, are given one until now plus grace.
+func (yellowPage *YellowPage) ExpireLeases(now time.Time, grace time.Duration) []string {
+	yellowPage.mutex.Lock()
+	defer yellowPage.mutex.Unlock()
+	if yellowPage.leases == nil {
+		yellowPage.leases = make(map[string]time.Time)
+	}
+	var expired []string
+	for _, address := range yellowPage.ContainerRegistry {
+		expires, ok := yellowPage.leases[address]
+		if !ok {
+			yellowPage.leases[address] = now.Add(grace)
+			continue
+		}
+		if now.After(expires) {
+			expired = append(expired, address)
+		}
+	}
+	for _, address := range expired {
+		yellowPage.commit(Operation{Kind: ContainerDeregistered, Address: address})
+	}
+	return expired
+}
+
+// containerRegistered tells whether a container has the address, the mutex must be held.
+func (yellowPage *YellowPage) containerRegistered(address string) bool {
+	for _, containerAddress := range yellowPage.ContainerRegistry {
+		if containerAddress == address {
+			return true
+		}
+	}
+	return false
+}
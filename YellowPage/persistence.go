@@ -0,0 +1,443 @@
+package YellowPage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// OperationKind is the kind of change made to the yellow page.
+type OperationKind int
+
+const (
+	ContainerRegistered   OperationKind = iota // ID and Address of the container
+	ContainerDeregistered                      // Address of the container, its agents are removed too
+	AgentRegistered                            // ID of the agent, Address of its container and Name if it has one
+	AgentDeregistered                          // ID of the agent
+	ServiceRegistered                          // ID of the agent and its Description, registered or modified
+	ServiceDeregistered                        // ID of the agent
+	Cleared                                    // every container, agent and service is removed, not the IDs handed out
+	AgentIDReserved                            // ID handed out to an agent, never to be given again
+	ContainerIDReserved                        // ID handed out to a container, never to be given again
+	SubscriptionAdded                          // ID and Subscription, see subscriptions.go
+	SubscriptionRemoved                        // ID of the subscription
+)
+
+// Operation is a change made to the yellow page. Applying the operations in order rebuilds it,
+// and applying an operation twice gives the same state.
+type Operation struct {
+	Kind         OperationKind
+	ID           string            `json:",omitempty"`
+	Address      string            `json:",omitempty"`
+	Name         string            `json:",omitempty"`
+	Description  *AgentDescription `json:",omitempty"`
+	Subscription *Subscription     `json:",omitempty"`
+}
+
+const (
+	logFileName      = "yellowpage.log"
+	snapshotFileName = "yellowpage.snapshot"
+)
+
+// DefaultSnapshotInterval is the number of operations written to the log between two snapshots.
+const DefaultSnapshotInterval = 1000
+
+// Journal writes the operations of a yellow page to an append-only log in a directory,
+// and replaces the log by a snapshot of the yellow page every SnapshotInterval operations.
+type Journal struct {
+	SnapshotInterval int
+	// SyncWrites flushes every operation to the disk, so the log survives a crash of the machine
+	// and not only of the process.
+	SyncWrites bool
+
+	directory  string
+	logFile    *os.File
+	size       int64 // of the complete records of the log
+	operations int   // written since the last snapshot
+}
+
+// snapshot is the content of the snapshot file.
+type snapshot struct {
+	AgentRegistry     map[string]string
+	ContainerRegistry map[string]string
+	ServiceRegistry   map[string]AgentDescription
+	NameRegistry      map[string]string
+	Subscriptions     map[string]Subscription
+	MaxIDAgent        uint64
+	MaxIDContainer    uint64
+}
+
+// OpenYellowPage loads the yellow page persisted in directory, or creates an empty one,
+// and records its next operations there. The directory is created if needed.
+func OpenYellowPage(directory string) (*YellowPage, error) {
+	if err := os.MkdirAll(directory, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating the yellow page directory: %w", err)
+	}
+	yellowPage := NewYellowPage()
+	if err := yellowPage.loadSnapshot(filepath.Join(directory, snapshotFileName)); err != nil {
+		return nil, err
+	}
+	replayed, size, err := yellowPage.replayLog(filepath.Join(directory, logFileName))
+	if err != nil {
+		return nil, err
+	}
+	logFile, err := os.OpenFile(filepath.Join(directory, logFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening the yellow page log: %w", err)
+	}
+	// the next records must not be appended to a line cut by a crash
+	if err := logFile.Truncate(size); err != nil {
+		logFile.Close()
+		return nil, fmt.Errorf("error truncating the yellow page log: %w", err)
+	}
+	yellowPage.journal = &Journal{
+		SnapshotInterval: DefaultSnapshotInterval,
+		directory:        directory,
+		logFile:          logFile,
+		size:             size,
+		operations:       replayed,
+	}
+	return yellowPage, nil
+}
+
+// Journal returns the journal of a persistent yellow page, nil otherwise.
+func (yellowPage *YellowPage) Journal() *Journal {
+	return yellowPage.journal
+}
+
+// Apply makes changes received from elsewhere, such as the log of another yellow page.
+func (yellowPage *YellowPage) Apply(operations ...Operation) {
+	yellowPage.mutex.Lock()
+	defer yellowPage.mutex.Unlock()
+	for _, operation := range operations {
+		yellowPage.commit(operation)
+	}
+}
+
+// Watch calls listener with the operations rebuilding the yellow page, then with every operation made,
+// in order, until the returned function is called. The listener runs with the yellow page locked,
+// so it must not block nor call the yellow page.
+func (yellowPage *YellowPage) Watch(listener func(operations []Operation)) (stop func()) {
+	yellowPage.mutex.Lock()
+	defer yellowPage.mutex.Unlock()
+	if yellowPage.watchers == nil {
+		yellowPage.watchers = make(map[int]func([]Operation))
+	}
+	yellowPage.nextWatcher++
+	id := yellowPage.nextWatcher
+	yellowPage.watchers[id] = listener
+	listener(yellowPage.state())
+	return func() {
+		yellowPage.mutex.Lock()
+		defer yellowPage.mutex.Unlock()
+		delete(yellowPage.watchers, id)
+	}
+}
+
+// Snapshot writes the whole yellow page to the snapshot file and empties the log.
+func (yellowPage *YellowPage) Snapshot() error {
+	yellowPage.mutex.Lock()
+	defer yellowPage.mutex.Unlock()
+	if yellowPage.journal == nil {
+		return errors.New("the yellow page is not persistent")
+	}
+	return yellowPage.snapshot()
+}
+
+// Close flushes and closes the log of a persistent yellow page.
+func (yellowPage *YellowPage) Close() error {
+	yellowPage.mutex.Lock()
+	defer yellowPage.mutex.Unlock()
+	if yellowPage.journal == nil {
+		return nil
+	}
+	journal := yellowPage.journal
+	yellowPage.journal = nil
+	if err := journal.logFile.Sync(); err != nil {
+		journal.logFile.Close()
+		return err
+	}
+	return journal.logFile.Close()
+}
+
+// commit applies the operation, tells the watchers and records it, the mutex must be held.
+func (yellowPage *YellowPage) commit(operation Operation) {
+	var events []Event
+	if len(yellowPage.eventWatchers) > 0 {
+		events = yellowPage.events(operation)
+	}
+	yellowPage.apply(operation)
+	for _, watcher := range yellowPage.watchers {
+		watcher([]Operation{operation})
+	}
+	if len(events) > 0 {
+		for _, watcher := range yellowPage.eventWatchers {
+			watcher(events)
+		}
+	}
+	if yellowPage.journal == nil {
+		return
+	}
+	if err := yellowPage.journal.write(operation); err != nil {
+		log.Printf("Error writing the yellow page log, the operation is not persisted: %v", err)
+		return
+	}
+	if yellowPage.journal.SnapshotInterval > 0 && yellowPage.journal.operations >= yellowPage.journal.SnapshotInterval {
+		if err := yellowPage.snapshot(); err != nil {
+			log.Printf("Error writing the yellow page snapshot, the log is kept: %v", err)
+		}
+	}
+}
+
+// apply changes the registries, the mutex must be held.
+func (yellowPage *YellowPage) apply(operation Operation) {
+	switch operation.Kind {
+	case ContainerRegistered:
+		yellowPage.ContainerRegistry[operation.ID] = operation.Address
+		yellowPage.maxIDContainer = maxID(yellowPage.maxIDContainer, operation.ID)
+	case ContainerDeregistered:
+		delete(yellowPage.leases, operation.Address)
+		for id, containerAdress := range yellowPage.ContainerRegistry {
+			if containerAdress == operation.Address {
+				delete(yellowPage.ContainerRegistry, id)
+			}
+		}
+		for agentID, containerID := range yellowPage.AgentRegistry {
+			if containerID == operation.Address {
+				delete(yellowPage.AgentRegistry, agentID)
+				delete(yellowPage.ServiceRegistry, agentID)
+			}
+		}
+		for name, agentID := range yellowPage.NameRegistry {
+			if _, ok := yellowPage.AgentRegistry[agentID]; !ok {
+				delete(yellowPage.NameRegistry, name)
+			}
+		}
+		for id, subscription := range yellowPage.Subscriptions {
+			if subscription.Address == operation.Address {
+				delete(yellowPage.Subscriptions, id)
+			}
+		}
+	case AgentRegistered:
+		yellowPage.AgentRegistry[operation.ID] = operation.Address
+		yellowPage.maxIDAgent = maxID(yellowPage.maxIDAgent, operation.ID)
+		if operation.Name != "" {
+			yellowPage.NameRegistry[operation.Name] = operation.ID
+		}
+	case AgentDeregistered:
+		delete(yellowPage.AgentRegistry, operation.ID)
+		delete(yellowPage.ServiceRegistry, operation.ID)
+		for name, agentID := range yellowPage.NameRegistry {
+			if agentID == operation.ID {
+				delete(yellowPage.NameRegistry, name)
+			}
+		}
+		for id, subscription := range yellowPage.Subscriptions {
+			if subscription.AgentID == operation.ID {
+				delete(yellowPage.Subscriptions, id)
+			}
+		}
+	case ServiceRegistered:
+		if operation.Description != nil {
+			yellowPage.ServiceRegistry[operation.ID] = *operation.Description
+		}
+	case ServiceDeregistered:
+		delete(yellowPage.ServiceRegistry, operation.ID)
+	case Cleared:
+		yellowPage.leases = nil
+		yellowPage.AgentRegistry = make(map[string]string)
+		yellowPage.ContainerRegistry = make(map[string]string)
+		yellowPage.ServiceRegistry = make(map[string]AgentDescription)
+		yellowPage.NameRegistry = make(map[string]string)
+		yellowPage.Subscriptions = make(map[string]Subscription)
+	case AgentIDReserved:
+		yellowPage.maxIDAgent = maxID(yellowPage.maxIDAgent, operation.ID)
+	case ContainerIDReserved:
+		yellowPage.maxIDContainer = maxID(yellowPage.maxIDContainer, operation.ID)
+	case SubscriptionAdded:
+		if operation.Subscription != nil {
+			yellowPage.Subscriptions[operation.ID] = *operation.Subscription
+		}
+	case SubscriptionRemoved:
+		delete(yellowPage.Subscriptions, operation.ID)
+	}
+}
+
+// state returns the operations turning any yellow page into this one, the mutex must be held.
+func (yellowPage *YellowPage) state() []Operation {
+	operations := []Operation{
+		{Kind: Cleared},
+		{Kind: ContainerIDReserved, ID: strconv.FormatUint(yellowPage.maxIDContainer, 10)},
+		{Kind: AgentIDReserved, ID: strconv.FormatUint(yellowPage.maxIDAgent, 10)},
+	}
+	for id, address := range yellowPage.ContainerRegistry {
+		operations = append(operations, Operation{Kind: ContainerRegistered, ID: id, Address: address})
+	}
+	names := make(map[string]string, len(yellowPage.NameRegistry))
+	for name, agentID := range yellowPage.NameRegistry {
+		names[agentID] = name
+	}
+	for id, containerID := range yellowPage.AgentRegistry {
+		operations = append(operations, Operation{Kind: AgentRegistered, ID: id, Address: containerID, Name: names[id]})
+	}
+	for id, description := range yellowPage.ServiceRegistry {
+		description := description
+		operations = append(operations, Operation{Kind: ServiceRegistered, ID: id, Description: &description})
+	}
+	for id, subscription := range yellowPage.Subscriptions {
+		subscription := subscription
+		operations = append(operations, Operation{Kind: SubscriptionAdded, ID: id, Subscription: &subscription})
+	}
+	return operations
+}
+
+// maxID keeps the IDs handed out increasing, they are never reused.
+func maxID(current uint64, id string) uint64 {
+	if value, err := strconv.ParseUint(id, 10, 64); err == nil && value > current {
+		return value
+	}
+	return current
+}
+
+func (journal *Journal) write(operation Operation) error {
+	line, err := json.Marshal(operation)
+	if err != nil {
+		return err
+	}
+	if _, err := journal.logFile.Write(append(line, '\n')); err != nil {
+		// drop what was written of the record, so that the next ones start on a line of their own
+		if truncateErr := journal.logFile.Truncate(journal.size); truncateErr != nil {
+			return fmt.Errorf("%w (and truncating the log: %v)", err, truncateErr)
+		}
+		return err
+	}
+	journal.size += int64(len(line)) + 1
+	journal.operations++
+	if journal.SyncWrites {
+		return journal.logFile.Sync()
+	}
+	return nil
+}
+
+// snapshot replaces the snapshot file, then empties the log, the mutex must be held.
+// Replaying a log already in the snapshot is harmless since the operations can be applied twice.
+func (yellowPage *YellowPage) snapshot() error {
+	journal := yellowPage.journal
+	data, err := json.Marshal(snapshot{
+		AgentRegistry:     yellowPage.AgentRegistry,
+		ContainerRegistry: yellowPage.ContainerRegistry,
+		ServiceRegistry:   yellowPage.ServiceRegistry,
+		NameRegistry:      yellowPage.NameRegistry,
+		Subscriptions:     yellowPage.Subscriptions,
+		MaxIDAgent:        yellowPage.maxIDAgent,
+		MaxIDContainer:    yellowPage.maxIDContainer,
+	})
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(journal.directory, snapshotFileName)
+	temporary, err := os.CreateTemp(journal.directory, snapshotFileName+".*")
+	if err != nil {
+		return err
+	}
+	if err := temporary.Chmod(0o644); err != nil {
+		temporary.Close()
+		os.Remove(temporary.Name())
+		return err
+	}
+	if _, err := temporary.Write(data); err != nil {
+		temporary.Close()
+		os.Remove(temporary.Name())
+		return err
+	}
+	if err := temporary.Sync(); err != nil {
+		temporary.Close()
+		os.Remove(temporary.Name())
+		return err
+	}
+	if err := temporary.Close(); err != nil {
+		os.Remove(temporary.Name())
+		return err
+	}
+	if err := os.Rename(temporary.Name(), path); err != nil {
+		os.Remove(temporary.Name())
+		return err
+	}
+	if err := journal.logFile.Truncate(0); err != nil {
+		return err
+	}
+	journal.size = 0
+	journal.operations = 0
+	return nil
+}
+
+func (yellowPage *YellowPage) loadSnapshot(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading the yellow page snapshot: %w", err)
+	}
+	var state snapshot
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("error decoding the yellow page snapshot: %w", err)
+	}
+	for id, address := range state.ContainerRegistry {
+		yellowPage.ContainerRegistry[id] = address
+	}
+	for id, containerID := range state.AgentRegistry {
+		yellowPage.AgentRegistry[id] = containerID
+	}
+	for id, description := range state.ServiceRegistry {
+		yellowPage.ServiceRegistry[id] = description
+	}
+	for name, agentID := range state.NameRegistry {
+		yellowPage.NameRegistry[name] = agentID
+	}
+	for id, subscription := range state.Subscriptions {
+		yellowPage.Subscriptions[id] = subscription
+	}
+	yellowPage.maxIDAgent = state.MaxIDAgent
+	yellowPage.maxIDContainer = state.MaxIDContainer
+	return nil
+}
+
+// replayLog applies the operations of the log and returns their number, with the size of the complete records.
+// A last line cut by a crash is ignored.
+func (yellowPage *YellowPage) replayLog(path string) (replayed int, size int64, err error) {
+	logFile, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("error opening the yellow page log: %w", err)
+	}
+	defer logFile.Close()
+	reader := bufio.NewReader(logFile)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				log.Printf("Ignoring the last record of the yellow page log, cut after %d bytes", len(line))
+			}
+			return replayed, size, nil
+		}
+		if err != nil {
+			return replayed, size, fmt.Errorf("error reading the yellow page log: %w", err)
+		}
+		var operation Operation
+		if err := json.Unmarshal(line, &operation); err != nil {
+			return replayed, size, fmt.Errorf("error decoding operation %d of the yellow page log: %w", replayed+1, err)
+		}
+		yellowPage.apply(operation)
+		replayed++
+		size += int64(len(line))
+	}
+}
@@ -0,0 +1,162 @@
+package YellowPage
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// registries is the content of a yellow page, compared after a reload.
+type registries struct {
+	Agents     map[string]string
+	Containers map[string]string
+	Names      map[string]string
+	Services   map[string]AgentDescription
+}
+
+func contents(yellowPage *YellowPage) registries {
+	yellowPage.mutex.Lock()
+	defer yellowPage.mutex.Unlock()
+	copyOf := func(registry map[string]string) map[string]string {
+		copied := make(map[string]string, len(registry))
+		for key, value := range registry {
+			copied[key] = value
+		}
+		return copied
+	}
+	services := make(map[string]AgentDescription, len(yellowPage.ServiceRegistry))
+	for id, description := range yellowPage.ServiceRegistry {
+		services[id] = description
+	}
+	return registries{
+		Agents:     copyOf(yellowPage.AgentRegistry),
+		Containers: copyOf(yellowPage.ContainerRegistry),
+		Names:      copyOf(yellowPage.NameRegistry),
+		Services:   services,
+	}
+}
+
+func openYellowPage(t *testing.T, directory string) *YellowPage {
+	t.Helper()
+	yellowPage, err := OpenYellowPage(directory)
+	if err != nil {
+		t.Fatalf("OpenYellowPage: %v", err)
+	}
+	return yellowPage
+}
+
+func appendToLog(t *testing.T, directory, data string) {
+	t.Helper()
+	logFile, err := os.OpenFile(filepath.Join(directory, logFileName), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logFile.Close()
+	if _, err := logFile.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	populate := func(yellowPage *YellowPage) {
+		yellowPage.RegisterContainer("container-1")
+		yellowPage.RegisterContainer("container-2")
+		pong, _ := yellowPage.RegisterNamedAgent("container-1", "pong")
+		yellowPage.RegisterAgent("container-2")
+		gone := yellowPage.RegisterAgent("container-2")
+		yellowPage.RegisterService(AgentDescription{AgentID: pong, Services: []ServiceDescription{{Type: "game"}}})
+		yellowPage.DeregisterAgent(gone)
+	}
+	tests := []struct {
+		name             string
+		snapshotInterval int
+		tamper           func(t *testing.T, directory string) // between the close and the reload
+		wantErr          bool
+		wantSnapshot     bool
+	}{
+		{name: "log only", snapshotInterval: 0},
+		{name: "snapshot then log", snapshotInterval: 4, wantSnapshot: true},
+		{name: "snapshot on each operation", snapshotInterval: 1, wantSnapshot: true},
+		{name: "torn last record", tamper: func(t *testing.T, directory string) {
+			appendToLog(t, directory, `{"Kind":2,"ID":"9","Addr`)
+		}},
+		{name: "record replayed twice", tamper: func(t *testing.T, directory string) {
+			appendToLog(t, directory, `{"Kind":0,"ID":"1","Address":"container-1"}`+"\n")
+		}},
+		{name: "corrupted record", wantErr: true, tamper: func(t *testing.T, directory string) {
+			appendToLog(t, directory, "not json\n")
+		}},
+		{name: "corrupted snapshot", snapshotInterval: 4, wantSnapshot: true, wantErr: true, tamper: func(t *testing.T, directory string) {
+			if err := os.WriteFile(filepath.Join(directory, snapshotFileName), []byte("{"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			directory := t.TempDir()
+			yellowPage := openYellowPage(t, directory)
+			yellowPage.Journal().SnapshotInterval = test.snapshotInterval
+			populate(yellowPage)
+			want := contents(yellowPage)
+			if err := yellowPage.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(directory, snapshotFileName)); (err == nil) != test.wantSnapshot {
+				t.Errorf("snapshot written: %v, want %v", err == nil, test.wantSnapshot)
+			}
+			if test.tamper != nil {
+				test.tamper(t, directory)
+			}
+
+			reloaded, err := OpenYellowPage(directory)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("reloaded a damaged yellow page")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("OpenYellowPage: %v", err)
+			}
+			if got := contents(reloaded); !reflect.DeepEqual(got, want) {
+				t.Errorf("reloaded %+v, want %+v", got, want)
+			}
+			// the IDs handed out are never given again
+			if id := reloaded.RegisterAgent("container-1"); id != "4" {
+				t.Errorf("next agent ID %s, want 4", id)
+			}
+			want = contents(reloaded)
+			reloaded.Close()
+
+			// the records appended after the reload are replayed too
+			if got := contents(openYellowPage(t, directory)); !reflect.DeepEqual(got, want) {
+				t.Errorf("reloaded again %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestSnapshotEmptiesTheLog(t *testing.T) {
+	directory := t.TempDir()
+	yellowPage := openYellowPage(t, directory)
+	yellowPage.RegisterContainer("container-1")
+	yellowPage.RegisterAgent("container-1")
+	if err := yellowPage.Snapshot(); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if info, err := os.Stat(filepath.Join(directory, logFileName)); err != nil || info.Size() != 0 {
+		t.Fatalf("log after the snapshot: %v, %v", info, err)
+	}
+	yellowPage.RegisterAgent("container-1")
+	want := contents(yellowPage)
+	yellowPage.Close()
+
+	if got := contents(openYellowPage(t, directory)); !reflect.DeepEqual(got, want) {
+		t.Errorf("reloaded %+v, want %+v", got, want)
+	}
+	if err := NewYellowPage().Snapshot(); err == nil {
+		t.Error("a yellow page without journal took a snapshot")
+	}
+}
@@ -0,0 +1,257 @@
+package YellowPage
+
+import "fmt"
+
+// EventKind is the kind of change notified to the subscribers of the yellow page.
+type EventKind int
+
+const (
+	EventContainerRegistered EventKind = iota
+	EventContainerDeregistered
+	EventAgentRegistered
+	EventAgentDeregistered
+	EventServiceRegistered
+	EventServiceModified
+	EventServiceDeregistered
+)
+
+func (kind EventKind) String() string {
+	switch kind {
+	case EventContainerRegistered:
+		return "container-registered"
+	case EventContainerDeregistered:
+		return "container-deregistered"
+	case EventAgentRegistered:
+		return "agent-registered"
+	case EventAgentDeregistered:
+		return "agent-deregistered"
+	case EventServiceRegistered:
+		return "service-registered"
+	case EventServiceModified:
+		return "service-modified"
+	case EventServiceDeregistered:
+		return "service-deregistered"
+	}
+	return "unknown"
+}
+
+// Event is a change of the yellow page. The agents of a container are registered with its address
+// as ContainerID. Description is the new entry of the agent in the directory, or the removed one.
+type Event struct {
+	Kind        EventKind
+	AgentID     string            `json:",omitempty"`
+	AgentName   string            `json:",omitempty"`
+	ContainerID string            `json:",omitempty"`
+	Description *AgentDescription `json:",omitempty"`
+}
+
+// Filter selects the events notified to a subscription, its empty fields select every event.
+type Filter struct {
+	Kinds       []EventKind
+	ContainerID string              // events of this container and of its agents
+	Service     *ServiceDescription // service events of the agents offering a matching service
+}
+
+// Matches tells whether the filter selects the event.
+func (filter Filter) Matches(event Event) bool {
+	if len(filter.Kinds) > 0 {
+		found := false
+		for _, kind := range filter.Kinds {
+			found = found || kind == event.Kind
+		}
+		if !found {
+			return false
+		}
+	}
+	if filter.ContainerID != "" && filter.ContainerID != event.ContainerID {
+		return false
+	}
+	if filter.Service != nil {
+		if event.Description == nil {
+			return false
+		}
+		for _, service := range event.Description.Services {
+			if service.Matches(*filter.Service) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Subscription asks for the events selected by Filter to be sent to the container at Address,
+// and to the agent AgentID if set. It ends with the agent or the container.
+type Subscription struct {
+	ID      string
+	Address string
+	AgentID string `json:",omitempty"`
+	Filter  Filter
+}
+
+// Subscribe records a subscription, replacing the one with the same ID.
+func (yellowPage *YellowPage) Subscribe(subscription Subscription) error {
+	if subscription.ID == "" || subscription.Address == "" {
+		return fmt.Errorf("a subscription needs an ID and an address")
+	}
+	yellowPage.mutex.Lock()
+	defer yellowPage.mutex.Unlock()
+	if subscription.AgentID != "" {
+		if _, ok := yellowPage.AgentRegistry[subscription.AgentID]; !ok {
+			return fmt.Errorf("%w %s", ErrUnknownAgent, subscription.AgentID)
+		}
+	}
+	yellowPage.commit(Operation{Kind: SubscriptionAdded, ID: subscription.ID, Subscription: &subscription})
+	return nil
+}
+
+// Unsubscribe removes a subscription.
+func (yellowPage *YellowPage) Unsubscribe(subscriptionID string) error {
+	yellowPage.mutex.Lock()
+	defer yellowPage.mutex.Unlock()
+	if _, ok := yellowPage.Subscriptions[subscriptionID]; !ok {
+		return fmt.Errorf("unknown subscription %s", subscriptionID)
+	}
+	yellowPage.commit(Operation{Kind: SubscriptionRemoved, ID: subscriptionID})
+	return nil
+}
+
+// Subscription returns the subscription with the ID.
+func (yellowPage *YellowPage) Subscription(subscriptionID string) (Subscription, bool) {
+	yellowPage.mutex.Lock()
+	defer yellowPage.mutex.Unlock()
+	subscription, ok := yellowPage.Subscriptions[subscriptionID]
+	return subscription, ok
+}
+
+// Subscribers returns the subscriptions whose filter selects the event.
+func (yellowPage *YellowPage) Subscribers(event Event) []Subscription {
+	yellowPage.mutex.Lock()
+	defer yellowPage.mutex.Unlock()
+	return yellowPage.subscribers(event)
+}
+
+// subscribers is Subscribers, the mutex must be held.
+func (yellowPage *YellowPage) subscribers(event Event) []Subscription {
+	var subscriptions []Subscription
+	for _, subscription := range yellowPage.Subscriptions {
+		if subscription.Filter.Matches(event) {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	return subscriptions
+}
+
+// WatchEvents calls listener with the events of every operation made until the returned function is called.
+// The listener runs with the yellow page locked, so it must not block nor call the yellow page.
+func (yellowPage *YellowPage) WatchEvents(listener func(events []Event)) (stop func()) {
+	yellowPage.mutex.Lock()
+	defer yellowPage.mutex.Unlock()
+	if yellowPage.eventWatchers == nil {
+		yellowPage.eventWatchers = make(map[int]func([]Event))
+	}
+	yellowPage.nextWatcher++
+	id := yellowPage.nextWatcher
+	yellowPage.eventWatchers[id] = listener
+	return func() {
+		yellowPage.mutex.Lock()
+		defer yellowPage.mutex.Unlock()
+		delete(yellowPage.eventWatchers, id)
+	}
+}
+
+// Notification is an event with a subscription selecting it.
+type Notification struct {
+	Subscription Subscription
+	Event        Event
+}
+
+// WatchNotifications is WatchEvents with the events paired with the subscriptions selecting them
+// when the operation is made, so a subscription added later is not told the changes made before it.
+func (yellowPage *YellowPage) WatchNotifications(listener func(notifications []Notification)) (stop func()) {
+	return yellowPage.WatchEvents(func(events []Event) {
+		var notifications []Notification
+		for _, event := range events {
+			for _, subscription := range yellowPage.subscribers(event) {
+				notifications = append(notifications, Notification{Subscription: subscription, Event: event})
+			}
+		}
+		if len(notifications) > 0 {
+			listener(notifications)
+		}
+	})
+}
+
+// events returns the changes the operation is about to make, the mutex must be held.
+func (yellowPage *YellowPage) events(operation Operation) []Event {
+	var events []Event
+	switch operation.Kind {
+	case ContainerRegistered:
+		if _, ok := yellowPage.ContainerRegistry[operation.ID]; !ok {
+			events = append(events, Event{Kind: EventContainerRegistered, ContainerID: operation.Address})
+		}
+	case ContainerDeregistered:
+		for agentID, containerID := range yellowPage.AgentRegistry {
+			if containerID == operation.Address {
+				events = append(events, yellowPage.agentGone(agentID)...)
+			}
+		}
+		for _, address := range yellowPage.ContainerRegistry {
+			if address == operation.Address {
+				events = append(events, Event{Kind: EventContainerDeregistered, ContainerID: operation.Address})
+				break
+			}
+		}
+	case AgentRegistered:
+		if containerID, ok := yellowPage.AgentRegistry[operation.ID]; !ok || containerID != operation.Address {
+			events = append(events, Event{Kind: EventAgentRegistered, AgentID: operation.ID, AgentName: operation.Name, ContainerID: operation.Address})
+		}
+	case AgentDeregistered:
+		if _, ok := yellowPage.AgentRegistry[operation.ID]; ok {
+			events = append(events, yellowPage.agentGone(operation.ID)...)
+		}
+	case ServiceRegistered:
+		kind := EventServiceRegistered
+		if _, ok := yellowPage.ServiceRegistry[operation.ID]; ok {
+			kind = EventServiceModified
+		}
+		events = append(events, Event{
+			Kind:        kind,
+			AgentID:     operation.ID,
+			AgentName:   yellowPage.agentName(operation.ID),
+			ContainerID: yellowPage.AgentRegistry[operation.ID],
+			Description: operation.Description,
+		})
+	case ServiceDeregistered:
+		if description, ok := yellowPage.ServiceRegistry[operation.ID]; ok {
+			events = append(events, Event{
+				Kind:        EventServiceDeregistered,
+				AgentID:     operation.ID,
+				AgentName:   yellowPage.agentName(operation.ID),
+				ContainerID: yellowPage.AgentRegistry[operation.ID],
+				Description: &description,
+			})
+		}
+	}
+	return events
+}
+
+// agentGone returns the events of an agent removed with its services, the mutex must be held.
+func (yellowPage *YellowPage) agentGone(agentID string) []Event {
+	name := yellowPage.agentName(agentID)
+	containerID := yellowPage.AgentRegistry[agentID]
+	var events []Event
+	if description, ok := yellowPage.ServiceRegistry[agentID]; ok {
+		events = append(events, Event{Kind: EventServiceDeregistered, AgentID: agentID, AgentName: name, ContainerID: containerID, Description: &description})
+	}
+	return append(events, Event{Kind: EventAgentDeregistered, AgentID: agentID, AgentName: name, ContainerID: containerID})
+}
+
+func (yellowPage *YellowPage) agentName(agentID string) string {
+	for name, id := range yellowPage.NameRegistry {
+		if id == agentID {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,128 @@
+package YellowPage
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFilterMatches(t *testing.T) {
+	worker := &AgentDescription{Services: []ServiceDescription{{Type: "worker"}}}
+	tests := []struct {
+		name   string
+		filter Filter
+		event  Event
+		want   bool
+	}{
+		{"empty filter", Filter{}, Event{Kind: EventAgentRegistered}, true},
+		{"kind", Filter{Kinds: []EventKind{EventAgentRegistered, EventAgentDeregistered}}, Event{Kind: EventAgentDeregistered}, true},
+		{"other kind", Filter{Kinds: []EventKind{EventAgentRegistered}}, Event{Kind: EventContainerRegistered}, false},
+		{"container", Filter{ContainerID: "container-1"}, Event{Kind: EventAgentRegistered, ContainerID: "container-1"}, true},
+		{"other container", Filter{ContainerID: "container-1"}, Event{Kind: EventAgentRegistered, ContainerID: "container-2"}, false},
+		{"service", Filter{Service: &ServiceDescription{Type: "worker"}}, Event{Kind: EventServiceRegistered, Description: worker}, true},
+		{"other service", Filter{Service: &ServiceDescription{Type: "game"}}, Event{Kind: EventServiceRegistered, Description: worker}, false},
+		{"service without description", Filter{Service: &ServiceDescription{Type: "worker"}}, Event{Kind: EventAgentRegistered}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.filter.Matches(test.event); got != test.want {
+				t.Errorf("Matches() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestWatchEvents(t *testing.T) {
+	yellowPage := NewYellowPage()
+	var kinds []string
+	stop := yellowPage.WatchEvents(func(events []Event) {
+		for _, event := range events {
+			kinds = append(kinds, event.Kind.String())
+		}
+	})
+	yellowPage.RegisterContainer("container-1")
+	yellowPage.RegisterContainer("container-1") // already registered, no event
+	agentID, err := yellowPage.RegisterNamedAgent("container-1", "pong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	description := AgentDescription{AgentID: agentID, Services: []ServiceDescription{{Type: "game"}}}
+	if err := yellowPage.RegisterService(description); err != nil {
+		t.Fatal(err)
+	}
+	if err := yellowPage.ModifyService(description); err != nil {
+		t.Fatal(err)
+	}
+	yellowPage.DeregisterContainer("container-1")
+	stop()
+	yellowPage.RegisterContainer("container-2")
+
+	want := "container-registered agent-registered service-registered service-modified " +
+		"service-deregistered agent-deregistered container-deregistered"
+	if got := strings.Join(kinds, " "); got != want {
+		t.Errorf("events %q, want %q", got, want)
+	}
+}
+
+func TestWatchNotifications(t *testing.T) {
+	yellowPage := NewYellowPage()
+	var got []string
+	stop := yellowPage.WatchNotifications(func(notifications []Notification) {
+		for _, notification := range notifications {
+			got = append(got, notification.Subscription.ID+" "+notification.Event.Kind.String())
+		}
+	})
+	defer stop()
+	yellowPage.RegisterContainer("container-1")
+	if err := yellowPage.Subscribe(Subscription{ID: "every", Address: "container-1"}); err != nil {
+		t.Fatal(err)
+	}
+	yellowPage.RegisterContainer("container-2")
+
+	// the registration of container-1 came before the subscription
+	if want := "every container-registered"; strings.Join(got, ", ") != want {
+		t.Errorf("notifications %q, want %q", got, want)
+	}
+}
+
+func TestSubscriptions(t *testing.T) {
+	yellowPage := NewYellowPage()
+	yellowPage.RegisterContainer("container-1")
+	yellowPage.RegisterContainer("container-2")
+	agentID := yellowPage.RegisterAgent("container-1")
+	subscriptions := []Subscription{
+		{ID: "container", Address: "container-2", Filter: Filter{Kinds: []EventKind{EventAgentRegistered}}},
+		{ID: "agent", Address: "container-1", AgentID: agentID},
+	}
+	for _, subscription := range subscriptions {
+		if err := yellowPage.Subscribe(subscription); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := yellowPage.Subscribe(Subscription{ID: "unknown", Address: "container-1", AgentID: "404"}); err == nil {
+		t.Error("subscribed for an unknown agent")
+	}
+
+	var ids []string
+	for _, subscription := range yellowPage.Subscribers(Event{Kind: EventAgentRegistered}) {
+		ids = append(ids, subscription.ID)
+	}
+	if len(ids) != 2 {
+		t.Errorf("subscribers %v, want container and agent", ids)
+	}
+	if subscribers := yellowPage.Subscribers(Event{Kind: EventContainerRegistered}); len(subscribers) != 1 || subscribers[0].ID != "agent" {
+		t.Errorf("subscribers %v, want agent", subscribers)
+	}
+
+	// a subscription ends with its agent or its container
+	yellowPage.DeregisterAgent(agentID)
+	if _, ok := yellowPage.Subscription("agent"); ok {
+		t.Error("the subscription of a deregistered agent is kept")
+	}
+	yellowPage.DeregisterContainer("container-2")
+	if _, ok := yellowPage.Subscription("container"); ok {
+		t.Error("the subscription of a deregistered container is kept")
+	}
+	if err := yellowPage.Unsubscribe("container"); err == nil {
+		t.Error("unsubscribed twice")
+	}
+}
@@ -1,13 +1,29 @@
 package YellowPage
 
 import (
+	"errors"
+	"fmt"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 )
 
+// ErrUnknownAgent is returned when resolving an agent that is not registered, or not anymore.
+var ErrUnknownAgent = errors.New("unknown agent")
+
+// ErrNameTaken is returned when registering an agent under the name of another living agent.
+var ErrNameTaken = errors.New("agent name already taken")
+
+// ErrInvalidName is returned for the agent names that could be mistaken for an ID or an AID.
+var ErrInvalidName = errors.New("invalid agent name")
+
 type YellowPage struct {
 	AgentRegistry     map[string]string
 	ContainerRegistry map[string]string
+	ServiceRegistry   map[string]AgentDescription // Directory Facilitator entries by agent ID, see df.go
+	NameRegistry      map[string]string           // agent IDs by agent name
+	Subscriptions     map[string]Subscription     // by ID, see subscriptions.go
 
 	// eviter un maximum les mutex, donc utiliser un/des channels dans le networkService avec un select pour éviter la concurrence
 	mutex sync.Mutex
@@ -15,49 +31,161 @@ type YellowPage struct {
 	// enlever la queue et utiliser un int64 directementZ
 	maxIDAgent     uint64
 	maxIDContainer uint64
+
+	// journal records the operations when the yellow page is persistent, see persistence.go
+	journal *Journal
+
+	// leases are the expiry times of the containers by address, see leases.go
+	leases map[string]time.Time
+
+	// watchers are told every operation, see Watch
+	watchers      map[int]func([]Operation)
+	eventWatchers map[int]func([]Event)
+	nextWatcher   int
 }
 
 func NewYellowPage() *YellowPage {
 	return &YellowPage{
 		AgentRegistry:     make(map[string]string),
 		ContainerRegistry: make(map[string]string),
+		ServiceRegistry:   make(map[string]AgentDescription),
+		NameRegistry:      make(map[string]string),
+		Subscriptions:     make(map[string]Subscription),
 		mutex:             sync.Mutex{},
 		maxIDAgent:        0,
 		maxIDContainer:    0,
 	}
 }
 
-func (yellowPage *YellowPage) getAvailableID() uint64 {
+// adress = ip:port
+// A container registering again with the same address keeps its ID.
+func (yellowPage *YellowPage) RegisterContainer(adress string) string {
 	yellowPage.mutex.Lock()
 	defer yellowPage.mutex.Unlock()
-	yellowPage.maxIDAgent++
-	return yellowPage.maxIDAgent
+	for id, containerAdress := range yellowPage.ContainerRegistry {
+		if containerAdress == adress {
+			return id
+		}
+	}
+	id := strconv.FormatUint(yellowPage.maxIDContainer+1, 10)
+	yellowPage.commit(Operation{Kind: ContainerRegistered, ID: id, Address: adress})
+	return id
 }
 
-// adress = ip:port
-func (yellowPage *YellowPage) RegisterContainer(adress string) string {
+// IsContainerRegistered tells whether a container is registered with the address.
+func (yellowPage *YellowPage) IsContainerRegistered(address string) bool {
 	yellowPage.mutex.Lock()
 	defer yellowPage.mutex.Unlock()
-	yellowPage.maxIDContainer++
-	id := strconv.FormatUint(yellowPage.maxIDContainer, 10)
-	yellowPage.ContainerRegistry[id] = adress
-	return id
+	return yellowPage.containerRegistered(address)
 }
+
+// ContainerCount returns the number of registered containers, the main container included.
+func (yellowPage *YellowPage) ContainerCount() int {
+	yellowPage.mutex.Lock()
+	defer yellowPage.mutex.Unlock()
+	return len(yellowPage.ContainerRegistry)
+}
+
+// AgentCount returns the number of agents registered in the container with the address.
+func (yellowPage *YellowPage) AgentCount(address string) int {
+	yellowPage.mutex.Lock()
+	defer yellowPage.mutex.Unlock()
+	count := 0
+	for _, containerAddress := range yellowPage.AgentRegistry {
+		if containerAddress == address {
+			count++
+		}
+	}
+	return count
+}
+
 func (yellowPage *YellowPage) RegisterAgent(containerID string) string {
-	id := strconv.FormatUint(yellowPage.getAvailableID(), 10)
-	yellowPage.AgentRegistry[id] = containerID
+	id, _ := yellowPage.RegisterNamedAgent(containerID, "")
 	return id
 }
 
+// RegisterNamedAgent registers an agent and reserves its name until it is deregistered.
+// An empty name registers an agent known by its ID only.
+func (yellowPage *YellowPage) RegisterNamedAgent(containerID, name string) (string, error) {
+	if name != "" {
+		if err := ValidateAgentName(name); err != nil {
+			return "", err
+		}
+	}
+	yellowPage.mutex.Lock()
+	defer yellowPage.mutex.Unlock()
+	if _, taken := yellowPage.NameRegistry[name]; taken {
+		return "", fmt.Errorf("%w: %s", ErrNameTaken, name)
+	}
+	id := strconv.FormatUint(yellowPage.maxIDAgent+1, 10)
+	yellowPage.commit(Operation{Kind: AgentRegistered, ID: id, Address: containerID, Name: name})
+	return id, nil
+}
+
+// ValidateAgentName rejects the empty names, the names made of digits only, kept for the IDs,
+// and the names containing '@', which separates the name from the platform in an AID.
+func ValidateAgentName(name string) error {
+	if name == "" || strings.Contains(name, "@") {
+		return fmt.Errorf("%w %q", ErrInvalidName, name)
+	}
+	if _, err := strconv.ParseUint(name, 10, 64); err == nil {
+		return fmt.Errorf("%w %q: only the IDs are numbers", ErrInvalidName, name)
+	}
+	return nil
+}
+
+func (yellowPage *YellowPage) DeregisterAgent(agentID string) bool {
+	yellowPage.mutex.Lock()
+	defer yellowPage.mutex.Unlock()
+	if _, ok := yellowPage.AgentRegistry[agentID]; !ok {
+		return false
+	}
+	yellowPage.commit(Operation{Kind: AgentDeregistered, ID: agentID})
+	return true
+}
+
+// DeregisterContainer removes the container registered with the address and purges its agents.
+// It returns the IDs of the agents removed and false if the container was not registered.
+func (yellowPage *YellowPage) DeregisterContainer(adress string) ([]string, bool) {
+	yellowPage.mutex.Lock()
+	defer yellowPage.mutex.Unlock()
+	found := false
+	for _, containerAdress := range yellowPage.ContainerRegistry {
+		if containerAdress == adress {
+			found = true
+		}
+	}
+	var agentIDs []string
+	for agentID, containerID := range yellowPage.AgentRegistry {
+		if containerID == adress {
+			agentIDs = append(agentIDs, agentID)
+		}
+	}
+	if !found && len(agentIDs) == 0 {
+		return nil, false
+	}
+	yellowPage.commit(Operation{Kind: ContainerDeregistered, Address: adress})
+	return agentIDs, true
+}
+
 func (yellowPage *YellowPage) ResolveAgentAddress(agentID string) (string, error) {
+	yellowPage.mutex.Lock()
+	defer yellowPage.mutex.Unlock()
 	containerID, ok := yellowPage.AgentRegistry[agentID]
 	if !ok {
-		return "", nil
+		return "", fmt.Errorf("%w %s", ErrUnknownAgent, agentID)
 	}
+	// containers register their agents with their address as container ID
 	return containerID, nil
-	adress, ok := yellowPage.ContainerRegistry[containerID]
+}
+
+// ResolveAgentName returns the ID of the agent registered under name, and the address of its container.
+func (yellowPage *YellowPage) ResolveAgentName(name string) (string, string, error) {
+	yellowPage.mutex.Lock()
+	defer yellowPage.mutex.Unlock()
+	agentID, ok := yellowPage.NameRegistry[name]
 	if !ok {
-		return "", nil
+		return "", "", fmt.Errorf("%w %s", ErrUnknownAgent, name)
 	}
-	return adress, nil
+	return agentID, yellowPage.AgentRegistry[agentID], nil
 }
@@ -19,32 +19,33 @@ func (b *BasicBehaviour1) Act(agent *Agent.Agent, params ...interface{}) {
 		agent.SendMail(Messages.Message{
 			Type:           Messages.InterAgentAsyncMessage,
 			Sender:         fmt.Sprintf("%d", agent.ID),
-			ContentType:    Messages.InterAgentAsyncMessageContent,
-			Content:        "Start !",
+			ContentType:    Messages.TextContent,
+			Content:        Messages.Text("Start !").Content,
 			ExpectResponse: false,
 		}, 2)
 	}
 	b.tick++
 }
 func (b *BasicBehaviour1) HandleMailboxMessage(agent *Agent.Agent, msg Messages.Message) {
+	text, _ := msg.Text()
 	if b.tick < 10 {
 		agent.SendMail(Messages.Message{
 			Type:           Messages.InterAgentAsyncMessage,
 			Sender:         fmt.Sprintf("%d", agent.ID),
-			ContentType:    Messages.InterAgentAsyncMessageContent,
-			Content:        "Pong",
+			ContentType:    Messages.TextContent,
+			Content:        Messages.Text("Pong").Content,
 			ExpectResponse: false,
 		}, 2)
 	} else {
 		agent.SendMail(Messages.Message{
 			Type:           Messages.InterAgentAsyncMessage,
 			Sender:         fmt.Sprintf("%d", agent.ID),
-			ContentType:    Messages.InterAgentAsyncMessageContent,
-			Content:        "Stop !",
+			ContentType:    Messages.TextContent,
+			Content:        Messages.Text("Stop !").Content,
 			ExpectResponse: false,
 		}, 2)
 	}
-	fmt.Printf("AGENT %s: Message recu: %s\n", fmt.Sprintf("%d", agent.ID), msg.Content)
+	fmt.Printf("AGENT %s: Message recu: %s\n", fmt.Sprintf("%d", agent.ID), text)
 	b.tick++
 }
 func (b *BasicBehaviour1) HandleSyncCommunication(agent *Agent.Agent, msg Messages.Message) {}
@@ -56,26 +57,31 @@ func (b *BasicBehaviour2) Decide(agent *Agent.Agent, params ...interface{})   {}
 func (b *BasicBehaviour2) Act(agent *Agent.Agent, params ...interface{}) {
 }
 func (b *BasicBehaviour2) HandleMailboxMessage(agent *Agent.Agent, msg Messages.Message) {
-	if msg.Content != "Stop !" {
+	text, _ := msg.Text()
+	if text != "Stop !" {
 		agent.SendMail(Messages.Message{
 			Type:           Messages.InterAgentAsyncMessage,
 			Sender:         fmt.Sprintf("%d", agent.ID),
-			ContentType:    Messages.InterAgentAsyncMessageContent,
-			Content:        "Ping",
+			ContentType:    Messages.TextContent,
+			Content:        Messages.Text("Ping").Content,
 			ExpectResponse: false,
 		}, 1)
 	}
-	fmt.Printf("AGENT %s: Message recu: %s\n", fmt.Sprintf("%d", agent.ID), msg.Content)
+	fmt.Printf("AGENT %s: Message recu: %s\n", fmt.Sprintf("%d", agent.ID), text)
 
 }
 func (b *BasicBehaviour2) HandleSyncCommunication(agent *Agent.Agent, msg Messages.Message) {}
 
 func main() {
 
-	mainContainer := Container.NewMainContainer("localhost:8080")
+	mainContainer, err := Container.NewMainContainer("localhost:8080")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 
-	agent1 := mainContainer.AddAgent()
-	agent2 := mainContainer.AddAgent()
+	agent1, _ := mainContainer.AddAgent("")
+	agent2, _ := mainContainer.AddAgent("")
 
 	agent1Ref := mainContainer.GetAgent(agent1)
 	agent2Ref := mainContainer.GetAgent(agent2)
@@ -95,4 +101,4 @@ func main() {
 		time.Sleep(1 * time.Second)
 	}
 
-}
\ No newline at end of file
+}
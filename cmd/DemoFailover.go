@@ -0,0 +1,122 @@
+package main
+
+import (
+	"FrameworkMultiAgents/Agent"
+	"FrameworkMultiAgents/Behaviours"
+	"FrameworkMultiAgents/Container"
+	"FrameworkMultiAgents/Messages"
+	"FrameworkMultiAgents/YellowPage"
+	"flag"
+	"fmt"
+	"time"
+)
+
+// The platform has a primary main container on 8080 and a standby on 8081.
+var mainAddresses = []string{"localhost:8080", "localhost:8081"}
+
+// PongBehaviour publishes a ping-pong service and answers the pings.
+type PongBehaviour struct {
+	Behaviours.Base
+	registered bool
+}
+
+func (b *PongBehaviour) Act(agent *Agent.Agent, params ...interface{}) {
+	if b.registered {
+		return
+	}
+	if err := agent.RegisterServices(YellowPage.ServiceDescription{Type: "ping-pong"}); err != nil {
+		fmt.Println(err)
+		return
+	}
+	b.registered = true
+	fmt.Printf("AGENT %s: service ping-pong publie\n", agent.Name())
+}
+
+func (b *PongBehaviour) HandleMailboxMessage(agent *Agent.Agent, msg Messages.Message) {
+	text, _ := msg.Text()
+	fmt.Printf("AGENT %s: %s recu de %s\n", agent.Name(), text, msg.Sender)
+	reply := msg.CreateReply()
+	reply.ContentType = Messages.TextContent
+	reply.Content = Messages.Text("Pong").Content
+	if err := agent.Send(reply); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// PingBehaviour looks for the ping-pong service every tick, through the main container, and pings it.
+type PingBehaviour struct {
+	Behaviours.Base
+	tick int
+}
+
+func (b *PingBehaviour) Act(agent *Agent.Agent, params ...interface{}) {
+	b.tick++
+	agents, err := agent.SearchServices(YellowPage.ServiceDescription{Type: "ping-pong"}, 1)
+	if err != nil {
+		fmt.Printf("AGENT %s: recherche impossible : %v\n", agent.Name(), err)
+		return
+	}
+	if len(agents) == 0 {
+		fmt.Printf("AGENT %s: aucun service ping-pong\n", agent.Name())
+		return
+	}
+	ping := Messages.Text(fmt.Sprintf("Ping %d", b.tick))
+	ping.Receivers = []string{agents[0].AgentID}
+	if err := agent.Send(ping); err != nil {
+		fmt.Println(err)
+	}
+}
+
+func (b *PingBehaviour) HandleMailboxMessage(agent *Agent.Agent, msg Messages.Message) {
+	text, _ := msg.Text()
+	fmt.Printf("AGENT %s: %s recu de %s\n", agent.Name(), text, msg.Sender)
+}
+
+func main() {
+
+	role := flag.String("role", "main", "main, standby, pong or ping")
+	port := flag.String("port", "8080", "Set the port number for this container")
+
+	flag.Parse()
+
+	address := "localhost:" + *port
+	switch *role {
+	case "main":
+		fmt.Printf("Starting MainContainer on port %s...\n", *port)
+		if _, err := Container.NewMainContainer(address); err != nil {
+			fmt.Println(err)
+			return
+		}
+	case "standby":
+		fmt.Printf("Starting standby MainContainer on port %s...\n", *port)
+		if _, err := Container.NewStandbyContainer(address, mainAddresses); err != nil {
+			fmt.Println(err)
+			return
+		}
+	default:
+		fmt.Printf("Starting Container on port %s...\n", *port)
+		container, err := Container.NewContainer(mainAddresses[0], address, Container.WithMainAddresses(mainAddresses[1:]...))
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		agentID, err := container.AddAgent(*role)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		agent := container.GetAgent(agentID)
+		if *role == "pong" {
+			agent.RegisterBehaviour("PongBehaviour", &PongBehaviour{})
+			agent.SetBehaviour("PongBehaviour")
+		} else {
+			agent.RegisterBehaviour("PingBehaviour", &PingBehaviour{})
+			agent.SetBehaviour("PingBehaviour")
+		}
+		container.Start()
+	}
+	for {
+		time.Sleep(1 * time.Second)
+	}
+
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"FrameworkMultiAgents/Agent"
+	"FrameworkMultiAgents/Container"
+	"FrameworkMultiAgents/Messages"
+	"FrameworkMultiAgents/NetworkService"
+	"fmt"
+	"time"
+)
+
+// PingBehaviour sends a ping to the agent named pong, then answers each pong with a ping.
+type PingBehaviour struct {
+	started bool
+	count   int
+}
+
+func (b *PingBehaviour) Perceive(agent *Agent.Agent, params ...interface{}) {}
+func (b *PingBehaviour) Decide(agent *Agent.Agent, params ...interface{})   {}
+func (b *PingBehaviour) Act(agent *Agent.Agent, params ...interface{}) {
+	if !b.started {
+		b.started = true
+		message := Messages.Text("Ping 0")
+		message.Receivers = []string{"pong"}
+		if err := agent.Send(message); err != nil {
+			fmt.Printf("AGENT %s: %v\n", agent.Name(), err)
+		}
+	}
+}
+func (b *PingBehaviour) HandleMailboxMessage(agent *Agent.Agent, msg Messages.Message) {
+	text, _ := msg.Text()
+	fmt.Printf("AGENT %s: Message recu de %s: %s\n", agent.Name(), msg.Sender, text)
+	b.count++
+	if b.count < 5 {
+		reply := msg.CreateReply()
+		reply.ContentType = Messages.TextContent
+		reply.Content = Messages.Text(fmt.Sprintf("Ping %d", b.count)).Content
+		agent.Send(reply)
+	}
+}
+func (b *PingBehaviour) HandleSyncCommunication(agent *Agent.Agent, msg Messages.Message) {}
+
+// PongBehaviour answers each ping with a pong.
+type PongBehaviour struct{}
+
+func (b *PongBehaviour) Perceive(agent *Agent.Agent, params ...interface{}) {}
+func (b *PongBehaviour) Decide(agent *Agent.Agent, params ...interface{})   {}
+func (b *PongBehaviour) Act(agent *Agent.Agent, params ...interface{})      {}
+func (b *PongBehaviour) HandleMailboxMessage(agent *Agent.Agent, msg Messages.Message) {
+	text, _ := msg.Text()
+	fmt.Printf("AGENT %s: Message recu de %s: %s\n", agent.Name(), msg.Sender, text)
+	reply := msg.CreateReply()
+	reply.ContentType = Messages.TextContent
+	reply.Content = Messages.Text("Pong").Content
+	agent.Send(reply)
+}
+func (b *PongBehaviour) HandleSyncCommunication(agent *Agent.Agent, msg Messages.Message) {}
+
+// The main container and two containers run in this program, connected without opening ports.
+func main() {
+	transport := NetworkService.NewMemoryTransport()
+
+	mainContainer, err := Container.NewMainContainer("main", Container.WithTransport(transport))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	pingContainer, err := Container.NewContainer("main", "container-1", Container.WithTransport(transport))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	pongContainer, err := Container.NewContainer("main", "container-2", Container.WithTransport(transport))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	pong, err := pongContainer.AddAgent("pong")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	pongContainer.GetAgent(pong).RegisterBehaviour("PongBehaviour", &PongBehaviour{})
+	pongContainer.GetAgent(pong).SetBehaviour("PongBehaviour")
+
+	ping, err := pingContainer.AddAgent("ping")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	pingContainer.GetAgent(ping).RegisterBehaviour("PingBehaviour", &PingBehaviour{})
+	pingContainer.GetAgent(ping).SetBehaviour("PingBehaviour")
+
+	mainContainer.Start()
+	pongContainer.Start()
+	pingContainer.Start()
+
+	time.Sleep(2 * time.Second)
+	pingContainer.Stop()
+	pongContainer.Stop()
+	mainContainer.Stop()
+}
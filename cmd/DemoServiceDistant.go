@@ -0,0 +1,145 @@
+package main
+
+import (
+	"FrameworkMultiAgents/Agent"
+	"FrameworkMultiAgents/Behaviours"
+	"FrameworkMultiAgents/Container"
+	"FrameworkMultiAgents/Messages"
+	"FrameworkMultiAgents/YellowPage"
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// SellerBehaviour publishes a book-selling service and answers the calls for proposals with its price.
+type SellerBehaviour struct {
+	Behaviours.Base
+	price      int
+	registered bool
+}
+
+func (b *SellerBehaviour) Act(agent *Agent.Agent, params ...interface{}) {
+	if b.registered {
+		return
+	}
+	err := agent.RegisterServices(YellowPage.ServiceDescription{
+		Type:       "book-selling",
+		Name:       fmt.Sprintf("seller-%d", agent.ID),
+		Ontologies: []string{"books"},
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	b.registered = true
+	fmt.Printf("AGENT %s: vend des livres a %d\n", agent.Name(), b.price)
+}
+
+func (b *SellerBehaviour) HandleMailboxMessage(agent *Agent.Agent, msg Messages.Message) {
+	if msg.Performative != Messages.CFP {
+		return
+	}
+	title, _ := msg.Text()
+	reply := msg.CreateReply()
+	reply.Performative = Messages.Propose
+	reply.ContentType = Messages.TextContent
+	reply.Content = Messages.Text(strconv.Itoa(b.price)).Content
+	fmt.Printf("AGENT %s: CFP recu pour %s\n", agent.Name(), title)
+	if err := agent.Send(reply); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// BuyerBehaviour looks for the sellers in the directory and sends them a call for proposals.
+type BuyerBehaviour struct {
+	Behaviours.Base
+	sent bool
+}
+
+func (b *BuyerBehaviour) Act(agent *Agent.Agent, params ...interface{}) {
+	if b.sent {
+		return
+	}
+	sellers, err := agent.SearchServices(YellowPage.ServiceDescription{Type: "book-selling"}, 0)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if len(sellers) == 0 {
+		fmt.Printf("AGENT %s: aucun vendeur pour l'instant\n", agent.Name())
+		return
+	}
+	cfp := Messages.Text("Le Petit Prince")
+	cfp.Performative = Messages.CFP
+	cfp.ConversationID = "book-trade"
+	for _, seller := range sellers {
+		cfp.Receivers = append(cfp.Receivers, seller.AgentID)
+	}
+	fmt.Printf("AGENT %s: CFP envoye a %v\n", agent.Name(), cfp.Receivers)
+	if err := agent.Send(cfp); err != nil {
+		fmt.Println(err)
+		return
+	}
+	b.sent = true
+}
+
+func (b *BuyerBehaviour) HandleMailboxMessage(agent *Agent.Agent, msg Messages.Message) {
+	if msg.Performative == Messages.Propose {
+		price, _ := msg.Text()
+		fmt.Printf("AGENT %s: proposition de %s a %s\n", agent.Name(), msg.Sender, price)
+	}
+}
+
+func main() {
+
+	isMainContainer := flag.Bool("main", false, "Set to true if this process should be the main container")
+	port := flag.String("port", "8080", "Set the port number for this container")
+
+	flag.Parse()
+
+	if *isMainContainer {
+		fmt.Printf("Starting MainContainer on port %s...\n", *port)
+		mainContainer, err := Container.NewMainContainer("localhost:" + *port)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		for i, price := range []int{12, 9} {
+			sellerID, err := mainContainer.AddAgent(fmt.Sprintf("seller-%d", i+1))
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			seller := mainContainer.GetAgent(sellerID)
+			seller.RegisterBehaviour("SellerBehaviour", &SellerBehaviour{price: price})
+			seller.SetBehaviour("SellerBehaviour")
+			fmt.Printf("Seller %d: %v\n", i+1, seller.AID)
+		}
+		mainContainer.Start()
+		for {
+			time.Sleep(1 * time.Second)
+		}
+	} else {
+		fmt.Printf("Starting Container on port %s...\n", *port)
+		container, err := Container.NewContainer("localhost:8080", "localhost:"+*port)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		buyerID, err := container.AddAgent("buyer")
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		buyer := container.GetAgent(buyerID)
+		buyer.RegisterBehaviour("BuyerBehaviour", &BuyerBehaviour{})
+		buyer.SetBehaviour("BuyerBehaviour")
+		fmt.Printf("Buyer: %v\n", buyer.AID)
+		container.Start()
+		for {
+			time.Sleep(1 * time.Second)
+		}
+	}
+
+}
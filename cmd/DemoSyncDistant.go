@@ -4,7 +4,6 @@ import (
 	"FrameworkMultiAgents/Agent"
 	"FrameworkMultiAgents/Container"
 	"FrameworkMultiAgents/Messages"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"time"
@@ -28,13 +27,13 @@ func (b *BasicBehaviour1) Act(agent *Agent.Agent, params ...interface{}) {
 			ReceiverID: 1,
 			Content:    "Let's start !",
 		}
-		payloadStr, _ := json.Marshal(payload)
+		payloadStr, _ := Messages.Encode(Messages.InterAgentSyncMessageContent, payload)
 
 		agent.SendSyncMessage(Messages.Message{
 			Type:        Messages.InterAgentSyncMessage,
 			Sender:      fmt.Sprintf("%d", agent.ID),
 			ContentType: Messages.InterAgentSyncMessageContent,
-			Content:     string(payloadStr),
+			Content:     payloadStr,
 		})
 	}
 	fmt.Printf("AGENT %d: Tick %d\n", agent.ID, b.tick)
@@ -45,16 +44,16 @@ func (b *BasicBehaviour1) HandleMailboxMessage(agent *Agent.Agent, msg Messages.
 		agent.SendMail(Messages.Message{
 			Type:           Messages.InterAgentAsyncMessage,
 			Sender:         fmt.Sprintf("%d", agent.ID),
-			ContentType:    Messages.InterAgentAsyncMessageContent,
-			Content:        "Pong",
+			ContentType:    Messages.TextContent,
+			Content:        Messages.Text("Pong").Content,
 			ExpectResponse: false,
 		}, 1)
 	} else {
 		agent.SendMail(Messages.Message{
 			Type:           Messages.InterAgentAsyncMessage,
 			Sender:         fmt.Sprintf("%d", agent.ID),
-			ContentType:    Messages.InterAgentAsyncMessageContent,
-			Content:        "Stop !",
+			ContentType:    Messages.TextContent,
+			Content:        Messages.Text("Stop !").Content,
 			ExpectResponse: false,
 		}, 1)
 	}
@@ -62,8 +61,7 @@ func (b *BasicBehaviour1) HandleMailboxMessage(agent *Agent.Agent, msg Messages.
 	b.tick++
 }
 func (b *BasicBehaviour1) HandleSyncCommunication(agent *Agent.Agent, msg Messages.Message) {
-	var payload Messages.InterAgentSyncMessagePayload
-	err := json.Unmarshal([]byte(msg.Content), &payload)
+	payload, err := Messages.Decode[Messages.InterAgentSyncMessagePayload](msg)
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -75,13 +73,13 @@ func (b *BasicBehaviour1) HandleSyncCommunication(agent *Agent.Agent, msg Messag
 			ReceiverID: 1,
 			Content:    "Pong",
 		}
-		payloadStr, _ := json.Marshal(payload)
+		payloadStr, _ := Messages.Encode(Messages.InterAgentSyncMessageContent, payload)
 
 		agent.SendSyncMessage(Messages.Message{
 			Type:        Messages.InterAgentSyncMessage,
 			Sender:      fmt.Sprintf("%d", agent.ID),
 			ContentType: Messages.InterAgentSyncMessageContent,
-			Content:     string(payloadStr),
+			Content:     payloadStr,
 		})
 
 	} else if b.tick >= 10 {
@@ -89,13 +87,13 @@ func (b *BasicBehaviour1) HandleSyncCommunication(agent *Agent.Agent, msg Messag
 			ReceiverID: 1,
 			Content:    "Stop !",
 		}
-		payloadStr, _ := json.Marshal(payload)
+		payloadStr, _ := Messages.Encode(Messages.InterAgentSyncMessageContent, payload)
 
 		agent.SendSyncMessage(Messages.Message{
 			Type:        Messages.InterAgentSyncMessage,
 			Sender:      fmt.Sprintf("%d", agent.ID),
 			ContentType: Messages.InterAgentSyncMessageContent,
-			Content:     string(payloadStr),
+			Content:     payloadStr,
 		})
 	} else {
 		agent.StopSynchronousCommunication()
@@ -111,12 +109,13 @@ func (b *BasicBehaviour2) Decide(agent *Agent.Agent, params ...interface{})   {}
 func (b *BasicBehaviour2) Act(agent *Agent.Agent, params ...interface{}) {
 }
 func (b *BasicBehaviour2) HandleMailboxMessage(agent *Agent.Agent, msg Messages.Message) {
-	if msg.Content != "Stop !" {
+	text, _ := msg.Text()
+	if text != "Stop !" {
 		agent.SendMail(Messages.Message{
 			Type:           Messages.InterAgentAsyncMessage,
 			Sender:         fmt.Sprintf("%d", agent.ID),
-			ContentType:    Messages.InterAgentAsyncMessageContent,
-			Content:        "Ping",
+			ContentType:    Messages.TextContent,
+			Content:        Messages.Text("Ping").Content,
 			ExpectResponse: false,
 		}, 2)
 	}
@@ -124,8 +123,7 @@ func (b *BasicBehaviour2) HandleMailboxMessage(agent *Agent.Agent, msg Messages.
 
 }
 func (b *BasicBehaviour2) HandleSyncCommunication(agent *Agent.Agent, msg Messages.Message) {
-	var payload Messages.InterAgentSyncMessagePayload
-	err := json.Unmarshal([]byte(msg.Content), &payload)
+	payload, err := Messages.Decode[Messages.InterAgentSyncMessagePayload](msg)
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -136,16 +134,16 @@ func (b *BasicBehaviour2) HandleSyncCommunication(agent *Agent.Agent, msg Messag
 		Content:    "Ping",
 	}
 
-	payloadStr, _ := json.Marshal(answerPayload)
+	payloadStr, _ := Messages.Encode(Messages.InterAgentSyncMessageContent, answerPayload)
 
 	if payload.Content == "Pong" || payload.Content == "Let's start !" {
 		agent.SendSyncMessage(Messages.Message{
 			Type:        Messages.InterAgentSyncMessage,
 			Sender:      fmt.Sprintf("%d", agent.ID),
 			ContentType: Messages.InterAgentSyncMessageContent,
-			Content:     string(payloadStr),
+			Content:     payloadStr,
 		})
-	} else if msg.Content == "Stop !" {
+	} else if payload.Content == "Stop !" {
 		agent.StopSynchronousCommunication()
 	}
 	fmt.Printf("AGENT %d: Message synchrone recu : %s\n", agent.ID, msg.Content)
@@ -160,8 +158,12 @@ func main() {
 
 	if *isMainContainer {
 		fmt.Printf("Starting MainContainer on port %s...\n", *port)
-		mainContainer := Container.NewMainContainer("localhost:" + *port)
-		agent1 := mainContainer.AddAgent()
+		mainContainer, err := Container.NewMainContainer("localhost:" + *port)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		agent1, _ := mainContainer.AddAgent("")
 		agent1Ref := mainContainer.GetAgent(agent1)
 		agent1Ref.RegisterBehaviour("BasicBehaviour", &BasicBehaviour2{})
 		agent1Ref.SetBehaviour("BasicBehaviour")
@@ -174,8 +176,12 @@ func main() {
 	} else {
 		//time.Sleep(10 * time.Second)
 		fmt.Printf("Starting Container on port %s...\n", *port)
-		container := Container.NewContainer("localhost:8080", "localhost:8081")
-		agent2 := container.AddAgent()
+		container, err := Container.NewContainer("localhost:8080", "localhost:8081")
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		agent2, _ := container.AddAgent("")
 		agent2Ref := container.GetAgent(agent2)
 		agent2Ref.RegisterBehaviour("BasicBehaviour", &BasicBehaviour1{})
 		agent2Ref.SetBehaviour("BasicBehaviour")
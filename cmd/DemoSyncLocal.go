@@ -24,53 +24,55 @@ func (b *BasicBehaviour1) Act(agent *Agent.Agent, params ...interface{}) {
 		agent.SendSyncMessage(Messages.Message{
 			Type:        Messages.InterAgentSyncMessage,
 			Sender:      fmt.Sprintf("%d", agent.ID),
-			ContentType: Messages.InterAgentSyncMessageContent,
-			Content:     "Let's start !",
+			ContentType: Messages.TextContent,
+			Content:     Messages.Text("Let's start !").Content,
 		})
 	}
 	fmt.Printf("AGENT %d: Tick %d\n", agent.ID, b.tick)
 	b.tick++
 }
 func (b *BasicBehaviour1) HandleMailboxMessage(agent *Agent.Agent, msg Messages.Message) {
+	text, _ := msg.Text()
 	if b.tick < 10 {
 		agent.SendMail(Messages.Message{
 			Type:           Messages.InterAgentAsyncMessage,
 			Sender:         fmt.Sprintf("%d", agent.ID),
-			ContentType:    Messages.InterAgentAsyncMessageContent,
-			Content:        "Pong",
+			ContentType:    Messages.TextContent,
+			Content:        Messages.Text("Pong").Content,
 			ExpectResponse: false,
 		}, 2)
 	} else {
 		agent.SendMail(Messages.Message{
 			Type:           Messages.InterAgentAsyncMessage,
 			Sender:         fmt.Sprintf("%d", agent.ID),
-			ContentType:    Messages.InterAgentAsyncMessageContent,
-			Content:        "Stop !",
+			ContentType:    Messages.TextContent,
+			Content:        Messages.Text("Stop !").Content,
 			ExpectResponse: false,
 		}, 2)
 	}
-	fmt.Printf("AGENT %s: Message recu: %s\n", fmt.Sprintf("%d", agent.ID), msg.Content)
+	fmt.Printf("AGENT %s: Message recu: %s\n", fmt.Sprintf("%d", agent.ID), text)
 	b.tick++
 }
 func (b *BasicBehaviour1) HandleSyncCommunication(agent *Agent.Agent, msg Messages.Message) {
-	if msg.Content == "Ping" && b.tick < 10 {
+	text, _ := msg.Text()
+	if text == "Ping" && b.tick < 10 {
 		agent.SendSyncMessage(Messages.Message{
 			Type:        Messages.InterAgentSyncMessage,
 			Sender:      fmt.Sprintf("%d", agent.ID),
-			ContentType: Messages.InterAgentSyncMessageContent,
-			Content:     "Pong",
+			ContentType: Messages.TextContent,
+			Content:     Messages.Text("Pong").Content,
 		})
 	} else if b.tick >= 10 {
 		agent.SendSyncMessage(Messages.Message{
 			Type:        Messages.InterAgentSyncMessage,
 			Sender:      fmt.Sprintf("%d", agent.ID),
-			ContentType: Messages.InterAgentSyncMessageContent,
-			Content:     "Stop !",
+			ContentType: Messages.TextContent,
+			Content:     Messages.Text("Stop !").Content,
 		})
 	} else {
 		agent.StopSynchronousCommunication()
 	}
-	fmt.Printf("AGENT %d: Message synchrone recu : %s\n", agent.ID, msg.Content)
+	fmt.Printf("AGENT %d: Message synchrone recu : %s\n", agent.ID, text)
 	b.tick++
 }
 
@@ -81,38 +83,44 @@ func (b *BasicBehaviour2) Decide(agent *Agent.Agent, params ...interface{})   {}
 func (b *BasicBehaviour2) Act(agent *Agent.Agent, params ...interface{}) {
 }
 func (b *BasicBehaviour2) HandleMailboxMessage(agent *Agent.Agent, msg Messages.Message) {
-	if msg.Content != "Stop !" {
+	text, _ := msg.Text()
+	if text != "Stop !" {
 		agent.SendMail(Messages.Message{
 			Type:           Messages.InterAgentAsyncMessage,
 			Sender:         fmt.Sprintf("%d", agent.ID),
-			ContentType:    Messages.InterAgentAsyncMessageContent,
-			Content:        "Ping",
+			ContentType:    Messages.TextContent,
+			Content:        Messages.Text("Ping").Content,
 			ExpectResponse: false,
 		}, 1)
 	}
-	fmt.Printf("AGENT %s: Message recu: %s\n", fmt.Sprintf("%d", agent.ID), msg.Content)
+	fmt.Printf("AGENT %s: Message recu: %s\n", fmt.Sprintf("%d", agent.ID), text)
 
 }
 func (b *BasicBehaviour2) HandleSyncCommunication(agent *Agent.Agent, msg Messages.Message) {
-	if msg.Content == "Pong" || msg.Content == "Let's start !" {
+	text, _ := msg.Text()
+	if text == "Pong" || text == "Let's start !" {
 		agent.SendSyncMessage(Messages.Message{
 			Type:        Messages.InterAgentSyncMessage,
 			Sender:      fmt.Sprintf("%d", agent.ID),
-			ContentType: Messages.InterAgentSyncMessageContent,
-			Content:     "Ping",
+			ContentType: Messages.TextContent,
+			Content:     Messages.Text("Ping").Content,
 		})
-	} else if msg.Content == "Stop !" {
+	} else if text == "Stop !" {
 		agent.StopSynchronousCommunication()
 	}
-	fmt.Printf("AGENT %d: Message synchrone recu : %s\n", agent.ID, msg.Content)
+	fmt.Printf("AGENT %d: Message synchrone recu : %s\n", agent.ID, text)
 }
 
 func main() {
 
-	mainContainer := Container.NewMainContainer("localhost:8080")
+	mainContainer, err := Container.NewMainContainer("localhost:8080")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 
-	agent1 := mainContainer.AddAgent()
-	agent2 := mainContainer.AddAgent()
+	agent1, _ := mainContainer.AddAgent("")
+	agent2, _ := mainContainer.AddAgent("")
 
 	agent1Ref := mainContainer.GetAgent(agent1)
 	agent2Ref := mainContainer.GetAgent(agent2)
@@ -132,4 +140,4 @@ func main() {
 		time.Sleep(1 * time.Second)
 	}
 
-}
\ No newline at end of file
+}
@@ -4,8 +4,13 @@ import "FrameworkMultiAgents/Messages"
 
 type ContainerOps interface {
 	RegisterContainer(address string) string
-	RegisterAgent(agentID string) string
+	RegisterAgent(containerID, name string) (string, error)
+	DeregisterAgent(agentID string) bool
+	DeregisterContainer(address string) bool
 	PutMessageInMailBox(message Messages.Message, receiverID int)
 	ResolveAgentAddress(agentID string) (string, error)
+	ResolveAgentName(name string) (agentID, address string, err error)
+	LocalAgentID(receiver string) (int, bool)
+	PlatformName() string
 	UpdateAgentSyncChannel(agentID string, channel chan Messages.Message)
 }